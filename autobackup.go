@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	ws "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Automatic backups get their own prefix, so pruning never touches backups saved by hand.
+const autoBackupFilePrefix = "cep_autobackup_"
+const autoBackupFileSuffix = ".zip"
+
+// autoBackupSettingsFile holds the automatic backup settings of this machine per database,
+// in the user's configuration directory.
+const autoBackupSettingsFile = "autobackup.json"
+
+type autoBackupSettings struct {
+	Folder          string `json:"folder"`
+	IntervalMinutes int    `json:"intervalMinutes"`
+	Retention       int    `json:"retention"`
+}
+
+// ConfigureAutoBackup writes a backup into folder every intervalMinutes and keeps the newest
+// retention files (0 keeps all). An empty folder or an interval of 0 disables automatic backups.
+// The settings are saved on this machine only, so just the clients configured for it write backups,
+// and are picked up again when InitDB connects to the same database.
+func (c *Core) ConfigureAutoBackup(folder string, intervalMinutes int, retention int) error {
+	if c.DB == nil {
+		return errors.New("DB not initialized")
+	}
+	if intervalMinutes < 0 {
+		return errors.New("intervalMinutes must not be negative")
+	}
+	if retention < 0 {
+		return errors.New("retention must not be negative")
+	}
+	folder = strings.TrimSpace(folder)
+	if folder != "" {
+		if err := os.MkdirAll(folder, 0o755); err != nil {
+			return fmt.Errorf("error creating backup folder '%s': %w", folder, err)
+		}
+	}
+
+	settings := autoBackupSettings{Folder: folder, IntervalMinutes: intervalMinutes, Retention: retention}
+	path, err := autoBackupSettingsPath()
+	if err != nil {
+		return err
+	}
+	if err := saveAutoBackupSettings(path, c.sourceDB, settings); err != nil {
+		return err
+	}
+	c.applyAutoBackupSettings(settings)
+	c.startAutoBackup()
+	return nil
+}
+
+func (c *Core) applyAutoBackupSettings(settings autoBackupSettings) {
+	c.backupMu.Lock()
+	defer c.backupMu.Unlock()
+	c.backupFolder = settings.Folder
+	c.backupInterval = time.Duration(settings.IntervalMinutes) * time.Minute
+	c.backupRetention = settings.Retention
+}
+
+// loadAutoBackupSettings applies the automatic backup settings this machine saved for the connected
+// database. Without saved settings automatic backups stay disabled.
+func (c *Core) loadAutoBackupSettings() {
+	var settings autoBackupSettings
+	path, err := autoBackupSettingsPath()
+	if err == nil {
+		var all map[string]autoBackupSettings
+		all, err = readAutoBackupSettings(path)
+		settings = all[c.sourceDB]
+	}
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	c.applyAutoBackupSettings(settings)
+}
+
+func autoBackupSettingsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("error locating the configuration directory: %w", err)
+	}
+	return filepath.Join(dir, "CEP", autoBackupSettingsFile), nil
+}
+
+// readAutoBackupSettings reads the settings file at path; a missing file holds no settings.
+func readAutoBackupSettings(path string) (map[string]autoBackupSettings, error) {
+	all := make(map[string]autoBackupSettings)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return all, nil
+		}
+		return nil, fmt.Errorf("error reading automatic backup settings: %w", err)
+	}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("invalid automatic backup settings in '%s': %w", path, err)
+	}
+	return all, nil
+}
+
+// saveAutoBackupSettings stores the settings of sourceDB in the settings file at path and keeps
+// those of other databases.
+func saveAutoBackupSettings(path string, sourceDB string, settings autoBackupSettings) error {
+	all, err := readAutoBackupSettings(path)
+	if err != nil {
+		return err
+	}
+	if settings.Folder == "" || settings.IntervalMinutes == 0 {
+		delete(all, sourceDB)
+	} else {
+		all[sourceDB] = settings
+	}
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error converting automatic backup settings to JSON: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating settings folder: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("error saving automatic backup settings: %w", err)
+	}
+	return nil
+}
+
+// startAutoBackup (re)starts the backup goroutine with the current configuration.
+func (c *Core) startAutoBackup() {
+	c.stopAutoBackup()
+
+	c.backupMu.Lock()
+	defer c.backupMu.Unlock()
+	if c.backupFolder == "" || c.backupInterval <= 0 || c.ctx == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.backupCancel = cancel
+	go c.autoBackupLoop(ctx, c.backupFolder, c.backupInterval, c.backupRetention)
+	log.Printf("Automatic backups every %s into '%s'.", c.backupInterval, c.backupFolder)
+}
+
+func (c *Core) stopAutoBackup() {
+	c.backupMu.Lock()
+	defer c.backupMu.Unlock()
+	if c.backupCancel != nil {
+		c.backupCancel()
+		c.backupCancel = nil
+	}
+}
+
+func (c *Core) autoBackupLoop(ctx context.Context, folder string, interval time.Duration, retention int) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("PANIC in autoBackupLoop: %v", r)
+		}
+	}()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.runAutoBackup(ctx, folder, retention)
+		}
+	}
+}
+
+func (c *Core) runAutoBackup(ctx context.Context, folder string, retention int) {
+	// The folder may have been removed or be on a drive that was not mounted when the loop started.
+	if err := os.MkdirAll(folder, 0o755); err != nil {
+		log.Printf("Automatic backup failed: %v", err)
+		ws.EventsEmit(ctx, "backup:failed", err.Error())
+		return
+	}
+	filePath := filepath.Join(folder, autoBackupFilePrefix+time.Now().Format("20060102_150405")+autoBackupFileSuffix)
+	if err := c.BackupDatabase(filePath); err != nil {
+		log.Printf("Automatic backup failed: %v", err)
+		ws.EventsEmit(ctx, "backup:failed", err.Error())
+		return
+	}
+	ws.EventsEmit(ctx, "backup:completed", filePath)
+	if err := pruneAutoBackups(folder, retention); err != nil {
+		log.Printf("Warning: pruning old backups failed: %v", err)
+	}
+}
+
+// pruneAutoBackups removes all but the newest retention automatic backups in folder.
+// The timestamp in the file name sorts chronologically.
+func pruneAutoBackups(folder string, retention int) error {
+	if retention <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return err
+	}
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, autoBackupFilePrefix) && strings.HasSuffix(name, autoBackupFileSuffix) {
+			backups = append(backups, name)
+		}
+	}
+	if len(backups) <= retention {
+		return nil
+	}
+	sort.Strings(backups)
+	for _, name := range backups[:len(backups)-retention] {
+		if err := os.Remove(filepath.Join(folder, name)); err != nil {
+			return err
+		}
+		log.Printf("Removed old backup '%s'.", name)
+	}
+	return nil
+}
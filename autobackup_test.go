@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPruneAutoBackups(t *testing.T) {
+	folder := t.TempDir()
+	files := []string{
+		"cep_autobackup_20240101_120000.zip",
+		"cep_autobackup_20240102_120000.zip",
+		"cep_autobackup_20240103_120000.zip",
+		// Backups saved by hand and other files are never removed.
+		"cep_backup_20230101_120000.zip",
+		"cep_autobackup_notes.txt",
+	}
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(folder, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pruneAutoBackups(folder, 2); err != nil {
+		t.Fatalf("pruneAutoBackups returned %v", err)
+	}
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	sort.Strings(got)
+	want := []string{
+		"cep_autobackup_20240102_120000.zip",
+		"cep_autobackup_20240103_120000.zip",
+		"cep_autobackup_notes.txt",
+		"cep_backup_20230101_120000.zip",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("files after pruning %q, want %q", got, want)
+	}
+}
+
+func TestAutoBackupSettingsPerDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "CEP", autoBackupSettingsFile)
+	all, err := readAutoBackupSettings(path)
+	if err != nil || len(all) != 0 {
+		t.Fatalf("missing settings file read as %v, %v", all, err)
+	}
+
+	first := autoBackupSettings{Folder: "C:/Backups/A", IntervalMinutes: 60, Retention: 5}
+	second := autoBackupSettings{Folder: "C:/Backups/B", IntervalMinutes: 30}
+	if err := saveAutoBackupSettings(path, "server/a", first); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveAutoBackupSettings(path, "server/b", second); err != nil {
+		t.Fatal(err)
+	}
+	all, err = readAutoBackupSettings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all["server/a"] != first || all["server/b"] != second {
+		t.Errorf("saved settings %v, want %v for server/a and %v for server/b", all, first, second)
+	}
+
+	// Disabling automatic backups removes the entry of that database only.
+	if err := saveAutoBackupSettings(path, "server/a", autoBackupSettings{}); err != nil {
+		t.Fatal(err)
+	}
+	all, err = readAutoBackupSettings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := all["server/a"]; ok || all["server/b"] != second {
+		t.Errorf("settings after disabling server/a: %v", all)
+	}
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	ws "github.com/wailsapp/wails/v2/pkg/runtime"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const backupFormatName = "cep-backup"
+const currentBackupSchemaVersion = 1
+const backupManifestFile = "manifest.json"
+
+// backupBatchSize is the number of rows read and written at once. Inserts stay below the
+// SQL Server parameter limit for the widest table.
+const backupBatchSize = 50
+
+// Restore modes: RestoreModeEmpty only restores into a database without CEP data,
+// RestoreModeReplace deletes all CEP data first and RestoreModeMerge keeps existing rows
+// and adds the missing ones.
+const RestoreModeEmpty = "empty"
+const RestoreModeReplace = "replace"
+const RestoreModeMerge = "merge"
+
+type BackupTableInfo struct {
+	Table string `json:"table"`
+	File  string `json:"file"`
+	Rows  int    `json:"rows"`
+}
+
+type BackupManifest struct {
+	Format                string            `json:"format"`
+	SchemaVersion         int               `json:"schemaVersion"`
+	CreatedAt             time.Time         `json:"createdAt"`
+	CreatedBy             string            `json:"createdBy"`
+	SourceDB              string            `json:"sourceDB"`
+	DependencyCatalogHash string            `json:"dependencyCatalogHash"`
+	Tables                []BackupTableInfo `json:"tables"`
+}
+
+// backupTable describes a table of a backup. keys are the primary key columns the rows are paged by.
+type backupTable struct {
+	name  string
+	keys  []string
+	model func() interface{}
+	batch func() interface{}
+}
+
+// backupTables lists every table of a backup in restore order, parents before their children.
+// Soft-deleted rows are included so the recycle bin survives a restore.
+var backupTables = []backupTable{
+	{"lines", []string{"id"}, func() interface{} { return &Line{} }, func() interface{} { return &[]Line{} }},
+	{"stations", []string{"id"}, func() interface{} { return &Station{} }, func() interface{} { return &[]Station{} }},
+	{"sequence_groups", []string{"id"}, func() interface{} { return &SequenceGroup{} }, func() interface{} { return &[]SequenceGroup{} }},
+	{"tools", []string{"id"}, func() interface{} { return &Tool{} }, func() interface{} { return &[]Tool{} }},
+	{"operations", []string{"id"}, func() interface{} { return &Operation{} }, func() interface{} { return &[]Operation{} }},
+	{"line_histories", []string{"entity_id", "version"}, func() interface{} { return &LineHistory{} }, func() interface{} { return &[]LineHistory{} }},
+	{"station_histories", []string{"entity_id", "version"}, func() interface{} { return &StationHistory{} }, func() interface{} { return &[]StationHistory{} }},
+	{"sequence_group_histories", []string{"entity_id", "version"}, func() interface{} { return &SequenceGroupHistory{} }, func() interface{} { return &[]SequenceGroupHistory{} }},
+	{"tool_histories", []string{"entity_id", "version"}, func() interface{} { return &ToolHistory{} }, func() interface{} { return &[]ToolHistory{} }},
+	{"operation_histories", []string{"entity_id", "version"}, func() interface{} { return &OperationHistory{} }, func() interface{} { return &[]OperationHistory{} }},
+	{"entity_change_logs", []string{"log_id"}, func() interface{} { return &EntityChangeLog{} }, func() interface{} { return &[]EntityChangeLog{} }},
+	{"recycle_bin_entries", []string{"entity_id"}, func() interface{} { return &RecycleBinEntry{} }, func() interface{} { return &[]RecycleBinEntry{} }},
+	{"line_baselines", []string{"id"}, func() interface{} { return &LineBaseline{} }, func() interface{} { return &[]LineBaseline{} }},
+	{"app_metadata", []string{"config_key"}, func() interface{} { return &AppMetadata{} }, func() interface{} { return &[]AppMetadata{} }},
+}
+
+// keysetCondition returns the condition for the rows that follow a row with the given key values
+// in the order of keys, e.g. "a > ? OR (a = ? AND b > ?)" for two keys.
+func keysetCondition(keys []string, values []interface{}) (string, []interface{}) {
+	var terms []string
+	var args []interface{}
+	for i := range keys {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, keys[j]+" = ?")
+			args = append(args, values[j])
+		}
+		parts = append(parts, keys[i]+" > ?")
+		args = append(args, values[i])
+		terms = append(terms, "("+strings.Join(parts, " AND ")+")")
+	}
+	return strings.Join(terms, " OR "), args
+}
+
+// lastRowKeys returns the key values of the last row of a batch.
+func lastRowKeys(tx *gorm.DB, table backupTable, batch interface{}) ([]interface{}, error) {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(table.model()); err != nil {
+		return nil, fmt.Errorf("error reading schema of table %s: %w", table.name, err)
+	}
+	rows := reflect.Indirect(reflect.ValueOf(batch))
+	last := rows.Index(rows.Len() - 1)
+	values := make([]interface{}, len(table.keys))
+	for i, key := range table.keys {
+		field := stmt.Schema.LookUpField(key)
+		if field == nil {
+			return nil, fmt.Errorf("table %s has no column %s", table.name, key)
+		}
+		values[i], _ = field.ValueOf(tx.Statement.Context, last)
+	}
+	return values, nil
+}
+
+func (c *Core) HandleBackup() string {
+	file, _ := ws.SaveFileDialog(c.ctx, ws.SaveDialogOptions{
+		DefaultFilename: fmt.Sprintf("cep_backup_%s.zip", time.Now().Format("20060102_150405")),
+		Title:           "Backup",
+		Filters: []ws.FileFilter{
+			{DisplayName: "CEP Backup", Pattern: "*.zip"},
+			{DisplayName: "*", Pattern: "*.*"},
+		},
+	})
+	err := c.BackupDatabase(file)
+	if err != nil {
+		return "BackupError"
+	} else {
+		return "BackupSuccess"
+	}
+}
+
+func (c *Core) HandleRestore(mode string) string {
+	file, _ := ws.OpenFileDialog(c.ctx, ws.OpenDialogOptions{
+		Title: "Restore",
+		Filters: []ws.FileFilter{
+			{DisplayName: "CEP Backup", Pattern: "*.zip"},
+			{DisplayName: "*", Pattern: "*.*"},
+		},
+	})
+	err := c.RestoreDatabase(file, mode)
+	if err != nil {
+		return "RestoreError"
+	} else {
+		return "RestoreSuccess"
+	}
+}
+
+// BackupDatabase writes all CEP tables into a zip archive with one JSON Lines file per table
+// and a manifest. Each line of a table file holds a batch of rows.
+func (c *Core) BackupDatabase(filePath string) (err error) {
+	if c.DB == nil {
+		return errors.New("DB not initialized")
+	}
+	if filePath == "" {
+		return errors.New("backup filePath is empty")
+	}
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("error creating file '%s': %w", filePath, err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("error closing file '%s': %w", filePath, closeErr)
+		}
+		if err != nil {
+			os.Remove(filePath)
+		}
+	}()
+	if err := c.writeBackup(file); err != nil {
+		return err
+	}
+	log.Printf("Database backup written to '%s'.", filePath)
+	return nil
+}
+
+func (c *Core) writeBackup(w io.Writer) error {
+	manifest := BackupManifest{
+		Format:                backupFormatName,
+		SchemaVersion:         currentBackupSchemaVersion,
+		CreatedAt:             time.Now().UTC(),
+		CreatedBy:             c.GetPlatformSpecificUserName(),
+		SourceDB:              c.sourceDB,
+		DependencyCatalogHash: c.dependencyCatalogHash(),
+	}
+	zw := zip.NewWriter(w)
+	err := c.DB.Transaction(func(tx *gorm.DB) error {
+		for _, table := range backupTables {
+			info := BackupTableInfo{Table: table.name, File: "tables/" + table.name + ".jsonl"}
+			part, err := zw.Create(info.File)
+			if err != nil {
+				return err
+			}
+			encoder := json.NewEncoder(part)
+			// Rows are paged by their key instead of an offset, so rows written meanwhile
+			// cannot shift the pages and make the backup skip or repeat rows.
+			var lastKeys []interface{}
+			for {
+				batch := table.batch()
+				query := tx.Unscoped().Model(table.model()).Order(strings.Join(table.keys, ", ")).Limit(backupBatchSize)
+				if lastKeys != nil {
+					condition, args := keysetCondition(table.keys, lastKeys)
+					query = query.Where(condition, args...)
+				}
+				result := query.Find(batch)
+				if result.Error != nil {
+					return fmt.Errorf("error reading table %s: %w", table.name, result.Error)
+				}
+				if result.RowsAffected == 0 {
+					break
+				}
+				if err := encoder.Encode(batch); err != nil {
+					return fmt.Errorf("error writing table %s: %w", table.name, err)
+				}
+				info.Rows += int(result.RowsAffected)
+				if result.RowsAffected < backupBatchSize {
+					break
+				}
+				if lastKeys, err = lastRowKeys(tx, table, batch); err != nil {
+					return err
+				}
+			}
+			manifest.Tables = append(manifest.Tables, info)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	part, err := zw.Create(backupManifestFile)
+	if err != nil {
+		return err
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error converting manifest to JSON: %w", err)
+	}
+	if _, err := part.Write(manifestJSON); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("error finishing backup archive: %w", err)
+	}
+	return nil
+}
+
+// readBackupManifest opens a backup archive and checks its manifest.
+func readBackupManifest(filePath string) (*zip.ReadCloser, *BackupManifest, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening backup '%s': %w", filePath, err)
+	}
+	manifestFile, err := zr.Open(backupManifestFile)
+	if err != nil {
+		zr.Close()
+		return nil, nil, fmt.Errorf("backup '%s' has no manifest: %w", filePath, err)
+	}
+	var manifest BackupManifest
+	err = json.NewDecoder(manifestFile).Decode(&manifest)
+	manifestFile.Close()
+	if err != nil {
+		zr.Close()
+		return nil, nil, fmt.Errorf("invalid backup manifest: %w", err)
+	}
+	if manifest.Format != backupFormatName {
+		zr.Close()
+		return nil, nil, fmt.Errorf("'%s' is not a CEP backup", filePath)
+	}
+	if manifest.SchemaVersion > currentBackupSchemaVersion {
+		zr.Close()
+		return nil, nil, fmt.Errorf("backup schema version %d is newer than the supported version %d", manifest.SchemaVersion, currentBackupSchemaVersion)
+	}
+	return zr, &manifest, nil
+}
+
+// RestoreDatabase replays a backup archive inside one transaction.
+func (c *Core) RestoreDatabase(filePath string, mode string) error {
+	if c.DB == nil {
+		return errors.New("DB not initialized")
+	}
+	if filePath == "" {
+		return errors.New("restore filePath is empty")
+	}
+	if mode != RestoreModeEmpty && mode != RestoreModeReplace && mode != RestoreModeMerge {
+		return fmt.Errorf("unknown restore mode: %s", mode)
+	}
+	zr, manifest, err := readBackupManifest(filePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	files := make(map[string]string, len(manifest.Tables))
+	for _, info := range manifest.Tables {
+		files[info.Table] = info.File
+	}
+
+	err = c.DB.Transaction(func(tx *gorm.DB) error {
+		switch mode {
+		case RestoreModeEmpty:
+			for _, table := range backupTables {
+				if table.name == "app_metadata" {
+					continue
+				}
+				var count int64
+				if err := tx.Unscoped().Model(table.model()).Count(&count).Error; err != nil {
+					return fmt.Errorf("error checking table %s: %w", table.name, err)
+				}
+				if count > 0 {
+					return fmt.Errorf("database is not empty (table %s has %d rows)", table.name, count)
+				}
+			}
+		case RestoreModeReplace:
+			for i := len(backupTables) - 1; i >= 0; i-- {
+				table := backupTables[i]
+				if table.name == "app_metadata" {
+					continue
+				}
+				if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(table.model()).Error; err != nil {
+					return fmt.Errorf("error clearing table %s: %w", table.name, err)
+				}
+			}
+		}
+
+		// app_metadata is neither checked nor cleared above: it always has the global timestamp row.
+		// Its rows are restored over the existing ones, except in merge mode.
+		for _, table := range backupTables {
+			fileName, ok := files[table.name]
+			if !ok {
+				continue
+			}
+			rows, err := restoreBackupTable(tx, zr, fileName, table, mode == RestoreModeMerge)
+			if err != nil {
+				return err
+			}
+			log.Printf("Restored %d rows into %s.", rows, table.name)
+		}
+
+		// Clients compare against the global timestamp, so it must move forward after a restore.
+		ensureAppMetadataExists(tx)
+		var emptyMsSQLID mssql.UniqueIdentifier
+		return updateGlobalLastUpdateTimestampAndLogChange(tx, emptyMsSQLID, "system", OpTypeSystemEvent, strPtr(c.GetPlatformSpecificUserName()), nil)
+	})
+	if err != nil {
+		return fmt.Errorf("restore from '%s' failed: %w", filePath, err)
+	}
+	c.loadRecycleBinSettings()
+	c.loadSPSConflictCheck()
+	log.Printf("Database restored from '%s' (mode: %s).", filePath, mode)
+	return nil
+}
+
+func restoreBackupTable(tx *gorm.DB, zr *zip.ReadCloser, fileName string, table backupTable, skipExisting bool) (int, error) {
+	f, err := zr.Open(fileName)
+	if err != nil {
+		return 0, fmt.Errorf("backup file %s is missing: %w", fileName, err)
+	}
+	defer f.Close()
+
+	insert := tx.Omit(clause.Associations)
+	if skipExisting {
+		insert = insert.Clauses(clause.OnConflict{DoNothing: true})
+	} else if table.name == "app_metadata" {
+		insert = insert.Clauses(clause.OnConflict{UpdateAll: true})
+	}
+	decoder := json.NewDecoder(f)
+	rows := 0
+	for {
+		batch := table.batch()
+		if err := decoder.Decode(batch); err == io.EOF {
+			break
+		} else if err != nil {
+			return rows, fmt.Errorf("error reading %s: %w", fileName, err)
+		}
+		result := insert.Session(&gorm.Session{}).Create(batch)
+		if result.Error != nil {
+			return rows, fmt.Errorf("error restoring table %s: %w", table.name, result.Error)
+		}
+		rows += int(result.RowsAffected)
+	}
+	return rows, nil
+}
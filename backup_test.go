@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// countTestRows counts the rows of every backed up table, soft-deleted ones included.
+func countTestRows(t *testing.T, c *Core) map[string]int64 {
+	t.Helper()
+	counts := make(map[string]int64)
+	for _, table := range backupTables {
+		var count int64
+		if err := c.DB.Unscoped().Model(table.model()).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		counts[table.name] = count
+	}
+	return counts
+}
+
+func TestBackupAndRestoreDatabase(t *testing.T) {
+	source := newTestCore(t)
+	line, station, tool, _ := createTestLine(t, source.DB)
+	// More operations than fit into one batch.
+	for i := 0; i < backupBatchSize+10; i++ {
+		op := &Operation{BaseModel: BaseModel{Name: strPtr(fmt.Sprintf("O%d", i+2))}, ParentID: tool.ID}
+		if err := source.DB.Create(op).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	updateTestEntity(t, source, "station", station.ID.String(), map[string]string{"Name": "S1b"})
+	deleted, err := source.CreateEntity("jdoe", "tool", station.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.DeleteEntityByIDString("jdoe", "tool", getIDFromModel(deleted).String()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.CreateLineBaseline("jdoe", line.ID.String(), "B1", ""); err != nil {
+		t.Fatal(err)
+	}
+	backupPath := filepath.Join(t.TempDir(), "cep_backup.zip")
+	if err := source.BackupDatabase(backupPath); err != nil {
+		t.Fatalf("backup returned %v", err)
+	}
+	want := countTestRows(t, source)
+
+	// Restoring adds a system event to the change log.
+	check := func(name string, c *Core, extraLines int64) {
+		t.Helper()
+		got := countTestRows(t, c)
+		for table, count := range want {
+			switch table {
+			case "app_metadata":
+				continue
+			case "entity_change_logs":
+				count++
+			case "lines":
+				count += extraLines
+			}
+			if got[table] != count {
+				t.Errorf("%s: table %s has %d rows, want %d", name, table, got[table], count)
+			}
+		}
+		var restored Tool
+		if err := c.DB.Unscoped().First(&restored, "id = ?", getIDFromModel(deleted)).Error; err != nil || !restored.DeletedAt.Valid {
+			t.Errorf("%s: deleted tool restored as %+v, %v, want it in the recycle bin", name, restored.DeletedAt, err)
+		}
+	}
+
+	target := newTestCore(t)
+	if err := target.RestoreDatabase(backupPath, RestoreModeEmpty); err != nil {
+		t.Fatalf("restore into an empty database returned %v", err)
+	}
+	check("empty", target, 0)
+	if err := target.RestoreDatabase(backupPath, RestoreModeEmpty); err == nil {
+		t.Error("restore into a database with data in empty mode returned no error")
+	}
+
+	// Replace drops what was added since; merge keeps it and adds nothing twice.
+	target = newTestCore(t)
+	createTestLine(t, target.DB)
+	if err := target.RestoreDatabase(backupPath, RestoreModeReplace); err != nil {
+		t.Fatalf("restore in replace mode returned %v", err)
+	}
+	check("replace", target, 0)
+
+	target = newTestCore(t)
+	createTestLine(t, target.DB)
+	if err := target.RestoreDatabase(backupPath, RestoreModeMerge); err != nil {
+		t.Fatalf("restore in merge mode returned %v", err)
+	}
+	if err := target.RestoreDatabase(backupPath, RestoreModeMerge); err != nil {
+		t.Fatalf("second restore in merge mode returned %v", err)
+	}
+	var stations int64
+	target.DB.Model(&Station{}).Count(&stations)
+	if stations != 2 {
+		t.Errorf("merged database has %d stations, want its own and the restored one", stations)
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	mssql "github.com/microsoft/go-mssqldb"
+	ws "github.com/wailsapp/wails/v2/pkg/runtime"
+	"gorm.io/gorm"
+)
+
+// CreateLineBaseline freezes the current configuration of a line under a label.
+func (c *Core) CreateLineBaseline(userName string, lineIDStr string, label string, note string) (*LineBaseline, error) {
+	if c.DB == nil {
+		return nil, errors.New("DB not initialized")
+	}
+	if userName == "" {
+		return nil, errors.New("userName is required for baseline creation")
+	}
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return nil, errors.New("baseline label must not be empty")
+	}
+	lineID, err := parseMSSQLUniqueIdentifierFromString(lineIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var baseline LineBaseline
+	err = c.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&LineBaseline{}).Where("line_id = ? AND label = ?", lineID, label).Count(&count).Error; err != nil {
+			return fmt.Errorf("error checking existing baselines: %w", err)
+		}
+		if count > 0 {
+			return fmt.Errorf("baseline '%s' already exists for this line", label)
+		}
+
+		hierarchyData, err := internalGetEntityHierarchy(tx, "line", lineIDStr)
+		if err != nil {
+			return fmt.Errorf("error loading line for baseline: %w", err)
+		}
+		jsonData, err := json.Marshal(hierarchyData)
+		if err != nil {
+			return fmt.Errorf("error converting line to JSON: %w", err)
+		}
+
+		newID := mssql.UniqueIdentifier{}
+		_ = newID.Scan(uuid.New().String())
+		baseline = LineBaseline{
+			ID:        newID,
+			LineID:    lineID,
+			Label:     label,
+			Note:      strPtr(note),
+			CreatedAt: time.Now(),
+			CreatedBy: strPtr(userName),
+			Snapshot:  string(jsonData),
+		}
+		if err := tx.Create(&baseline).Error; err != nil {
+			return fmt.Errorf("error storing baseline: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	baseline.Snapshot = ""
+	return &baseline, nil
+}
+
+// ListLineBaselines returns the baselines of a line without their snapshots, newest first.
+func (c *Core) ListLineBaselines(lineIDStr string) ([]LineBaseline, error) {
+	if c.DB == nil {
+		return nil, errors.New("DB not initialized")
+	}
+	lineID, err := parseMSSQLUniqueIdentifierFromString(lineIDStr)
+	if err != nil {
+		return nil, err
+	}
+	var baselines []LineBaseline
+	if err := c.DB.Omit("snapshot").Where("line_id = ?", lineID).Order("created_at desc").Find(&baselines).Error; err != nil {
+		return nil, fmt.Errorf("error loading baselines: %w", err)
+	}
+	return baselines, nil
+}
+
+func (c *Core) loadLineBaseline(baselineIDStr string) (*LineBaseline, *Line, error) {
+	baselineID, err := parseMSSQLUniqueIdentifierFromString(baselineIDStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	var baseline LineBaseline
+	if err := c.DB.Where("id = ?", baselineID).Take(&baseline).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, fmt.Errorf("baseline with ID %s not found", baselineIDStr)
+		}
+		return nil, nil, fmt.Errorf("error loading baseline %s: %w", baselineIDStr, err)
+	}
+	var line Line
+	if err := json.Unmarshal([]byte(baseline.Snapshot), &line); err != nil {
+		return nil, nil, fmt.Errorf("error unmarshalling baseline snapshot: %w", err)
+	}
+	return &baseline, &line, nil
+}
+
+func (c *Core) HandleBaselineExport(user string, baselineIDStr string) string {
+	file, _ := ws.SaveFileDialog(c.ctx, ws.SaveDialogOptions{
+		DefaultFilename: fmt.Sprintf("baseline_%s_export.json", baselineIDStr),
+		Title:           "Export",
+		Filters: []ws.FileFilter{
+			{DisplayName: "JSON", Pattern: "*.json"},
+			{DisplayName: "*", Pattern: "*.*"},
+		},
+	})
+	err := c.ExportLineBaselineToJSON(user, baselineIDStr, file)
+	if err != nil {
+		return "ExportError"
+	} else {
+		return "ExportSuccess"
+	}
+}
+
+// ExportLineBaselineToJSON writes a baseline in the same format as ExportEntityHierarchyToJSON,
+// so it can be imported like any other line export.
+func (c *Core) ExportLineBaselineToJSON(userName string, baselineIDStr string, filePath string) error {
+	if c.DB == nil {
+		return errors.New("DB not initialized")
+	}
+	if filePath == "" {
+		return errors.New("export filePath is empty")
+	}
+	_, line, err := c.loadLineBaseline(baselineIDStr)
+	if err != nil {
+		return err
+	}
+	envelope, err := c.newExportEnvelope(userName, "line", line)
+	if err != nil {
+		return err
+	}
+	jsonData, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error converting to JSON: %w", err)
+	}
+	if err := os.WriteFile(filePath, jsonData, 0644); err != nil {
+		return fmt.Errorf("error writing JSON file '%s': %w", filePath, err)
+	}
+	log.Printf("Baseline successfully exported to '%s'.", filePath)
+	return nil
+}
+
+type BaselineEntityRef struct {
+	EntityType string  `json:"entityType"`
+	EntityID   string  `json:"entityId"`
+	Name       *string `json:"name"`
+}
+
+type BaselineEntityChange struct {
+	BaselineEntityRef
+	Changes []FieldDiff `json:"changes"`
+}
+
+type BaselineComparison struct {
+	BaselineID string                 `json:"baselineId"`
+	Label      string                 `json:"label"`
+	CreatedAt  time.Time              `json:"createdAt"`
+	Added      []BaselineEntityRef    `json:"added"`
+	Removed    []BaselineEntityRef    `json:"removed"`
+	Changed    []BaselineEntityChange `json:"changed"`
+}
+
+type flatEntity struct {
+	entityType string
+	entity     interface{}
+}
+
+// flattenLine lists every entity of a line hierarchy keyed by its ID.
+func flattenLine(line *Line) map[string]flatEntity {
+	result := make(map[string]flatEntity)
+	flattenHierarchy(line, "line", result)
+	return result
+}
+
+// flattenHierarchy adds an entity and everything below it to result, keyed by ID.
+func flattenHierarchy(entity interface{}, entityType string, result map[string]flatEntity) {
+	result[getIDFromModel(entity).String()] = flatEntity{entityType, entity}
+	switch e := entity.(type) {
+	case *Line:
+		for i := range e.Stations {
+			flattenHierarchy(&e.Stations[i], "station", result)
+		}
+	case *Station:
+		for i := range e.SequenceGroups {
+			result[e.SequenceGroups[i].ID.String()] = flatEntity{"sequencegroup", &e.SequenceGroups[i]}
+		}
+		for i := range e.Tools {
+			flattenHierarchy(&e.Tools[i], "tool", result)
+		}
+	case *Tool:
+		for i := range e.Operations {
+			result[e.Operations[i].ID.String()] = flatEntity{"operation", &e.Operations[i]}
+		}
+	}
+}
+
+// CompareBaselineWithLive lists the entities added, removed and changed since the baseline was taken.
+func (c *Core) CompareBaselineWithLive(baselineIDStr string) (*BaselineComparison, error) {
+	if c.DB == nil {
+		return nil, errors.New("DB not initialized")
+	}
+	baseline, baselineLine, err := c.loadLineBaseline(baselineIDStr)
+	if err != nil {
+		return nil, err
+	}
+	liveData, err := internalGetEntityHierarchy(c.DB, "line", baseline.LineID.String())
+	if err != nil {
+		return nil, fmt.Errorf("error loading live line: %w", err)
+	}
+
+	baselineEntities := flattenLine(baselineLine)
+	liveEntities := flattenLine(liveData.(*Line))
+	comparison := &BaselineComparison{
+		BaselineID: baseline.ID.String(),
+		Label:      baseline.Label,
+		CreatedAt:  baseline.CreatedAt,
+		Added:      []BaselineEntityRef{},
+		Removed:    []BaselineEntityRef{},
+		Changed:    []BaselineEntityChange{},
+	}
+
+	for id, live := range liveEntities {
+		name, _ := entityNameAndParent(live.entity)
+		ref := BaselineEntityRef{EntityType: live.entityType, EntityID: id, Name: name}
+		old, existed := baselineEntities[id]
+		if !existed {
+			comparison.Added = append(comparison.Added, ref)
+			continue
+		}
+		oldFields, err := versionedFields(old.entity)
+		if err != nil {
+			return nil, err
+		}
+		liveFields, err := versionedFields(live.entity)
+		if err != nil {
+			return nil, err
+		}
+		var changes []FieldDiff
+		for field, value := range liveFields {
+			if derefOrEmpty(oldFields[field]) != derefOrEmpty(value) {
+				changes = append(changes, FieldDiff{Field: field, OldValue: oldFields[field], NewValue: value})
+			}
+		}
+		if len(changes) > 0 {
+			sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+			comparison.Changed = append(comparison.Changed, BaselineEntityChange{BaselineEntityRef: ref, Changes: changes})
+		}
+	}
+	for id, old := range baselineEntities {
+		if _, stillExists := liveEntities[id]; !stillExists {
+			name, _ := entityNameAndParent(old.entity)
+			comparison.Removed = append(comparison.Removed, BaselineEntityRef{EntityType: old.entityType, EntityID: id, Name: name})
+		}
+	}
+
+	byTypeAndID := func(a, b BaselineEntityRef) bool {
+		if a.EntityType != b.EntityType {
+			return a.EntityType < b.EntityType
+		}
+		return a.EntityID < b.EntityID
+	}
+	sort.Slice(comparison.Added, func(i, j int) bool { return byTypeAndID(comparison.Added[i], comparison.Added[j]) })
+	sort.Slice(comparison.Removed, func(i, j int) bool { return byTypeAndID(comparison.Removed[i], comparison.Removed[j]) })
+	sort.Slice(comparison.Changed, func(i, j int) bool {
+		return byTypeAndID(comparison.Changed[i].BaselineEntityRef, comparison.Changed[j].BaselineEntityRef)
+	})
+	return comparison, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLineBaseline(t *testing.T) {
+	c := newTestCore(t)
+	line, station, _, op := createTestLine(t, c.DB)
+	baseline, err := c.CreateLineBaseline("jdoe", line.ID.String(), " Release 1 ", "")
+	if err != nil {
+		t.Fatalf("baseline creation returned %v", err)
+	}
+	if baseline.Label != "Release 1" || baseline.Snapshot != "" {
+		t.Errorf("created baseline %+v, want the trimmed label without its snapshot", baseline)
+	}
+	if _, err := c.CreateLineBaseline("jdoe", line.ID.String(), "Release 1", ""); err == nil {
+		t.Error("second baseline with the same label returned no error")
+	}
+	if baselines, err := c.ListLineBaselines(line.ID.String()); err != nil || len(baselines) != 1 || baselines[0].Snapshot != "" {
+		t.Errorf("listed baselines %+v, %v, want one without its snapshot", baselines, err)
+	}
+
+	updateTestEntity(t, c, "station", station.ID.String(), map[string]string{"Name": "S1b"})
+	added, err := c.CreateEntity("jdoe", "tool", station.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteEntityByIDString("jdoe", "operation", op.ID.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	comparison, err := c.CompareBaselineWithLive(baseline.ID.String())
+	if err != nil {
+		t.Fatalf("comparison returned %v", err)
+	}
+	refs := func(list []BaselineEntityRef) []string {
+		var ids []string
+		for _, ref := range list {
+			ids = append(ids, ref.EntityType+" "+ref.EntityID)
+		}
+		return ids
+	}
+	if got, want := refs(comparison.Added), []string{"tool " + getIDFromModel(added).String()}; !reflect.DeepEqual(got, want) {
+		t.Errorf("added %q, want %q", got, want)
+	}
+	if got, want := refs(comparison.Removed), []string{"operation " + op.ID.String()}; !reflect.DeepEqual(got, want) {
+		t.Errorf("removed %q, want %q", got, want)
+	}
+	if len(comparison.Changed) != 1 || comparison.Changed[0].EntityID != station.ID.String() ||
+		len(comparison.Changed[0].Changes) != 1 || derefOrEmpty(comparison.Changed[0].Changes[0].NewValue) != "S1b" {
+		t.Errorf("changed %+v, want the station's new name", comparison.Changed)
+	}
+
+	// The exported baseline imports like a line export and holds the state it was taken in.
+	filePath := filepath.Join(t.TempDir(), "baseline.json")
+	if err := c.ExportLineBaselineToJSON("jdoe", baseline.ID.String(), filePath); err != nil {
+		t.Fatalf("baseline export returned %v", err)
+	}
+	target := newTestCore(t)
+	if err := target.ImportEntityHierarchyFromJSON("jdoe", filePath, "", ImportModeOriginal); err != nil {
+		t.Fatalf("import of the baseline returned %v", err)
+	}
+	var imported Station
+	if err := target.DB.Preload("Tools.Operations").First(&imported, "id = ?", station.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if derefOrEmpty(imported.Name) != "S1" || len(imported.Tools) != 1 || len(imported.Tools[0].Operations) != 1 {
+		t.Errorf("imported baseline station %q with tools %+v, want S1 with one tool and operation", derefOrEmpty(imported.Name), imported.Tools)
+	}
+}
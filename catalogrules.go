@@ -0,0 +1,369 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"gorm.io/gorm"
+)
+
+// catalogRuleFields are the update fields that take part in a dependency catalog rule.
+var catalogRuleFields = map[string]bool{
+	"Template": true, "DecisionClass": true, "GenerationClass": true, "SavingClass": true,
+	"VerificationClass": true, "SerialOrParallel": true, "ToolClass": true, "ToolType": true,
+	"StationType": true,
+}
+
+// catalogValue returns a catalog ID, treating "none" like an empty value.
+func catalogValue(value *string) string {
+	v := derefOrEmpty(value)
+	if v == "none" {
+		return ""
+	}
+	return v
+}
+
+// templateClassAllowed reports whether the class list has an entry with the given ID for the template.
+func templateClassAllowed(entries []CatalogClass, id string, templateID string) bool {
+	for _, entry := range entries {
+		if entry.ID != id {
+			continue
+		}
+		if entry.TemplateID == templateID || containsString(entry.TemplateIDs, templateID) {
+			return true
+		}
+	}
+	return false
+}
+
+// operationCatalogViolations returns one message per dependency catalog rule the operation breaks.
+func operationCatalogViolations(data *Data, op Operation, tool *Tool, station *Station) []string {
+	var violations []string
+	template := catalogValue(op.Template)
+	if template != "" {
+		toolClass := derefOrEmpty(tool.ToolClass)
+		found := false
+		for _, tc := range data.ToolClasses {
+			if tc.ID == toolClass {
+				found = true
+				if !containsString(tc.TemplateIDs, template) {
+					violations = append(violations, fmt.Sprintf("template '%s' is not compatible with tool class '%s'", template, toolClass))
+				}
+				break
+			}
+		}
+		if !found {
+			violations = append(violations, fmt.Sprintf("tool class '%s' not found in dependency data", toolClass))
+		}
+	}
+
+	classes := []struct {
+		label   string
+		value   string
+		entries []CatalogClass
+	}{
+		{"decision class", catalogValue(op.DecisionClass), data.DecisionClasses},
+		{"generation class", catalogValue(op.GenerationClass), data.GenerationClasses},
+		{"saving class", catalogValue(op.SavingClass), data.SavingClasses},
+		{"verification class", catalogValue(op.VerificationClass), data.VerificationClasses},
+	}
+	for _, class := range classes {
+		if class.value == "" {
+			continue
+		}
+		if template == "" {
+			violations = append(violations, fmt.Sprintf("%s '%s' requires a template", class.label, class.value))
+		} else if !templateClassAllowed(class.entries, class.value, template) {
+			violations = append(violations, fmt.Sprintf("%s '%s' is not allowed for template '%s'", class.label, class.value, template))
+		}
+	}
+
+	if sop := catalogValue(op.SerialOrParallel); sop != "" {
+		stationType := derefOrEmpty(station.StationType)
+		found := false
+		for _, st := range data.StationTypes {
+			if st.ID == stationType {
+				found = true
+				if !containsString(st.SOP, sop) {
+					violations = append(violations, fmt.Sprintf("serial or parallel id '%s' is not compatible with station type '%s'", sop, stationType))
+				}
+				break
+			}
+		}
+		if !found {
+			violations = append(violations, fmt.Sprintf("station type id '%s' not found in dependency data", stationType))
+		}
+	}
+	return violations
+}
+
+// toolCatalogViolations returns one message per dependency catalog rule the tool breaks.
+func toolCatalogViolations(data *Data, tool *Tool) []string {
+	toolType := catalogValue(tool.ToolType)
+	if toolType == "" {
+		return nil
+	}
+	toolClass := catalogValue(tool.ToolClass)
+	if toolClass == "" {
+		return []string{fmt.Sprintf("tool type '%s' requires a tool class", toolType)}
+	}
+	for _, tt := range data.ToolTypes {
+		if tt.ID == toolType {
+			if tt.ToolClassID != toolClass {
+				return []string{fmt.Sprintf("tool type '%s' does not belong to tool class '%s'", toolType, toolClass)}
+			}
+			return nil
+		}
+	}
+	return []string{fmt.Sprintf("tool type '%s' not found in dependency data", toolType)}
+}
+
+func catalogViolationError(entityType string, name *string, violations []string) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	label := entityType
+	if n := derefOrEmpty(name); n != "" {
+		label = fmt.Sprintf("%s '%s'", entityType, n)
+	}
+	return fmt.Errorf("%s: %s", label, strings.Join(violations, "; "))
+}
+
+// checkOperationCompatibility checks an operation against every dependency catalog rule:
+// template vs tool class, the classes vs template and serial/parallel vs station type.
+func checkOperationCompatibility(data *Data, op Operation, tool *Tool, station *Station) error {
+	return catalogViolationError("operation", op.Name, operationCatalogViolations(data, op, tool, station))
+}
+
+// checkToolCompatibility checks the tool type of a tool against its tool class.
+func checkToolCompatibility(data *Data, tool *Tool) error {
+	return catalogViolationError("tool", tool.Name, toolCatalogViolations(data, tool))
+}
+
+// checkHierarchyCompatibility checks every tool and operation of an in-memory hierarchy.
+// tool and station are the context of a tool or operation root and unused otherwise.
+func checkHierarchyCompatibility(data *Data, root interface{}, tool *Tool, station *Station) error {
+	var errs []error
+	checkTool := func(t *Tool, s *Station) {
+		if err := checkToolCompatibility(data, t); err != nil {
+			errs = append(errs, err)
+		}
+		for i := range t.Operations {
+			if err := checkOperationCompatibility(data, t.Operations[i], t, s); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	checkStation := func(s *Station) {
+		for i := range s.Tools {
+			checkTool(&s.Tools[i], s)
+		}
+	}
+	switch e := root.(type) {
+	case *Line:
+		for i := range e.Stations {
+			checkStation(&e.Stations[i])
+		}
+	case *Station:
+		checkStation(e)
+	case *Tool:
+		checkTool(e, station)
+	case *Operation:
+		if err := checkOperationCompatibility(data, *e, tool, station); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// hasCatalogValues reports whether a tool or operation has any value a catalog rule applies to.
+func hasCatalogValues(entity interface{}) bool {
+	switch e := entity.(type) {
+	case *Tool:
+		return catalogValue(e.ToolType) != ""
+	case *Operation:
+		for _, value := range []*string{e.Template, e.DecisionClass, e.GenerationClass, e.SavingClass, e.VerificationClass, e.SerialOrParallel} {
+			if catalogValue(value) != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadOperationContext loads the tool of an operation and the station of that tool.
+func loadOperationContext(db *gorm.DB, toolID mssql.UniqueIdentifier) (*Tool, *Station, error) {
+	var tool Tool
+	if err := db.First(&tool, "id = ?", toolID).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to load tool %s: %w", toolID.String(), err)
+	}
+	var station Station
+	if err := db.First(&station, "id = ?", tool.ParentID).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to load station %s: %w", tool.ParentID.String(), err)
+	}
+	return &tool, &station, nil
+}
+
+// checkEntityCatalog checks a stored tool or operation against the dependency catalog.
+func (c *Core) checkEntityCatalog(db *gorm.DB, entity interface{}) error {
+	if !hasCatalogValues(entity) {
+		return nil
+	}
+	data, err := c.loadDependencyData()
+	if err != nil {
+		return err
+	}
+	switch e := entity.(type) {
+	case *Tool:
+		return checkToolCompatibility(data, e)
+	case *Operation:
+		tool, station, err := loadOperationContext(db, e.ParentID)
+		if err != nil {
+			return err
+		}
+		return checkOperationCompatibility(data, *e, tool, station)
+	}
+	return nil
+}
+
+// checkUpdatedEntityCatalog checks an updated entity against the dependency catalog. The station type
+// and the tool class constrain the operations below them, so stations and tools are checked with their subtree.
+func (c *Core) checkUpdatedEntityCatalog(db *gorm.DB, entity interface{}) error {
+	var root interface{}
+	var station *Station
+	switch e := entity.(type) {
+	case *Station:
+		s := *e
+		if err := db.Preload("Operations").Where("parent_id = ?", e.ID).Find(&s.Tools).Error; err != nil {
+			return fmt.Errorf("failed to load tools of station %s: %w", e.ID.String(), err)
+		}
+		root = &s
+	case *Tool:
+		t := *e
+		if err := db.Where("parent_id = ?", e.ID).Find(&t.Operations).Error; err != nil {
+			return fmt.Errorf("failed to load operations of tool %s: %w", e.ID.String(), err)
+		}
+		station = &Station{}
+		if err := db.First(station, "id = ?", e.ParentID).Error; err != nil {
+			return fmt.Errorf("failed to load station %s: %w", e.ParentID.String(), err)
+		}
+		root = &t
+	default:
+		return c.checkEntityCatalog(db, entity)
+	}
+	data, err := c.loadDependencyData()
+	if err != nil {
+		return err
+	}
+	if err := checkHierarchyCompatibility(data, root, nil, station); err != nil {
+		return fmt.Errorf("dependency catalog violations: %w", err)
+	}
+	return nil
+}
+
+// resetDependentOperations clears the operation fields a new tool class or station type makes invalid,
+// so the subtree check of the tool or station passes: the template and its classes of operations whose
+// template does not fit the tool class, and serial/parallel and the sequence of operations whose
+// serial/parallel the station type does not allow. Every reset operation gets a version and a change log entry.
+func (c *Core) resetDependentOperations(tx *gorm.DB, userName string, entity interface{}) error {
+	data, err := c.loadDependencyData()
+	if err != nil {
+		return err
+	}
+	var ops []Operation
+	var resets func(op *Operation) map[string]string
+	switch e := entity.(type) {
+	case *Tool:
+		if err := tx.Where("parent_id = ?", e.ID).Find(&ops).Error; err != nil {
+			return fmt.Errorf("failed to load operations of tool %s: %w", e.ID.String(), err)
+		}
+		var templates []string
+		for _, tc := range data.ToolClasses {
+			if tc.ID == derefOrEmpty(e.ToolClass) {
+				templates = tc.TemplateIDs
+			}
+		}
+		resets = func(op *Operation) map[string]string {
+			if template := catalogValue(op.Template); template == "" || containsString(templates, template) {
+				return nil
+			}
+			return map[string]string{"Template": "none", "DecisionClass": "none", "VerificationClass": "none", "GenerationClass": "none", "SavingClass": "none"}
+		}
+	case *Station:
+		var toolIDs []mssql.UniqueIdentifier
+		if err := tx.Model(&Tool{}).Where("parent_id = ?", e.ID).Pluck("id", &toolIDs).Error; err != nil {
+			return fmt.Errorf("failed to load tools of station %s: %w", e.ID.String(), err)
+		}
+		if len(toolIDs) > 0 {
+			if err := tx.Where("parent_id IN ?", toolIDs).Find(&ops).Error; err != nil {
+				return fmt.Errorf("failed to load operations of station %s: %w", e.ID.String(), err)
+			}
+		}
+		var sops []string
+		for _, st := range data.StationTypes {
+			if st.ID == derefOrEmpty(e.StationType) {
+				sops = st.SOP
+			}
+		}
+		resets = func(op *Operation) map[string]string {
+			if sop := catalogValue(op.SerialOrParallel); sop == "" || containsString(sops, sop) {
+				return nil
+			}
+			return map[string]string{"SerialOrParallel": "none", "SequenceGroup": "", "Sequence": "", "GroupID": ""}
+		}
+	default:
+		return nil
+	}
+
+	for i := range ops {
+		updates := resets(&ops[i])
+		if len(updates) == 0 {
+			continue
+		}
+		if err := createVersion(tx, "operation", &ops[i]); err != nil {
+			return fmt.Errorf("failed to create entity version: %w", err)
+		}
+		fieldChanges := buildFieldChanges(&ops[i], updates)
+		gormUpdates := make(map[string]interface{}, len(updates)+2)
+		for field, value := range updates {
+			gormUpdates[field] = fieldUpdateValue("operation", field, value)
+		}
+		gormUpdates["updated_by"] = strPtr(userName)
+		gormUpdates["updated_at"] = time.Now()
+		if err := tx.Model(&Operation{}).Where("id = ?", ops[i].ID).Updates(gormUpdates).Error; err != nil {
+			return fmt.Errorf("failed to reset operation %s: %w", ops[i].ID.String(), err)
+		}
+		if err := updateGlobalLastUpdateTimestampAndLogChange(tx, ops[i].ID, "operation", OpTypeUpdate, strPtr(userName), fieldChanges); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkImportCatalog checks an imported or pasted hierarchy before it is written.
+func (c *Core) checkImportCatalog(db *gorm.DB, root interface{}, rootType string, parentID mssql.UniqueIdentifier) error {
+	data, err := c.loadDependencyData()
+	if err != nil {
+		return err
+	}
+	var tool *Tool
+	var station *Station
+	switch strings.ToLower(rootType) {
+	case "tool":
+		station = &Station{}
+		if err := db.First(station, "id = ?", parentID).Error; err != nil {
+			return fmt.Errorf("failed to load station %s: %w", parentID.String(), err)
+		}
+	case "operation":
+		if tool, station, err = loadOperationContext(db, parentID); err != nil {
+			return err
+		}
+	}
+	if err := checkHierarchyCompatibility(data, root, tool, station); err != nil {
+		return fmt.Errorf("dependency catalog violations: %w", err)
+	}
+	return nil
+}
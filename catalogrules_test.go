@@ -0,0 +1,212 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCatalog is a small dependency catalog: tool class 1 takes template 1 and tool class 2 template 2.
+var testCatalog = &Data{
+	Templates: []Template{{ID: "1"}, {ID: "2"}},
+	ToolClasses: []ToolClass{
+		{ID: "1", TemplateIDs: []string{"1"}},
+		{ID: "2", TemplateIDs: []string{"2"}},
+	},
+	ToolTypes: []ToolType{
+		{ID: "10", ToolClassID: "1"},
+		{ID: "20", ToolClassID: "2"},
+	},
+	StationTypes: []StationType{
+		{ID: "0", SOP: []string{"0"}},
+		{ID: "1", SOP: []string{"0", "1"}},
+	},
+	DecisionClasses: []CatalogClass{
+		{ID: "1", TemplateIDs: []string{"1", "2"}},
+		{ID: "2", TemplateIDs: []string{"2"}},
+	},
+	GenerationClasses: []CatalogClass{
+		{ID: "1", TemplateID: "1"},
+		{ID: "2", TemplateID: "2"},
+	},
+	SavingClasses:       []CatalogClass{{ID: "1", TemplateID: "1"}},
+	VerificationClasses: []CatalogClass{{ID: "0", TemplateID: "1"}},
+	SerialOrParallel:    []CatalogClass{{ID: "0"}, {ID: "1"}},
+}
+
+func TestOperationCatalogViolations(t *testing.T) {
+	tests := []struct {
+		name        string
+		op          Operation
+		toolClass   string
+		stationType string
+		want        []string
+	}{
+		{name: "no catalog values", op: Operation{}, toolClass: "1", stationType: "1"},
+		{name: "none values", op: Operation{Template: strPtr("none"), DecisionClass: strPtr("none"), SerialOrParallel: strPtr("none")}, toolClass: "1", stationType: "1"},
+		{
+			name:      "compatible operation",
+			op:        Operation{Template: strPtr("1"), DecisionClass: strPtr("1"), GenerationClass: strPtr("1"), SavingClass: strPtr("1"), VerificationClass: strPtr("0"), SerialOrParallel: strPtr("1")},
+			toolClass: "1", stationType: "1",
+		},
+		{
+			name:      "template of another tool class",
+			op:        Operation{Template: strPtr("2")},
+			toolClass: "1", stationType: "1",
+			want: []string{"template '2' is not compatible with tool class '1'"},
+		},
+		{
+			name:      "unknown tool class",
+			op:        Operation{Template: strPtr("1")},
+			toolClass: "9", stationType: "1",
+			want: []string{"tool class '9' not found in dependency data"},
+		},
+		{
+			name:      "classes of another template",
+			op:        Operation{Template: strPtr("1"), DecisionClass: strPtr("2"), GenerationClass: strPtr("2")},
+			toolClass: "1", stationType: "1",
+			want: []string{
+				"decision class '2' is not allowed for template '1'",
+				"generation class '2' is not allowed for template '1'",
+			},
+		},
+		{
+			name:      "class without template",
+			op:        Operation{SavingClass: strPtr("1")},
+			toolClass: "1", stationType: "1",
+			want: []string{"saving class '1' requires a template"},
+		},
+		{
+			name:      "serial or parallel not allowed for the station type",
+			op:        Operation{SerialOrParallel: strPtr("1")},
+			toolClass: "1", stationType: "0",
+			want: []string{"serial or parallel id '1' is not compatible with station type '0'"},
+		},
+		{
+			name:      "serial or parallel without station type",
+			op:        Operation{SerialOrParallel: strPtr("0")},
+			toolClass: "1", stationType: "",
+			want: []string{"station type id '' not found in dependency data"},
+		},
+		{
+			name:      "several violations",
+			op:        Operation{Template: strPtr("2"), VerificationClass: strPtr("0")},
+			toolClass: "1", stationType: "1",
+			want: []string{
+				"template '2' is not compatible with tool class '1'",
+				"verification class '0' is not allowed for template '2'",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tool := &Tool{ToolClass: strPtr(tt.toolClass)}
+		station := &Station{StationType: strPtr(tt.stationType)}
+		got := operationCatalogViolations(testCatalog, tt.op, tool, station)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: operationCatalogViolations = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestToolCatalogViolations(t *testing.T) {
+	tests := []struct {
+		name      string
+		toolClass *string
+		toolType  *string
+		want      []string
+	}{
+		{name: "no values"},
+		{name: "tool class only", toolClass: strPtr("1")},
+		{name: "tool type none", toolClass: strPtr("1"), toolType: strPtr("none")},
+		{name: "matching tool type", toolClass: strPtr("1"), toolType: strPtr("10")},
+		{name: "tool type of another class", toolClass: strPtr("2"), toolType: strPtr("10"), want: []string{"tool type '10' does not belong to tool class '2'"}},
+		{name: "tool type without class", toolType: strPtr("10"), want: []string{"tool type '10' requires a tool class"}},
+		{name: "tool type with class none", toolClass: strPtr("none"), toolType: strPtr("20"), want: []string{"tool type '20' requires a tool class"}},
+		{name: "unknown tool type", toolClass: strPtr("1"), toolType: strPtr("99"), want: []string{"tool type '99' not found in dependency data"}},
+	}
+	for _, tt := range tests {
+		got := toolCatalogViolations(testCatalog, &Tool{ToolClass: tt.toolClass, ToolType: tt.toolType})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: toolCatalogViolations = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckHierarchyCompatibilityStationType(t *testing.T) {
+	station := &Station{
+		StationType: strPtr("1"),
+		Tools: []Tool{{
+			BaseModel: BaseModel{Name: strPtr("T1")},
+			ToolClass: strPtr("1"),
+			Operations: []Operation{
+				{BaseModel: BaseModel{Name: strPtr("O1")}, Template: strPtr("1"), SerialOrParallel: strPtr("1")},
+			},
+		}},
+	}
+	if err := checkHierarchyCompatibility(testCatalog, station, nil, nil); err != nil {
+		t.Fatalf("valid station reported %v", err)
+	}
+	// The operations of the station must be checked against a changed station type.
+	station.StationType = strPtr("0")
+	err := checkHierarchyCompatibility(testCatalog, station, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "operation 'O1'") {
+		t.Errorf("station type change reported %v, want a violation of operation 'O1'", err)
+	}
+}
+
+func TestToolClassChangeResetsOperations(t *testing.T) {
+	c := newTestCore(t)
+	_, _, tool, op := createTestLine(t, c.DB)
+	if err := c.DB.Model(op).Updates(map[string]interface{}{"decision_class": "1", "saving_class": "1"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UpdateEntityFieldsString("jdoe", "tool", tool.ID.String(), time.Now().Format(time.RFC3339Nano), map[string]string{"ToolClass": "2"}); err != nil {
+		t.Fatalf("tool class change returned %v", err)
+	}
+	var reset Operation
+	if err := c.DB.First(&reset, "id = ?", op.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	for field, value := range map[string]*string{"Template": reset.Template, "DecisionClass": reset.DecisionClass, "SavingClass": reset.SavingClass} {
+		if derefOrEmpty(value) != "none" {
+			t.Errorf("%s of the operation is %q after the tool class change, want none", field, derefOrEmpty(value))
+		}
+	}
+	var versions int64
+	c.DB.Model(&OperationHistory{}).Where("entity_id = ?", op.ID).Count(&versions)
+	if versions != 1 {
+		t.Errorf("operation has %d versions after its reset, want 1", versions)
+	}
+}
+
+func TestStationTypeChangeResetsOperations(t *testing.T) {
+	c := newTestCore(t)
+	_, station, tool, op := createTestLine(t, c.DB)
+	group := &SequenceGroup{BaseModel: BaseModel{Name: strPtr("G1")}, ParentID: station.ID}
+	if err := c.DB.Create(group).Error; err != nil {
+		t.Fatal(err)
+	}
+	// This operation's serial or parallel value fits both station types and is kept.
+	kept := &Operation{BaseModel: BaseModel{Name: strPtr("O2")}, SerialOrParallel: strPtr("0"), ParentID: tool.ID}
+	if err := c.DB.Create(kept).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DB.Model(op).Updates(map[string]interface{}{"group_id": group.ID, "sequence": "1"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UpdateEntityFieldsString("jdoe", "station", station.ID.String(), time.Now().Format(time.RFC3339Nano), map[string]string{"StationType": "0"}); err != nil {
+		t.Fatalf("station type change returned %v", err)
+	}
+	var ops []Operation
+	if err := c.DB.Order("name").Find(&ops).Error; err != nil {
+		t.Fatal(err)
+	}
+	if derefOrEmpty(ops[0].SerialOrParallel) != "none" || ops[0].GroupID != nil || derefOrEmpty(ops[0].Sequence) != "" {
+		t.Errorf("operation not fitting the station type kept serial or parallel %q, group %v, sequence %q",
+			derefOrEmpty(ops[0].SerialOrParallel), ops[0].GroupID, derefOrEmpty(ops[0].Sequence))
+	}
+	if derefOrEmpty(ops[1].SerialOrParallel) != "0" {
+		t.Errorf("operation fitting the station type has serial or parallel %q, want 0", derefOrEmpty(ops[1].SerialOrParallel))
+	}
+}
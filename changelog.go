@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	ws "github.com/wailsapp/wails/v2/pkg/runtime"
+	"gorm.io/gorm"
+)
+
+const defaultChangeLogPageSize = 100
+const maxChangeLogPageSize = 1000
+
+// ChangeLogFilter selects EntityChangeLog entries. Empty fields do not restrict the result.
+type ChangeLogFilter struct {
+	UserID        string `json:"userId"`
+	EntityType    string `json:"entityType"`
+	OperationType string `json:"operationType"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+	// SubtreeType and SubtreeID restrict the result to an entity and everything below it.
+	SubtreeType string `json:"subtreeType"`
+	SubtreeID   string `json:"subtreeId"`
+	// Ascending sorts oldest first; the default is newest first.
+	Ascending bool   `json:"ascending"`
+	Cursor    string `json:"cursor"`
+	Limit     int    `json:"limit"`
+}
+
+type ChangeLogEntry struct {
+	LogID         string                 `json:"logId"`
+	EntityID      string                 `json:"entityId"`
+	EntityType    string                 `json:"entityType"`
+	EntityName    *string                `json:"entityName"`
+	EntityPath    string                 `json:"entityPath"`
+	OperationType string                 `json:"operationType"`
+	ChangeTime    time.Time              `json:"changeTime"`
+	ChangedBy     *string                `json:"changedBy"`
+	ParentID      *string                `json:"parentId"`
+	ParentType    *string                `json:"parentType"`
+	Changes       map[string]FieldChange `json:"changes"`
+}
+
+type ChangeLogPage struct {
+	Entries    []ChangeLogEntry `json:"entries"`
+	NextCursor string           `json:"nextCursor"`
+}
+
+func encodeChangeLogCursor(lg EntityChangeLog) string {
+	raw := lg.ChangeTime.Format(time.RFC3339Nano) + "|" + lg.LogID.String()
+	return base64.URLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeChangeLogCursor(cursor string) (time.Time, mssql.UniqueIdentifier, error) {
+	var logID mssql.UniqueIdentifier
+	raw, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, logID, fmt.Errorf("invalid cursor: %w", err)
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, logID, errors.New("invalid cursor")
+	}
+	changeTime, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, logID, fmt.Errorf("invalid cursor time: %w", err)
+	}
+	logID, err = parseMSSQLUniqueIdentifierFromString(parts[1])
+	if err != nil {
+		return time.Time{}, logID, fmt.Errorf("invalid cursor ID: %w", err)
+	}
+	return changeTime, logID, nil
+}
+
+// buildChangeLogQuery applies every filter criterion except cursor and limit.
+func buildChangeLogQuery(db *gorm.DB, filter ChangeLogFilter) (*gorm.DB, error) {
+	query := db.Model(&EntityChangeLog{})
+	if filter.UserID != "" {
+		query = query.Where("changed_by_user_id = ?", filter.UserID)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", strings.ToLower(filter.EntityType))
+	}
+	if filter.OperationType != "" {
+		query = query.Where("operation_type = ?", strings.ToUpper(filter.OperationType))
+	}
+	if filter.From != "" {
+		from, err := parseTimestampFlexible(filter.From)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from timestamp: %w", err)
+		}
+		query = query.Where("change_time >= ?", from)
+	}
+	if filter.To != "" {
+		to, err := parseTimestampFlexible(filter.To)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to timestamp: %w", err)
+		}
+		query = query.Where("change_time <= ?", to)
+	}
+	if filter.SubtreeID != "" {
+		if filter.SubtreeType == "" {
+			return nil, errors.New("subtreeType is required together with subtreeId")
+		}
+		subtreeID, err := parseMSSQLUniqueIdentifierFromString(filter.SubtreeID)
+		if err != nil {
+			return nil, err
+		}
+		// Soft-deleted children still belong to the subtree's audit trail.
+		idsByType, err := collectChildIDs(db.Unscoped().Session(&gorm.Session{}), strings.ToLower(filter.SubtreeType), subtreeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to collect subtree IDs: %w", err)
+		}
+		var ids []string
+		for _, typeIDs := range idsByType {
+			ids = append(ids, typeIDs...)
+		}
+		// Large lines exceed the SQL Server parameter limit, so the IDs are passed as one JSON array.
+		idsJSON, _ := json.Marshal(ids)
+		query = query.Where("entity_id IN (SELECT CAST(value AS uniqueidentifier) FROM OPENJSON(?))", string(idsJSON))
+	}
+	return query, nil
+}
+
+type entityNode struct {
+	name       *string
+	parentID   string
+	parentType string
+}
+
+// loadEntityNodes loads name and parent of the given entities. Entities that no longer exist
+// are described by their newest history snapshot.
+func loadEntityNodes(db *gorm.DB, entityType string, ids []string) map[string]entityNode {
+	nodes := make(map[string]entityNode)
+	model, err := getModelInstance(entityType)
+	if err != nil || len(ids) == 0 {
+		return nodes
+	}
+	parentType := parentEntityType(entityType)
+	columns := "id, name, parent_id"
+	historyColumns := "entity_id AS id, name, parent_id"
+	if parentType == "" {
+		columns = "id, name"
+		historyColumns = "entity_id AS id, name"
+	}
+	type nodeRow struct {
+		ID       mssql.UniqueIdentifier
+		Name     *string
+		ParentID *mssql.UniqueIdentifier
+	}
+	toNode := func(r nodeRow) entityNode {
+		node := entityNode{name: r.Name}
+		if r.ParentID != nil {
+			node.parentID = r.ParentID.String()
+			node.parentType = parentType
+		}
+		return node
+	}
+	for _, chunk := range chunkIDs(ids, 1000) {
+		var rows []nodeRow
+		if err := db.Unscoped().Model(model).Select(columns).Where("id IN ?", chunk).Scan(&rows).Error; err == nil {
+			for _, r := range rows {
+				nodes[r.ID.String()] = toNode(r)
+			}
+		}
+
+		historyModel, err := getHistoryModelInstance(entityType)
+		if err != nil {
+			continue
+		}
+		var missing []string
+		for _, id := range chunk {
+			if _, ok := nodes[id]; !ok {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		var historyRows []nodeRow
+		if err := db.Model(historyModel).Select(historyColumns).Where("entity_id IN ?", missing).Order("version asc").Scan(&historyRows).Error; err == nil {
+			for _, r := range historyRows {
+				nodes[r.ID.String()] = toNode(r)
+			}
+		}
+	}
+	return nodes
+}
+
+// resolveEntityPaths loads the given entities and all their ancestors and returns
+// the names of the entities together with their "Line / Station / Tool / Operation" paths.
+func resolveEntityPaths(db *gorm.DB, idsByType map[string][]string) (map[string]*string, map[string]string) {
+	nodes := make(map[string]entityNode)
+	pending := idsByType
+	for len(pending) > 0 {
+		next := make(map[string][]string)
+		for entityType, ids := range pending {
+			for id, node := range loadEntityNodes(db, entityType, ids) {
+				nodes[id] = node
+				if node.parentType != "" {
+					if _, known := nodes[node.parentID]; !known {
+						next[node.parentType] = append(next[node.parentType], node.parentID)
+					}
+				}
+			}
+		}
+		pending = next
+	}
+
+	names := make(map[string]*string)
+	paths := make(map[string]string)
+	for _, ids := range idsByType {
+		for _, id := range ids {
+			node, ok := nodes[id]
+			if !ok {
+				continue
+			}
+			names[id] = node.name
+			var segments []string
+			currentID := id
+			for {
+				current, found := nodes[currentID]
+				if !found {
+					break
+				}
+				segment := currentID
+				if current.name != nil && *current.name != "" {
+					segment = *current.name
+				}
+				segments = append([]string{segment}, segments...)
+				if current.parentID == "" {
+					break
+				}
+				currentID = current.parentID
+			}
+			paths[id] = strings.Join(segments, " / ")
+		}
+	}
+	return names, paths
+}
+
+func toChangeLogEntries(db *gorm.DB, logs []EntityChangeLog) []ChangeLogEntry {
+	idsByType := make(map[string][]string)
+	for _, lg := range logs {
+		if lg.EntityType != "system" {
+			idsByType[lg.EntityType] = append(idsByType[lg.EntityType], lg.EntityID.String())
+		}
+	}
+	names, paths := resolveEntityPaths(db, idsByType)
+
+	entries := make([]ChangeLogEntry, 0, len(logs))
+	for _, lg := range logs {
+		entry := ChangeLogEntry{
+			LogID:         lg.LogID.String(),
+			EntityID:      lg.EntityID.String(),
+			EntityType:    lg.EntityType,
+			EntityName:    names[lg.EntityID.String()],
+			EntityPath:    paths[lg.EntityID.String()],
+			OperationType: lg.OperationType,
+			ChangeTime:    lg.ChangeTime,
+			ChangedBy:     lg.ChangedByUserID,
+			ParentType:    lg.ParentType,
+			Changes:       decodeChangedFields(lg.ChangedFields),
+		}
+		if lg.ParentID != nil {
+			entry.ParentID = strPtr(lg.ParentID.String())
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// QueryChangeLog returns one page of the audit trail. Pass the returned NextCursor in the next
+// filter to continue; an empty NextCursor means there are no further entries.
+func (c *Core) QueryChangeLog(filter ChangeLogFilter) (*ChangeLogPage, error) {
+	if c.DB == nil {
+		return nil, errors.New("DB not initialized")
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultChangeLogPageSize
+	}
+	if limit > maxChangeLogPageSize {
+		limit = maxChangeLogPageSize
+	}
+
+	query, err := buildChangeLogQuery(c.DB, filter)
+	if err != nil {
+		return nil, err
+	}
+	order, cmp := "desc", "<"
+	if filter.Ascending {
+		order, cmp = "asc", ">"
+	}
+	if filter.Cursor != "" {
+		cursorTime, cursorID, err := decodeChangeLogCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(fmt.Sprintf("(change_time %s ?) OR (change_time = ? AND log_id %s ?)", cmp, cmp), cursorTime, cursorTime, cursorID)
+	}
+
+	var logs []EntityChangeLog
+	if err := query.Order("change_time " + order).Order("log_id " + order).Limit(limit + 1).Find(&logs).Error; err != nil {
+		return nil, fmt.Errorf("failed to query change log: %w", err)
+	}
+
+	page := &ChangeLogPage{}
+	if len(logs) > limit {
+		logs = logs[:limit]
+		page.NextCursor = encodeChangeLogCursor(logs[len(logs)-1])
+	}
+	page.Entries = toChangeLogEntries(c.DB, logs)
+	return page, nil
+}
+
+func (c *Core) HandleChangeLogExport(filter ChangeLogFilter, format string) string {
+	format = strings.ToLower(format)
+	fileFilter := ws.FileFilter{DisplayName: "CSV", Pattern: "*.csv"}
+	if format == "jsonl" {
+		fileFilter = ws.FileFilter{DisplayName: "JSON Lines", Pattern: "*.jsonl"}
+	}
+	file, _ := ws.SaveFileDialog(c.ctx, ws.SaveDialogOptions{
+		DefaultFilename: fmt.Sprintf("changelog_%s.%s", time.Now().Format("20060102_150405"), format),
+		Title:           "Export",
+		Filters: []ws.FileFilter{
+			fileFilter,
+			{DisplayName: "*", Pattern: "*.*"},
+		},
+	})
+	err := c.ExportChangeLog(filter, format, file)
+	if err != nil {
+		return "ExportError"
+	} else {
+		return "ExportSuccess"
+	}
+}
+
+var changeLogCSVHeader = []string{"ChangeTime", "OperationType", "EntityType", "EntityID", "EntityPath", "ChangedBy", "Field", "OldValue", "NewValue"}
+
+// ExportChangeLog writes all change log entries matching the filter to a CSV or JSON Lines file.
+// CSV files contain one row per changed field, JSON Lines files one object per log entry.
+func (c *Core) ExportChangeLog(filter ChangeLogFilter, format string, filePath string) (err error) {
+	if c.DB == nil {
+		return errors.New("DB not initialized")
+	}
+	if filePath == "" {
+		return errors.New("export filePath is empty")
+	}
+	format = strings.ToLower(format)
+	if format != "csv" && format != "jsonl" {
+		return fmt.Errorf("unsupported change log export format: %s", format)
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("error creating file '%s': %w", filePath, err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("error closing file '%s': %w", filePath, closeErr)
+		}
+	}()
+	buffered := bufio.NewWriter(file)
+	csvWriter := csv.NewWriter(buffered)
+	jsonEncoder := json.NewEncoder(buffered)
+	if format == "csv" {
+		if err := csvWriter.Write(changeLogCSVHeader); err != nil {
+			return fmt.Errorf("error writing CSV header: %w", err)
+		}
+	}
+
+	filter.Cursor = ""
+	filter.Limit = maxChangeLogPageSize
+	written := 0
+	for {
+		page, err := c.QueryChangeLog(filter)
+		if err != nil {
+			return err
+		}
+		for _, entry := range page.Entries {
+			if format == "jsonl" {
+				if err := jsonEncoder.Encode(entry); err != nil {
+					return fmt.Errorf("error writing JSON line: %w", err)
+				}
+			} else if err := writeChangeLogCSVRows(csvWriter, entry); err != nil {
+				return fmt.Errorf("error writing CSV row: %w", err)
+			}
+			written++
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return fmt.Errorf("error writing CSV: %w", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("error writing file '%s': %w", filePath, err)
+	}
+	log.Printf("%d change log entries exported to '%s'.", written, filePath)
+	return nil
+}
+
+func writeChangeLogCSVRows(w *csv.Writer, entry ChangeLogEntry) error {
+	base := []string{
+		entry.ChangeTime.Format(time.RFC3339),
+		entry.OperationType,
+		entry.EntityType,
+		entry.EntityID,
+		entry.EntityPath,
+		derefOrEmpty(entry.ChangedBy),
+	}
+	if len(entry.Changes) == 0 {
+		return w.Write(append(base, "", "", ""))
+	}
+	fields := make([]string, 0, len(entry.Changes))
+	for field := range entry.Changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		change := entry.Changes[field]
+		row := append(append([]string{}, base...), field, derefOrEmpty(change.Old), derefOrEmpty(change.New))
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChangeLogCursor(t *testing.T) {
+	logID, err := parseMSSQLUniqueIdentifierFromString("6F9619FF-8B86-D011-B42D-00C04FC964FF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		changeTime time.Time
+	}{
+		{name: "UTC", changeTime: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)},
+		{name: "nanoseconds", changeTime: time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC)},
+		{name: "other zone", changeTime: time.Date(2024, 10, 27, 2, 30, 0, 5000, time.FixedZone("CEST", 2*60*60))},
+	}
+	for _, tt := range tests {
+		cursor := encodeChangeLogCursor(EntityChangeLog{LogID: logID, ChangeTime: tt.changeTime})
+		gotTime, gotID, err := decodeChangeLogCursor(cursor)
+		if err != nil {
+			t.Errorf("%s: decodeChangeLogCursor(%q) returned %v", tt.name, cursor, err)
+			continue
+		}
+		if !gotTime.Equal(tt.changeTime) {
+			t.Errorf("%s: time %s, want %s", tt.name, gotTime, tt.changeTime)
+		}
+		if gotID != logID {
+			t.Errorf("%s: log ID %s, want %s", tt.name, gotID, logID)
+		}
+	}
+}
+
+func TestDecodeChangeLogCursorErrors(t *testing.T) {
+	encode := func(raw string) string { return base64.URLEncoding.EncodeToString([]byte(raw)) }
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "empty", cursor: ""},
+		{name: "not base64", cursor: "not a cursor!"},
+		{name: "no separator", cursor: encode("2024-03-01T12:30:00Z")},
+		{name: "invalid time", cursor: encode("yesterday|6F9619FF-8B86-D011-B42D-00C04FC964FF")},
+		{name: "invalid ID", cursor: encode("2024-03-01T12:30:00Z|not-an-id")},
+		{name: "empty ID", cursor: encode("2024-03-01T12:30:00Z|")},
+	}
+	for _, tt := range tests {
+		if _, _, err := decodeChangeLogCursor(tt.cursor); err == nil {
+			t.Errorf("%s: decodeChangeLogCursor(%q) returned no error", tt.name, tt.cursor)
+		}
+	}
+}
+
+func TestChangesSinceReportsCreatedEntities(t *testing.T) {
+	c := newTestCore(t)
+	line, station, _, _ := createTestLine(t, c.DB)
+	since := time.Now().Format(time.RFC3339Nano)
+	time.Sleep(10 * time.Millisecond)
+
+	tool, err := c.CreateEntity("jdoe", "tool", station.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, err := c.CreateEntitySequenceGroup("jdoe", "sequencegroup", station.ID.String(), "G1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	discarded, err := c.CreateEntity("jdoe", "operation", getIDFromModel(tool).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteEntityByIDString("jdoe", "operation", getIDFromModel(discarded).String()); err != nil {
+		t.Fatal(err)
+	}
+	// An import logs every entity it creates.
+	filePath := filepath.Join(t.TempDir(), "station.json")
+	if err := c.ExportEntityHierarchyToJSON("jdoe", "station", station.ID.String(), filePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ImportEntityHierarchyFromJSON("jdoe", filePath, line.ID.String(), ImportModeRemap); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := c.GetChangesSince(since)
+	if err != nil {
+		t.Fatalf("GetChangesSince returned %v", err)
+	}
+	counts := make(map[string]int)
+	for entityType, entries := range changes.CreatedEntities {
+		counts[entityType] = len(entries)
+		for _, entry := range entries {
+			if entry["parentId"] == nil || entry["parentType"] != parentEntityType(entityType) {
+				t.Errorf("created %s %v without its parent", entityType, entry["id"])
+			}
+		}
+	}
+	// The new tool and group plus the copied station with its two tools, its group and one operation.
+	want := map[string]int{"station": 1, "tool": 3, "sequencegroup": 2, "operation": 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("created entities per type %v, want %v", counts, want)
+	}
+	for _, entry := range changes.CreatedEntities["sequencegroup"] {
+		if entry["id"] == getIDFromModel(group).String() && entry["parentId"] != station.ID.String() {
+			t.Errorf("group created below %v, want station %s", entry["parentId"], station.ID)
+		}
+	}
+	for _, id := range changes.DeletedEntities["operation"] {
+		if id != getIDFromModel(discarded).String() {
+			t.Errorf("deleted operation %s, want only %s", id, getIDFromModel(discarded))
+		}
+	}
+}
+
+func TestExportChangeLog(t *testing.T) {
+	c := newTestCore(t)
+	_, station, tool, _ := createTestLine(t, c.DB)
+	for _, name := range []string{"S1b", "S1c"} {
+		updateTestEntity(t, c, "station", station.ID.String(), map[string]string{"Name": name, "Description": "for " + name})
+	}
+	updateTestEntity(t, c, "tool", tool.ID.String(), map[string]string{"Description": "x"})
+	filter := ChangeLogFilter{EntityType: "station", OperationType: OpTypeUpdate, Ascending: true}
+
+	csvPath := filepath.Join(t.TempDir(), "audit.csv")
+	if err := c.ExportChangeLog(filter, "CSV", csvPath); err != nil {
+		t.Fatalf("CSV export returned %v", err)
+	}
+	file, err := os.Open(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One row per changed field, sorted by field within a log entry.
+	want := [][]string{
+		{"UPDATE", "Description", "", "for S1b"},
+		{"UPDATE", "Name", "S1", "S1b"},
+		{"UPDATE", "Description", "for S1b", "for S1c"},
+		{"UPDATE", "Name", "S1b", "S1c"},
+	}
+	if len(rows) != len(want)+1 || !reflect.DeepEqual(rows[0], changeLogCSVHeader) {
+		t.Fatalf("CSV export has rows %q", rows)
+	}
+	for i, row := range rows[1:] {
+		if got := []string{row[1], row[6], row[7], row[8]}; !reflect.DeepEqual(got, want[i]) || row[3] != station.ID.String() {
+			t.Errorf("CSV row %d is %q, want %q for station %s", i+1, row, want[i], station.ID)
+		}
+	}
+
+	jsonlPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	if err := c.ExportChangeLog(filter, "jsonl", jsonlPath); err != nil {
+		t.Fatalf("JSON Lines export returned %v", err)
+	}
+	raw, err := os.ReadFile(jsonlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("JSON Lines export has %d lines, want 2", len(lines))
+	}
+	for _, line := range lines {
+		var entry ChangeLogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("line %q is not a change log entry: %v", line, err)
+		}
+		if entry.EntityID != station.ID.String() || len(entry.Changes) != 2 {
+			t.Errorf("exported entry %+v, want the station with two changes", entry)
+		}
+	}
+
+	if err := c.ExportChangeLog(filter, "xml", filepath.Join(t.TempDir(), "audit.xml")); err == nil {
+		t.Error("export in an unknown format returned no error")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
 	"encoding/json"
@@ -13,6 +14,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/atotto/clipboard"
@@ -50,6 +52,25 @@ type Core struct {
 	queueName      string
 	serviceName    string
 	dependencyJSON []byte
+	// sourceDB names the connected server and database in export files.
+	sourceDB string
+	// settingsMu guards the settings below, which InitDB loads from app_metadata.
+	settingsMu sync.RWMutex
+	// hardDelete restores the old behaviour of deleting entities and their history permanently.
+	hardDelete bool
+	// recycleBinPurgeDays removes recycle bin entries older than this many days; 0 keeps them forever.
+	recycleBinPurgeDays int
+	// blockSPSConflicts rejects tool updates that cause an SPS conflict, see ConfigureSPSConflictCheck.
+	blockSPSConflicts bool
+	// transferCancel cancels the running export or import, see CancelTransfer.
+	transferMu     sync.Mutex
+	transferCancel context.CancelFunc
+	// Automatic backups, see ConfigureAutoBackup.
+	backupMu        sync.Mutex
+	backupCancel    context.CancelFunc
+	backupFolder    string
+	backupInterval  time.Duration
+	backupRetention int
 }
 
 func NewCore() *Core {
@@ -65,7 +86,7 @@ func (c *Core) startup(ctx context.Context) {
 	c.ctx = ctx
 }
 
-func (c *Core) HandleExport(entityType string, entityID string) string {
+func (c *Core) HandleExport(user string, entityType string, entityID string) string {
 	file, _ := ws.SaveFileDialog(c.ctx, ws.SaveDialogOptions{
 		DefaultFilename: fmt.Sprintf("%s_%s_export.json", entityType, entityID),
 		Title:           "Export",
@@ -74,7 +95,7 @@ func (c *Core) HandleExport(entityType string, entityID string) string {
 			{DisplayName: "*", Pattern: "*.*"},
 		},
 	})
-	err := c.ExportEntityHierarchyToJSON(entityType, entityID, file)
+	err := c.ExportEntityHierarchyToJSON(user, entityType, entityID, file)
 	if err != nil {
 		return "ExportError"
 	} else {
@@ -82,7 +103,7 @@ func (c *Core) HandleExport(entityType string, entityID string) string {
 	}
 }
 
-func (c *Core) HandleImport(user string) string {
+func (c *Core) HandleImport(user string, parentIDStr string, mode string) string {
 	file, _ := ws.OpenFileDialog(c.ctx, ws.OpenDialogOptions{
 		Title: "Import",
 		Filters: []ws.FileFilter{
@@ -90,7 +111,7 @@ func (c *Core) HandleImport(user string) string {
 			{DisplayName: "*", Pattern: "*.*"},
 		},
 	})
-	err := c.ImportEntityHierarchyFromJSON_UseOriginalData(user, file)
+	err := c.ImportEntityHierarchyFromJSON(user, file, parentIDStr, mode)
 	if err != nil {
 		return "ImportError"
 	} else {
@@ -284,11 +305,21 @@ func (c *Core) listenForChanges(ctx context.Context, sqlDB *sql.DB, dsn string)
 }
 
 const GlobalMetadataKey = "global_state"
+const OpTypeCreate = "CREATE"
 const OpTypeUpdate = "UPDATE"
 const OpTypeDelete = "DELETE"
 const OpTypeSystemEvent = "SYSTEM_EVENT"
 
+// Import modes: ImportModeOriginal keeps the IDs of the file and fails on existing ones,
+// ImportModeRemap creates every entity with a fresh ID, ImportModeMerge updates existing entities
+// in place and ImportModeMergeDelete additionally deletes entities missing from the file.
+const ImportModeOriginal = "original"
+const ImportModeRemap = "remap"
+const ImportModeMerge = "merge"
+const ImportModeMergeDelete = "merge-delete"
+
 func (c *Core) InitDB(dsn string) string {
+	c.stopAutoBackup()
 	if c.listenerCancel != nil {
 		c.listenerCancel()
 		c.listenerCancel = nil
@@ -323,18 +354,22 @@ func (c *Core) InitDB(dsn string) string {
 	}
 	err = c.DB.AutoMigrate(
 		&Line{}, &Station{}, &Tool{}, &Operation{}, &SequenceGroup{},
-		&AppMetadata{}, &EntityChangeLog{},
-		&LineHistory{}, &StationHistory{}, &ToolHistory{}, &OperationHistory{},
+		&AppMetadata{}, &EntityChangeLog{}, &RecycleBinEntry{}, &LineBaseline{},
+		&LineHistory{}, &StationHistory{}, &ToolHistory{}, &OperationHistory{}, &SequenceGroupHistory{},
 	)
 	if err != nil {
 		return "InitError"
 	}
 	ensureAppMetadataExists(c.DB)
+	c.loadRecycleBinSettings()
+	c.loadSPSConflictCheck()
+	c.purgeExpiredRecycleBin()
 	u, err := url.Parse(dsn)
 	if err != nil {
 		return "InitError"
 	}
 	dbName := u.Query().Get("database")
+	c.sourceDB = u.Host + "/" + dbName
 	queueName, serviceName, err := setupBroker(c.DB, dbName)
 	if err != nil {
 		return "InitError"
@@ -358,6 +393,9 @@ func (c *Core) InitDB(dsn string) string {
 	listenerCtx, cancel := context.WithCancel(c.ctx)
 	c.listenerCancel = cancel
 	go c.listenForChanges(listenerCtx, sqlDB, dsn)
+	go c.recycleBinPurgeLoop(listenerCtx)
+	c.loadAutoBackupSettings()
+	c.startAutoBackup()
 
 	log.Println("Successfully connected to and migrated MS SQL server DB.")
 	return "InitSuccess"
@@ -392,7 +430,48 @@ func (c *Core) GetPlatformSpecificUserName() string {
 	}
 	return username
 }
-func updateGlobalLastUpdateTimestampAndLogChange(tx *gorm.DB, entityID mssql.UniqueIdentifier, entityType string, operationType string, userID *string, changedFields map[string]string) error {
+
+// FieldChange is the value of a single column before and after an update.
+type FieldChange struct {
+	Old *string `json:"old"`
+	New *string `json:"new"`
+}
+
+// buildFieldChanges pairs the new values of an update with the values the entity holds before it is applied.
+func buildFieldChanges(before interface{}, newValues map[string]string) map[string]FieldChange {
+	oldValues := currentFieldValues(before)
+	changes := make(map[string]FieldChange, len(newValues))
+	for field, value := range newValues {
+		changes[field] = FieldChange{Old: oldValues[field], New: strPtr(value)}
+	}
+	return changes
+}
+
+// decodeChangedFields reads EntityChangeLog.ChangedFields. Older entries only hold the new values.
+func decodeChangedFields(raw *string) map[string]FieldChange {
+	changes := make(map[string]FieldChange)
+	if raw == nil || *raw == "" {
+		return changes
+	}
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(*raw), &entries); err != nil {
+		return changes
+	}
+	for field, entry := range entries {
+		var change FieldChange
+		if err := json.Unmarshal(entry, &change); err == nil {
+			changes[field] = change
+			continue
+		}
+		var newValue string
+		if err := json.Unmarshal(entry, &newValue); err == nil {
+			changes[field] = FieldChange{New: &newValue}
+		}
+	}
+	return changes
+}
+
+func updateGlobalLastUpdateTimestampAndLogChange(tx *gorm.DB, entityID mssql.UniqueIdentifier, entityType string, operationType string, userID *string, changedFields map[string]FieldChange) error {
 	now := time.Now()
 	if err := tx.Model(&AppMetadata{}).Where("config_key = ?", GlobalMetadataKey).Update("last_update", now).Error; err != nil {
 		return fmt.Errorf("failed to update global timestamp: %w", err)
@@ -432,6 +511,31 @@ func updateGlobalLastUpdateTimestampAndLogChange(tx *gorm.DB, entityID mssql.Uni
 	}
 	return nil
 }
+
+// logEntityCreation updates the global timestamp and records that an entity was created below its parent.
+func logEntityCreation(tx *gorm.DB, entityID mssql.UniqueIdentifier, entityType string, parentID *mssql.UniqueIdentifier, userID *string) error {
+	now := time.Now()
+	if err := tx.Model(&AppMetadata{}).Where("config_key = ?", GlobalMetadataKey).Update("last_update", now).Error; err != nil {
+		return fmt.Errorf("failed to update global timestamp: %w", err)
+	}
+	logEntityType := strings.ToLower(entityType)
+	changeLog := EntityChangeLog{
+		EntityID:        entityID,
+		EntityType:      logEntityType,
+		OperationType:   OpTypeCreate,
+		ChangeTime:      now,
+		ChangedByUserID: userID,
+	}
+	if parentID != nil {
+		changeLog.ParentID = parentID
+		changeLog.ParentType = strPtr(parentEntityType(logEntityType))
+	}
+	if err := tx.Create(&changeLog).Error; err != nil {
+		return fmt.Errorf("failed to create entity change log for %s on %s (ID: %s): %w", OpTypeCreate, entityType, entityID.String(), err)
+	}
+	return nil
+}
+
 func (c *Core) GetGlobalLastUpdateTimestamp() (string, error) {
 	if c.DB == nil {
 		return "", errors.New("DB not initialized")
@@ -450,11 +554,18 @@ func (c *Core) GetGlobalLastUpdateTimestamp() (string, error) {
 
 type ChangeResponse struct {
 	NewGlobalLastUpdatedAt string                              `json:"newGlobalLastUpdatedAt"`
+	CreatedEntities        map[string][]map[string]interface{} `json:"createdEntities"`
 	UpdatedEntities        map[string][]map[string]interface{} `json:"updatedEntities"`
 	DeletedEntities        map[string][]string                 `json:"deletedEntities"`
 }
 
 func (c *Core) collectAllChildIDs(entityType string, entityID mssql.UniqueIdentifier) (map[string][]string, error) {
+	return collectChildIDs(c.DB, entityType, entityID)
+}
+
+// collectChildIDs walks the hierarchy below an entity using the given DB handle,
+// so callers can pass an unscoped handle to include soft-deleted children.
+func collectChildIDs(db *gorm.DB, entityType string, entityID mssql.UniqueIdentifier) (map[string][]string, error) {
 	result := make(map[string][]string)
 	idStr := entityID.String()
 
@@ -463,11 +574,11 @@ func (c *Core) collectAllChildIDs(entityType string, entityID mssql.UniqueIdenti
 	switch entityType {
 	case "line":
 		var stations []Station
-		if err := c.DB.Where("parent_id = ?", entityID).Find(&stations).Error; err != nil {
+		if err := db.Where("parent_id = ?", entityID).Find(&stations).Error; err != nil {
 			return result, err
 		}
 		for _, s := range stations {
-			childResults, err := c.collectAllChildIDs("station", s.ID)
+			childResults, err := collectChildIDs(db, "station", s.ID)
 			if err != nil {
 				return result, err
 			}
@@ -476,12 +587,19 @@ func (c *Core) collectAllChildIDs(entityType string, entityID mssql.UniqueIdenti
 			}
 		}
 	case "station":
+		var groups []SequenceGroup
+		if err := db.Where("parent_id = ?", entityID).Find(&groups).Error; err != nil {
+			return result, err
+		}
+		for _, g := range groups {
+			result["sequencegroup"] = append(result["sequencegroup"], g.ID.String())
+		}
 		var tools []Tool
-		if err := c.DB.Where("parent_id = ?", entityID).Find(&tools).Error; err != nil {
+		if err := db.Where("parent_id = ?", entityID).Find(&tools).Error; err != nil {
 			return result, err
 		}
 		for _, t := range tools {
-			childResults, err := c.collectAllChildIDs("tool", t.ID)
+			childResults, err := collectChildIDs(db, "tool", t.ID)
 			if err != nil {
 				return result, err
 			}
@@ -491,11 +609,11 @@ func (c *Core) collectAllChildIDs(entityType string, entityID mssql.UniqueIdenti
 		}
 	case "tool":
 		var ops []Operation
-		if err := c.DB.Where("parent_id = ?", entityID).Find(&ops).Error; err != nil {
+		if err := db.Where("parent_id = ?", entityID).Find(&ops).Error; err != nil {
 			return result, err
 		}
 		for _, o := range ops {
-			childResults, err := c.collectAllChildIDs("operation", o.ID)
+			childResults, err := collectChildIDs(db, "operation", o.ID)
 			if err != nil {
 				return result, err
 			}
@@ -520,7 +638,7 @@ func (c *Core) GetChangesSince(clientLastKnownTimestampStr string) (*ChangeRespo
 		return nil, fmt.Errorf("failed to get current global update timestamp: %w", err)
 	}
 	currentGlobalTime, _ := parseTimestampFlexible(currentGlobalTsStr)
-	response := &ChangeResponse{NewGlobalLastUpdatedAt: currentGlobalTsStr, UpdatedEntities: make(map[string][]map[string]interface{}), DeletedEntities: make(map[string][]string)}
+	response := &ChangeResponse{NewGlobalLastUpdatedAt: currentGlobalTsStr, CreatedEntities: make(map[string][]map[string]interface{}), UpdatedEntities: make(map[string][]map[string]interface{}), DeletedEntities: make(map[string][]string)}
 	if currentGlobalTime.After(clientLastKnownTime) {
 		var logs []EntityChangeLog
 		if err := c.DB.Where("change_time > ?", clientLastKnownTime).Order("change_time asc").Find(&logs).Error; err != nil {
@@ -528,10 +646,19 @@ func (c *Core) GetChangesSince(clientLastKnownTimestampStr string) (*ChangeRespo
 		}
 		processedUpdatedIDs := make(map[string]bool)
 		processedDeletedIDs := make(map[string]bool)
+		// Entities created and deleted again within the window are not reported as created.
+		var createdKeys []string
+		createdEntries := make(map[string]EntityChangeLog)
 		for _, lg := range logs {
 			idStr := lg.EntityID.String()
 			key := lg.EntityType + "_" + idStr
-			if lg.OperationType == OpTypeSystemEvent {
+			if lg.OperationType == OpTypeCreate {
+				if _, seen := createdEntries[key]; !seen {
+					createdKeys = append(createdKeys, key)
+				}
+				createdEntries[key] = lg
+				delete(processedDeletedIDs, key)
+			} else if lg.OperationType == OpTypeSystemEvent {
 				if _, ok := response.UpdatedEntities["system_event"]; !ok {
 					response.UpdatedEntities["system_event"] = []map[string]interface{}{}
 				}
@@ -543,18 +670,40 @@ func (c *Core) GetChangesSince(clientLastKnownTimestampStr string) (*ChangeRespo
 				response.DeletedEntities[lg.EntityType] = append(response.DeletedEntities[lg.EntityType], idStr)
 				processedDeletedIDs[key] = true
 				delete(processedUpdatedIDs, key)
+				delete(createdEntries, key)
 			} else if lg.OperationType == OpTypeUpdate && !processedUpdatedIDs[key] {
-				var changedFields map[string]string
-				if lg.ChangedFields != nil && *lg.ChangedFields != "" {
-					_ = json.Unmarshal([]byte(*lg.ChangedFields), &changedFields)
+				fieldChanges := decodeChangedFields(lg.ChangedFields)
+				changedFields := make(map[string]string, len(fieldChanges))
+				for field, change := range fieldChanges {
+					changedFields[field] = derefOrEmpty(change.New)
 				}
 				if _, ok := response.UpdatedEntities[lg.EntityType]; !ok {
 					response.UpdatedEntities[lg.EntityType] = []map[string]interface{}{}
 				}
-				response.UpdatedEntities[lg.EntityType] = append(response.UpdatedEntities[lg.EntityType], map[string]interface{}{"id": idStr, "changedFields": changedFields})
+				response.UpdatedEntities[lg.EntityType] = append(response.UpdatedEntities[lg.EntityType], map[string]interface{}{"id": idStr, "changedFields": changedFields, "fieldChanges": fieldChanges})
 				processedUpdatedIDs[key] = true
 			}
 		}
+		for _, key := range createdKeys {
+			lg, ok := createdEntries[key]
+			if !ok {
+				continue
+			}
+			entry := map[string]interface{}{"id": lg.EntityID.String()}
+			if lg.ParentID != nil {
+				entry["parentId"] = lg.ParentID.String()
+				entry["parentType"] = derefOrEmpty(lg.ParentType)
+			}
+			response.CreatedEntities[lg.EntityType] = append(response.CreatedEntities[lg.EntityType], entry)
+			// A restored entity is reported as created, not as deleted.
+			ids := response.DeletedEntities[lg.EntityType]
+			for i, idStr := range ids {
+				if idStr == lg.EntityID.String() {
+					response.DeletedEntities[lg.EntityType] = append(ids[:i], ids[i+1:]...)
+					break
+				}
+			}
+		}
 	}
 	return response, nil
 }
@@ -602,6 +751,20 @@ func getModelInstance(entityTypeStr string) (interface{}, error) {
 	}
 }
 
+// parentEntityType returns the type of the entity that owns entities of the given type.
+func parentEntityType(entityTypeStr string) string {
+	switch strings.ToLower(entityTypeStr) {
+	case "station":
+		return "line"
+	case "tool", "sequencegroup":
+		return "station"
+	case "operation":
+		return "tool"
+	default:
+		return ""
+	}
+}
+
 type HierarchyResponse struct {
 	Data                interface{} `json:"data"`
 	GlobalLastUpdatedAt string      `json:"globalLastUpdatedAt"`
@@ -674,7 +837,11 @@ func (c *Core) CreateEntity(userName string, entityTypeStr string, parentIDStrIf
 		if err := tx.Create(entityToCreate).Error; err != nil {
 			return fmt.Errorf("DB error creating %s: %w", entityTypeStr, err)
 		}
-		return tx.Model(&AppMetadata{}).Where("config_key = ?", GlobalMetadataKey).Update("last_update", time.Now()).Error
+		if err := c.checkEntityCatalog(tx, entityToCreate); err != nil {
+			return err
+		}
+		_, parentID := entityNameAndParent(entityToCreate)
+		return logEntityCreation(tx, getIDFromModel(entityToCreate), entityTypeNormalized, parentID, strPtr(userName))
 	})
 	if err != nil {
 		return nil, err
@@ -734,7 +901,7 @@ func (c *Core) CreateEntitySequenceGroup(userName string, entityTypeStr string,
 		if err := tx.Create(entityToCreate).Error; err != nil {
 			return fmt.Errorf("DB error creating %s: %w", entityTypeStr, err)
 		}
-		return tx.Model(&AppMetadata{}).Where("config_key = ?", GlobalMetadataKey).Update("last_update", time.Now()).Error
+		return logEntityCreation(tx, entityToCreate.ID, entityTypeNormalized, &parentIDmssql, strPtr(userName))
 	})
 	if err != nil {
 		return nil, err
@@ -771,6 +938,9 @@ func createVersion(tx *gorm.DB, entityTypeStr string, entityData interface{}) er
 	case *Operation:
 		historyModel = &OperationHistory{}
 		entityID = v.ID
+	case *SequenceGroup:
+		historyModel = &SequenceGroupHistory{}
+		entityID = v.ID
 	default:
 		return fmt.Errorf("unknown entity type for versioning: %s", entityTypeStr)
 	}
@@ -863,10 +1033,51 @@ func createVersion(tx *gorm.DB, entityTypeStr string, entityData interface{}) er
 			ParentID:          v.ParentID,
 		}
 		return tx.Create(&historyRecord).Error
+	case *SequenceGroup:
+		historyRecord := SequenceGroupHistory{
+			Version:     int(nextVersion),
+			EntityID:    v.ID,
+			Name:        v.Name,
+			Comment:     v.Comment,
+			StatusColor: v.StatusColor,
+			CreatedAt:   v.CreatedAt,
+			UpdatedAt:   v.UpdatedAt,
+			CreatedBy:   v.CreatedBy,
+			UpdatedBy:   v.UpdatedBy,
+			Index:       v.Index,
+			ParentID:    v.ParentID,
+		}
+		return tx.Create(&historyRecord).Error
 	}
 	return fmt.Errorf("unhandled entity type in createVersion switch: %T", entityData)
 }
 
+// checkUpdatedAtConflict compares the UpdatedAt of the loaded entity with the timestamp the client last saw.
+func checkUpdatedAtConflict(entity interface{}, lastKnownUpdatedAt time.Time) error {
+	var currentDBUpdatedAt time.Time
+	switch e := entity.(type) {
+	case *Line:
+		currentDBUpdatedAt = e.UpdatedAt
+	case *Station:
+		currentDBUpdatedAt = e.UpdatedAt
+	case *Tool:
+		currentDBUpdatedAt = e.UpdatedAt
+	case *Operation:
+		currentDBUpdatedAt = e.UpdatedAt
+	case *SequenceGroup:
+		currentDBUpdatedAt = e.UpdatedAt
+	default:
+		return fmt.Errorf("unknown entity type for concurrency check: %T", e)
+	}
+
+	// Compare timestamps with a tiny tolerance for precision differences.
+	if currentDBUpdatedAt.After(lastKnownUpdatedAt.Add(time.Millisecond)) {
+		log.Printf("[Concurrency] Conflict detected: DB UpdatedAt=%s | Client Known UpdatedAt=%s", currentDBUpdatedAt.UTC().Format(time.RFC3339Nano), lastKnownUpdatedAt.UTC().Format(time.RFC3339Nano))
+		return errors.New("conflict: record was modified by another user")
+	}
+	return nil
+}
+
 func (c *Core) UpdateEntityFieldsString(userName string, entityTypeStr string, entityIDStr string, lastKnownUpdatedAtStr string, updatesMapStr map[string]string) (interface{}, error) {
 	if c.DB == nil {
 		return nil, errors.New("DB not initialized")
@@ -882,6 +1093,9 @@ func (c *Core) UpdateEntityFieldsString(userName string, entityTypeStr string, e
 	if err != nil {
 		return nil, fmt.Errorf("invalid updated_at format ('%s'): %w", lastKnownUpdatedAtStr, err)
 	}
+	if err := c.validateEntityUpdate(entityTypeStr, updatesMapStr); err != nil {
+		return nil, err
+	}
 
 	var finalModelInstance interface{}
 	err = c.DB.Transaction(func(tx *gorm.DB) error {
@@ -900,24 +1114,8 @@ func (c *Core) UpdateEntityFieldsString(userName string, entityTypeStr string, e
 		}
 
 		// 3. Concurrency Check: Ensure the client is updating the version they think they are.
-		var currentDBUpdatedAt time.Time
-		switch e := modelToUpdate.(type) {
-		case *Line:
-			currentDBUpdatedAt = e.UpdatedAt
-		case *Station:
-			currentDBUpdatedAt = e.UpdatedAt
-		case *Tool:
-			currentDBUpdatedAt = e.UpdatedAt
-		case *Operation:
-			currentDBUpdatedAt = e.UpdatedAt
-		default:
-			return fmt.Errorf("unknown entity type for concurrency check: %T", e)
-		}
-
-		// Compare timestamps with a tiny tolerance for precision differences.
-		if currentDBUpdatedAt.After(lastKnownUpdatedAt.Add(time.Millisecond)) {
-			log.Printf("[Concurrency] Conflict detected: DB UpdatedAt=%s | Client Known UpdatedAt=%s", currentDBUpdatedAt.UTC().Format(time.RFC3339Nano), lastKnownUpdatedAt.UTC().Format(time.RFC3339Nano))
-			return errors.New("conflict: record was modified by another user")
+		if err := checkUpdatedAtConflict(modelToUpdate, lastKnownUpdatedAt); err != nil {
+			return err
 		}
 
 		// 4. Create a history version of the entity state BEFORE the update.
@@ -925,10 +1123,37 @@ func (c *Core) UpdateEntityFieldsString(userName string, entityTypeStr string, e
 			return fmt.Errorf("failed to create entity version: %w", err)
 		}
 
+		fieldChanges := buildFieldChanges(modelToUpdate, updatesMapStr)
+
+		// Conflicts the tool already has do not block the update, only new ones do.
+		checkSPS := false
+		var spsConflictsBefore []SPSConflict
+		if tool, ok := modelToUpdate.(*Tool); ok && c.spsConflictCheckEnabled() {
+			for field := range updatesMapStr {
+				if spsFields[field] {
+					checkSPS = true
+					break
+				}
+			}
+			if checkSPS {
+				if spsConflictsBefore, err = toolSPSConflicts(tx, tool); err != nil {
+					return err
+				}
+			}
+		}
+
+		if op, ok := modelToUpdate.(*Operation); ok {
+			if groupID, ok := updatesMapStr["GroupID"]; ok {
+				if err := checkOperationGroup(tx, op.ParentID, groupID); err != nil {
+					return err
+				}
+			}
+		}
+
 		// 5. Prepare and apply the updates to the live entity.
 		gormUpdates := make(map[string]interface{})
 		for k, v := range updatesMapStr {
-			gormUpdates[k] = strPtr(v)
+			gormUpdates[k] = fieldUpdateValue(entityTypeStr, k, v)
 		}
 		gormUpdates["updated_by"] = strPtr(userName)
 		gormUpdates["updated_at"] = time.Now() // Explicitly set timestamp
@@ -944,8 +1169,29 @@ func (c *Core) UpdateEntityFieldsString(userName string, entityTypeStr string, e
 		}
 		finalModelInstance = reloadedEntityWithinTx
 
+		_, toolClassChanged := updatesMapStr["ToolClass"]
+		_, stationTypeChanged := updatesMapStr["StationType"]
+		if toolClassChanged || stationTypeChanged {
+			if err := c.resetDependentOperations(tx, userName, reloadedEntityWithinTx); err != nil {
+				return err
+			}
+		}
+		for field := range updatesMapStr {
+			if catalogRuleFields[field] {
+				if err := c.checkUpdatedEntityCatalog(tx, reloadedEntityWithinTx); err != nil {
+					return err
+				}
+				break
+			}
+		}
+		if tool, ok := reloadedEntityWithinTx.(*Tool); ok && checkSPS {
+			if err := checkToolSPSConflicts(tx, tool, spsConflictsBefore); err != nil {
+				return err
+			}
+		}
+
 		// 7. Update global timestamp and log the change.
-		return updateGlobalLastUpdateTimestampAndLogChange(tx, entityIDmssql, strings.ToLower(entityTypeStr), OpTypeUpdate, strPtr(userName), updatesMapStr)
+		return updateGlobalLastUpdateTimestampAndLogChange(tx, entityIDmssql, strings.ToLower(entityTypeStr), OpTypeUpdate, strPtr(userName), fieldChanges)
 	})
 
 	if err != nil {
@@ -975,6 +1221,9 @@ func (c *Core) UpdateEntityFieldsStringSequenceGroup(userName string, entityType
 	if err != nil {
 		return nil, fmt.Errorf("invalid updated_at format ('%s'): %w", lastKnownUpdatedAtStr, err)
 	}
+	if err := c.validateEntityUpdate(entityTypeNormalized, updatesMapStr); err != nil {
+		return nil, err
+	}
 
 	var modelToUpdate interface{}
 	switch entityTypeNormalized {
@@ -986,6 +1235,7 @@ func (c *Core) UpdateEntityFieldsStringSequenceGroup(userName string, entityType
 
 	var finalModelInstance interface{}
 	err = c.DB.Transaction(func(tx *gorm.DB) error {
+		var currentEntity interface{}
 		switch entityTypeNormalized {
 		case "sequencegroup":
 			var m SequenceGroup
@@ -995,6 +1245,7 @@ func (c *Core) UpdateEntityFieldsStringSequenceGroup(userName string, entityType
 				}
 				return fmt.Errorf("error loading entity for update check: %w", err)
 			}
+			currentEntity = &m
 		case "operation":
 			var m Operation
 			if err := tx.Where("id = ?", entityIDmssql).Take(&m).Error; err != nil {
@@ -1003,7 +1254,9 @@ func (c *Core) UpdateEntityFieldsStringSequenceGroup(userName string, entityType
 				}
 				return fmt.Errorf("error loading entity for update check: %w", err)
 			}
+			currentEntity = &m
 		}
+		currentFields := currentFieldValues(currentEntity)
 
 		currentGlobalTsStr, err := c.GetGlobalLastUpdateTimestamp()
 		if err != nil {
@@ -1018,9 +1271,32 @@ func (c *Core) UpdateEntityFieldsStringSequenceGroup(userName string, entityType
 			return errors.New("conflict: record was modified by another user")
 		}
 
+		// Reorders resend every group and operation, so only rows that actually change get a version.
+		changedFields := make(map[string]string)
+		for k, v := range updatesMapStr {
+			current, known := currentFields[k]
+			if !known || !strings.EqualFold(derefOrEmpty(current), v) {
+				changedFields[k] = v
+			}
+		}
+		fieldChanges := buildFieldChanges(currentEntity, changedFields)
+		if len(changedFields) > 0 {
+			if err := createVersion(tx, entityTypeNormalized, currentEntity); err != nil {
+				return fmt.Errorf("failed to create entity version: %w", err)
+			}
+		}
+
+		if op, ok := currentEntity.(*Operation); ok {
+			if groupID, ok := changedFields["GroupID"]; ok {
+				if err := checkOperationGroup(tx, op.ParentID, groupID); err != nil {
+					return err
+				}
+			}
+		}
+
 		gromUpdates := make(map[string]interface{})
 		for k, v := range updatesMapStr {
-			gromUpdates[k] = strPtr(v)
+			gromUpdates[k] = fieldUpdateValue(entityTypeNormalized, k, v)
 		}
 		gromUpdates["updated_by"] = strPtr(userName)
 		gromUpdates["updated_at"] = time.Now()
@@ -1034,11 +1310,20 @@ func (c *Core) UpdateEntityFieldsStringSequenceGroup(userName string, entityType
 		}
 		finalModelInstance = reloadedEntity
 
-		// Nur Änderungen an Operationen in den Changelog schreiben
-		if entityTypeNormalized == "operation" {
-			return updateGlobalLastUpdateTimestampAndLogChange(tx, entityIDmssql, entityTypeNormalized, OpTypeUpdate, strPtr(userName), updatesMapStr)
+		for field := range changedFields {
+			if catalogRuleFields[field] {
+				if err := c.checkUpdatedEntityCatalog(tx, reloadedEntity); err != nil {
+					return err
+				}
+				break
+			}
 		}
-		return nil
+
+		if len(changedFields) > 0 {
+			return updateGlobalLastUpdateTimestampAndLogChange(tx, entityIDmssql, entityTypeNormalized, OpTypeUpdate, strPtr(userName), fieldChanges)
+		}
+		// Keep the global timestamp ahead of updated_at so the next reorder passes the conflict check.
+		return tx.Model(&AppMetadata{}).Where("config_key = ?", GlobalMetadataKey).Update("last_update", time.Now()).Error
 	})
 	if err != nil {
 		return nil, err
@@ -1193,11 +1478,10 @@ func internalGetEntityHierarchy(db *gorm.DB, entityTypeStr string, entityIDStr s
 	case "line":
 		txDB = txDB.Preload("Stations.Tools.Operations").Preload("Stations.SequenceGroups.Operations")
 	case "station":
-		txDB = txDB.Preload("Tools.Operations").Preload("SequenceGroups.Operations").Preload("Line")
+		txDB = txDB.Preload("Tools.Operations").Preload("SequenceGroups.Operations")
 	case "tool", "sequencegroup":
-		txDB = txDB.Preload("Operations").Preload("Station.Line")
+		txDB = txDB.Preload("Operations")
 	case "operation":
-		txDB = txDB.Preload("Tool.Station.Line").Preload("SequenceGroup.Station.Line")
 	default:
 		return nil, fmt.Errorf("hierarchical loading not defined for type: %s", entityTypeStr)
 	}
@@ -1207,53 +1491,161 @@ func internalGetEntityHierarchy(db *gorm.DB, entityTypeStr string, entityIDStr s
 		}
 		return nil, fmt.Errorf("error loading hierarchy for type %s, ID %s: %w", entityTypeStr, entityIDStr, err)
 	}
+	if err := loadParentReferences(db, modelInstance); err != nil {
+		return nil, err
+	}
 	return modelInstance, nil
 }
 
-func (c *Core) ExportEntityHierarchyToJSON(entityTypeStr string, entityIDStr string, filePath string) error {
+// loadParentReferences loads the parents of an entity up to its line: the line of a station,
+// the station and line of a tool or sequence group and the tool and group of an operation.
+func loadParentReferences(db *gorm.DB, entity interface{}) error {
+	loadStation := func(id mssql.UniqueIdentifier) (*Station, error) {
+		var station Station
+		if err := db.First(&station, "id = ?", id).Error; err != nil {
+			return nil, fmt.Errorf("error loading station %s: %w", id.String(), err)
+		}
+		var line Line
+		if err := db.First(&line, "id = ?", station.ParentID).Error; err != nil {
+			return nil, fmt.Errorf("error loading line %s: %w", station.ParentID.String(), err)
+		}
+		station.Line = &line
+		return &station, nil
+	}
+	var err error
+	switch e := entity.(type) {
+	case *Station:
+		var line Line
+		if err := db.First(&line, "id = ?", e.ParentID).Error; err != nil {
+			return fmt.Errorf("error loading line %s: %w", e.ParentID.String(), err)
+		}
+		e.Line = &line
+	case *Tool:
+		e.Station, err = loadStation(e.ParentID)
+	case *SequenceGroup:
+		e.Station, err = loadStation(e.ParentID)
+	case *Operation:
+		var tool Tool
+		if err := db.First(&tool, "id = ?", e.ParentID).Error; err != nil {
+			return fmt.Errorf("error loading tool %s: %w", e.ParentID.String(), err)
+		}
+		if tool.Station, err = loadStation(tool.ParentID); err != nil {
+			return err
+		}
+		e.Tool = &tool
+		if e.GroupID != nil {
+			// A group in the recycle bin is left out, as a preload would.
+			var groups []SequenceGroup
+			if err := db.Where("id = ?", *e.GroupID).Limit(1).Find(&groups).Error; err != nil {
+				return fmt.Errorf("error loading sequence group %s: %w", e.GroupID.String(), err)
+			}
+			if len(groups) > 0 {
+				if groups[0].Station, err = loadStation(groups[0].ParentID); err != nil {
+					return err
+				}
+				e.Group = &groups[0]
+			}
+		}
+	}
+	return err
+}
+
+// clearParentReferences removes the parents internalGetEntityHierarchy loads along with an entity,
+// so an export only holds the entity and its children.
+func clearParentReferences(entity interface{}) {
+	switch e := entity.(type) {
+	case *Station:
+		e.Line = nil
+	case *Tool:
+		e.Station = nil
+	case *SequenceGroup:
+		e.Station = nil
+	case *Operation:
+		e.Tool = nil
+		e.Group = nil
+	}
+}
+
+// ExportEntityHierarchyToJSON writes an entity and its children as an export file.
+// Lines are streamed station by station; progress is emitted and CancelTransfer stops the export.
+func (c *Core) ExportEntityHierarchyToJSON(userName string, entityTypeStr string, entityIDStr string, filePath string) (err error) {
 	if c.DB == nil {
 		return errors.New("DB not initialized")
 	}
 	if filePath == "" {
 		return errors.New("export filePath is empty")
 	}
-	hierarchyData, err := internalGetEntityHierarchy(c.DB, entityTypeStr, entityIDStr)
+	ctx, finish, err := c.beginTransfer()
 	if err != nil {
-		return fmt.Errorf("error loading hierarchy for export: %w", err)
+		return err
 	}
-	jsonData, err := json.MarshalIndent(hierarchyData, "", "  ")
+	defer finish()
+	entityTypeStr = strings.ToLower(entityTypeStr)
+
+	file, err := os.Create(filePath)
 	if err != nil {
-		return fmt.Errorf("error converting to JSON: %w", err)
+		return fmt.Errorf("error writing JSON file '%s': %w", filePath, err)
 	}
-	err = os.WriteFile(filePath, jsonData, 0644)
-	if err != nil {
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("error writing JSON file '%s': %w", filePath, closeErr)
+		}
+		if err != nil {
+			os.Remove(filePath)
+		}
+	}()
+	buffered := bufio.NewWriter(file)
+
+	if entityTypeStr == "line" {
+		if err := c.writeStreamedLineExport(ctx, buffered, userName, entityIDStr); err != nil {
+			return err
+		}
+	} else {
+		hierarchyData, err := internalGetEntityHierarchy(c.DB.WithContext(ctx), entityTypeStr, entityIDStr)
+		if err != nil {
+			return transferError(ctx, fmt.Errorf("error loading hierarchy for export: %w", err))
+		}
+		clearParentReferences(hierarchyData)
+		envelope, err := c.newExportEnvelope(userName, entityTypeStr, hierarchyData)
+		if err != nil {
+			return err
+		}
+		if err := json.NewEncoder(buffered).Encode(envelope); err != nil {
+			return fmt.Errorf("error converting to JSON: %w", err)
+		}
+	}
+	if err := buffered.Flush(); err != nil {
 		return fmt.Errorf("error writing JSON file '%s': %w", filePath, err)
 	}
 	log.Printf("Hierarchy successfully exported to '%s'.", filePath)
 	return nil
 }
 
-func (c *Core) ImportEntityHierarchyFromJSON_UseOriginalData(importingUserName string, filePath string) (err error) {
+// ImportEntityHierarchyFromJSON_UseOriginalData imports an export file keeping the IDs stored in it.
+func (c *Core) ImportEntityHierarchyFromJSON_UseOriginalData(importingUserName string, filePath string, parentIDStr string) error {
+	return c.ImportEntityHierarchyFromJSON(importingUserName, filePath, parentIDStr, ImportModeOriginal)
+}
+
+// ImportEntityHierarchyFromJSON imports an export file of any entity type.
+// Lines are imported as roots; every other type needs the ID of the parent it is imported under.
+// Line exports are written station by station while the file is read.
+func (c *Core) ImportEntityHierarchyFromJSON(importingUserName string, filePath string, parentIDStr string, mode string) (err error) {
 	if c.DB == nil {
 		return errors.New("DB not initialized")
 	}
 	if filePath == "" {
 		return errors.New("import filePath is empty")
 	}
-	log.Printf("Starting import from '%s' (using original data from JSON).", filePath)
-	jsonData, err := os.ReadFile(filePath)
+	log.Printf("Starting import from '%s' (mode: %s).", filePath, mode)
+	ctx, finish, err := c.beginTransfer()
 	if err != nil {
-		return fmt.Errorf("error reading JSON file '%s': %w", filePath, err)
-	}
-	var rootImportedLine Line
-	if err = json.Unmarshal(jsonData, &rootImportedLine); err != nil {
-		return fmt.Errorf("error unmarshalling JSON: %w", err)
+		return err
 	}
-	tx := c.DB.Begin()
+	defer finish()
+	tx := c.DB.WithContext(ctx).Begin()
 	if tx.Error != nil {
 		return fmt.Errorf("error starting DB transaction: %w", tx.Error)
 	}
-	var emptyMsSQLID mssql.UniqueIdentifier
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -1267,19 +1659,66 @@ func (c *Core) ImportEntityHierarchyFromJSON_UseOriginalData(importingUserName s
 				log.Printf("Error committing transaction: %v", commitErr)
 				err = commitErr
 			} else {
-				log.Println("Import (Original Data) committed successfully.")
+				log.Printf("Import (%s) committed successfully.", mode)
 			}
 		}
 	}()
-	err = importEntityRecursive_UseOriginalData(tx, &rootImportedLine, "line", emptyMsSQLID)
+	writer, err := c.newImportWriter(tx, importingUserName, mode)
+	if err != nil {
+		return err
+	}
+	err = transferError(ctx, c.importFile(ctx, writer, filePath, parentIDStr))
 	if err == nil {
+		var emptyMsSQLID mssql.UniqueIdentifier
 		if errTimestamp := updateGlobalLastUpdateTimestampAndLogChange(tx, emptyMsSQLID, "system", OpTypeSystemEvent, strPtr(importingUserName), nil); errTimestamp != nil {
 			log.Printf("Warning: failed to update global timestamp and log after successful import: %v", errTimestamp)
 		}
 	}
 	return err
 }
-func importEntityRecursive_UseOriginalData(currentTx *gorm.DB, originalEntityData interface{}, entityTypeStr string, newParentActualID mssql.UniqueIdentifier) error {
+
+// prepareImportRoot checks the target parent of a decoded import and detaches what cannot be imported with it.
+func (c *Core) prepareImportRoot(db *gorm.DB, rootImported interface{}, rootType string, parentIDStr string) (mssql.UniqueIdentifier, error) {
+	var parentID mssql.UniqueIdentifier
+	var err error
+	if rootType == "line" {
+		if parentIDStr != "" {
+			return parentID, errors.New("a line cannot be imported under a parent")
+		}
+	} else {
+		if parentIDStr == "" {
+			return parentID, fmt.Errorf("ParentID is required to import a %s", rootType)
+		}
+		parentID, err = parseMSSQLUniqueIdentifierFromString(parentIDStr)
+		if err != nil {
+			return parentID, fmt.Errorf("invalid ParentID for import: %w", err)
+		}
+		parentModel, _ := getModelInstance(parentEntityType(rootType))
+		if err := db.First(parentModel, "id = ?", parentID).Error; err != nil {
+			return parentID, fmt.Errorf("parent %s with ID %s not found for import: %w", parentEntityType(rootType), parentIDStr, err)
+		}
+	}
+	// Sequence groups of another station cannot be referenced, so loose tools and operations
+	// are imported unassigned, the same way they are pasted from the clipboard.
+	switch e := rootImported.(type) {
+	case *Tool:
+		for i := range e.Operations {
+			e.Operations[i].GroupID = nil
+			e.Operations[i].SequenceGroup = nil
+			e.Operations[i].Sequence = nil
+		}
+	case *Operation:
+		e.GroupID = nil
+		e.SequenceGroup = nil
+		e.Sequence = nil
+	case *SequenceGroup:
+		// Operations of a group belong to tools and are not part of a group import.
+		e.Operations = nil
+	}
+	return parentID, nil
+}
+
+func importEntityRecursive_UseOriginalData(currentTx *gorm.DB, importingUserName string, originalEntityData interface{}, entityTypeStr string, newParentActualID mssql.UniqueIdentifier) error {
 	var currentEntityID mssql.UniqueIdentifier
 	var currentEntityNamePtr *string
 	var childrenToProcess []interface{}
@@ -1346,7 +1785,7 @@ func importEntityRecursive_UseOriginalData(currentTx *gorm.DB, originalEntityDat
 		logName = "<NULL>"
 	}
 	modelToCheck, _ := getModelInstance(entityTypeStr)
-	if err := currentTx.First(modelToCheck, "id = ?", currentEntityID).Error; err == nil {
+	if err := currentTx.Unscoped().First(modelToCheck, "id = ?", currentEntityID).Error; err == nil {
 		return fmt.Errorf("%s ID %s already exists. Import aborted", entityTypeStr, currentEntityID.String())
 	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
 		return fmt.Errorf("DB error checking %s ID %s: %w", entityTypeStr, currentEntityID.String(), err)
@@ -1354,22 +1793,29 @@ func importEntityRecursive_UseOriginalData(currentTx *gorm.DB, originalEntityDat
 	if err := currentTx.Omit(clause.Associations).Create(originalEntityData).Error; err != nil {
 		return fmt.Errorf("error creating imported entity %s '%s' (ID: %s): %w", entityTypeStr, logName, currentEntityID.String(), err)
 	}
+	var logParentID *mssql.UniqueIdentifier
+	if newParentActualID != emptyMsSQLID {
+		logParentID = &newParentActualID
+	}
+	if err := logEntityCreation(currentTx, currentEntityID, entityTypeStr, logParentID, strPtr(importingUserName)); err != nil {
+		return err
+	}
 	if entityTypeStr == "station" {
 		for _, childData := range groupsToProcess {
-			if err := importEntityRecursive_UseOriginalData(currentTx, childData, "sequencegroup", currentEntityID); err != nil {
+			if err := importEntityRecursive_UseOriginalData(currentTx, importingUserName, childData, "sequencegroup", currentEntityID); err != nil {
 				return err
 			}
 		}
 	}
 	for _, childData := range childrenToProcess {
-		if err := importEntityRecursive_UseOriginalData(currentTx, childData, childEntityTypeStr, currentEntityID); err != nil {
+		if err := importEntityRecursive_UseOriginalData(currentTx, importingUserName, childData, childEntityTypeStr, currentEntityID); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (c *Core) CopyEntityHierarchyToClipboard(entityTypeStr string, entityIDStr string) error {
+func (c *Core) CopyEntityHierarchyToClipboard(userName string, entityTypeStr string, entityIDStr string) error {
 	if c.DB == nil {
 		return errors.New("DB not initialized")
 	}
@@ -1422,7 +1868,11 @@ func (c *Core) CopyEntityHierarchyToClipboard(entityTypeStr string, entityIDStr
 		}
 	}
 
-	jsonData, err := json.MarshalIndent(modelInstance, "", "  ")
+	envelope, err := c.newExportEnvelope(userName, entityTypeStr, modelInstance)
+	if err != nil {
+		return err
+	}
+	jsonData, err := json.MarshalIndent(envelope, "", "  ")
 	if err != nil {
 		return fmt.Errorf("error converting to JSON: %w", err)
 	}
@@ -1448,10 +1898,11 @@ func (c *Core) PasteEntityHierarchyFromClipboard(userName string, expectedEntity
 		return fmt.Errorf("error reading from clipboard: %w", err)
 	}
 
-	actualEntityType, err := detectEntityTypeFromClipboard(clipboardData)
+	envelope, err := c.decodeExportPayload([]byte(clipboardData))
 	if err != nil {
-		return fmt.Errorf("could not detect entity type from clipboard: %w", err)
+		return fmt.Errorf("could not read clipboard: %w", err)
 	}
+	actualEntityType := envelope.RootType
 
 	if !strings.EqualFold(actualEntityType, expectedEntityType) {
 		return fmt.Errorf("type mismatch: clipboard contains '%s' but expected '%s'", actualEntityType, expectedEntityType)
@@ -1461,36 +1912,32 @@ func (c *Core) PasteEntityHierarchyFromClipboard(userName string, expectedEntity
 	switch strings.ToLower(expectedEntityType) {
 	case "line":
 		var line Line
-		if err := json.Unmarshal([]byte(clipboardData), &line); err != nil {
+		if err := json.Unmarshal(envelope.Data, &line); err != nil {
 			return fmt.Errorf("clipboard does not contain a valid Line: %w", err)
 		}
 		root = &line
 
 	case "station":
 		var station Station
-		if err := json.Unmarshal([]byte(clipboardData), &station); err != nil {
+		if err := json.Unmarshal(envelope.Data, &station); err != nil {
 			return fmt.Errorf("clipboard does not contain a valid Station: %w", err)
 		}
 		root = &station
 
 	case "tool":
 		var tool Tool
-		if err := json.Unmarshal([]byte(clipboardData), &tool); err != nil {
+		if err := json.Unmarshal(envelope.Data, &tool); err != nil {
 			return fmt.Errorf("clipboard does not contain a valid Tool: %w", err)
 		}
 		root = &tool
 
 	case "operation":
 		var op Operation
-		if err := json.Unmarshal([]byte(clipboardData), &op); err != nil {
+		if err := json.Unmarshal(envelope.Data, &op); err != nil {
 			return fmt.Errorf("clipboard does not contain a valid Operation: %w", err)
 		}
 		root = &op
 
-		if err := c.checkCompatibility(op, parentIDStrOptional); err != nil {
-			return err
-		}
-
 	default:
 		return fmt.Errorf("unknown expected entity type: '%s'", expectedEntityType)
 	}
@@ -1502,6 +1949,9 @@ func (c *Core) PasteEntityHierarchyFromClipboard(userName string, expectedEntity
 			return fmt.Errorf("invalid parent ID: %w", err)
 		}
 	}
+	if err := c.checkImportCatalog(c.DB, root, expectedEntityType, parentID); err != nil {
+		return err
+	}
 
 	tx := c.DB.Begin()
 	if tx.Error != nil {
@@ -1547,93 +1997,67 @@ type StationType struct {
 	SOP  []string `json:"serialOrParallel"`
 }
 
-type Data struct {
-	ToolClasses  []ToolClass   `json:"ToolClasses"`
-	StationTypes []StationType `json:"StationTypes"`
+type ToolType struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	ToolClassID string `json:"toolClassId"`
 }
 
-func (c *Core) checkCompatibility(op Operation, parentIDStrOptional string) error {
+type Template struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
 
-	var data Data
-	var jsonData []byte
-	var err error
+// CatalogClass is an entry of one of the class lists of the dependency catalog. Generation, saving
+// and verification classes belong to a single template, decision classes to several.
+type CatalogClass struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	TemplateID  string   `json:"templateId"`
+	TemplateIDs []string `json:"templateIds"`
+}
+
+type Data struct {
+	ToolClasses         []ToolClass    `json:"ToolClasses"`
+	ToolTypes           []ToolType     `json:"ToolTypes"`
+	StationTypes        []StationType  `json:"StationTypes"`
+	Templates           []Template     `json:"Templates"`
+	DecisionClasses     []CatalogClass `json:"DecisionClasses"`
+	GenerationClasses   []CatalogClass `json:"GenerationClasses"`
+	SavingClasses       []CatalogClass `json:"SavingClasses"`
+	VerificationClasses []CatalogClass `json:"VerificationClasses"`
+	SerialOrParallel    []CatalogClass `json:"SerialOrParallel"`
+	QGateRelevant       []CatalogClass `json:"QGateRelevant"`
+}
 
+// readDependencyJSON returns the raw dependency catalog shared with the frontend.
+func (c *Core) readDependencyJSON() ([]byte, error) {
 	// Try to use embedded dependency JSON first (production mode)
 	if len(c.dependencyJSON) > 0 {
-		jsonData = c.dependencyJSON
-	} else {
-		// Fallback to reading from file system (development mode)
-		jsonData, err = os.ReadFile("frontend/src/assets/dependency.json")
-		if err != nil {
-			return fmt.Errorf("error reading dependency.json file: %v", err)
-		}
-	}
-
-	var toolDetails, errTwo = c.GetEntityDetails("tool", parentIDStrOptional)
-	if errTwo != nil {
-		return fmt.Errorf("failed to get entity details: %w", errTwo)
+		return c.dependencyJSON, nil
 	}
-
-	if errThree := json.Unmarshal(jsonData, &data); errThree != nil {
-		return fmt.Errorf("error unmarshaling dependency JSON: %w", errThree)
+	// Fallback to reading from file system (development mode)
+	jsonData, err := os.ReadFile("frontend/src/assets/dependency.json")
+	if err != nil {
+		return nil, fmt.Errorf("error reading dependency.json file: %v", err)
 	}
+	return jsonData, nil
+}
 
-	if !(op.Template == nil || *op.Template == "none" || *op.Template == "") {
-		var parentToolClass = toolDetails.(*Tool).ToolClass
-		var toolClassTemplateIDs []string
-		var foundToolClass bool = false
-		var compatibleWithToolClass bool = false
-		for _, toolClass := range data.ToolClasses {
-			if toolClass.ID == *parentToolClass {
-				foundToolClass = true
-				toolClassTemplateIDs = toolClass.TemplateIDs
-				break
-			}
-		}
-		if !foundToolClass {
-			return fmt.Errorf("tool class '%s' not found in dependency data", *parentToolClass)
-		}
-		for _, templateID := range toolClassTemplateIDs {
-			if *op.Template == templateID {
-				compatibleWithToolClass = true
-				break
-			}
-		}
-		if !compatibleWithToolClass {
-			return fmt.Errorf("operation template id '%s' is not compatible with tool class '%s'", *op.Template, *parentToolClass)
-		}
+// loadDependencyData reads the dependency catalog shared with the frontend.
+func (c *Core) loadDependencyData() (*Data, error) {
+	var data Data
+	jsonData, err := c.readDependencyJSON()
+	if err != nil {
+		return nil, err
 	}
-	if !(op.SerialOrParallel == nil || *op.SerialOrParallel == "none" || *op.SerialOrParallel == "") {
-		var stationDetails, errTwo = c.GetEntityDetails("station", toolDetails.(*Tool).ParentID.String())
-		if errTwo != nil {
-			return fmt.Errorf("failed to get entity details: %w", errTwo)
-		}
 
-		var grandParentStationType = stationDetails.(*Station).StationType
-		var stationTypeSOPs []string
-		var foundStationType bool = false
-		var compatibleWithSOP bool = false
-		for _, stationType := range data.StationTypes {
-			if stationType.ID == *grandParentStationType {
-				foundStationType = true
-				stationTypeSOPs = stationType.SOP
-				break
-			}
-		}
-		if !foundStationType {
-			return fmt.Errorf("station type id '%s' not found in dependency data", *grandParentStationType)
-		}
-		for _, SOP := range stationTypeSOPs {
-			if *op.SerialOrParallel == SOP {
-				compatibleWithSOP = true
-				break
-			}
-		}
-		if !compatibleWithSOP {
-			return fmt.Errorf("operation serial or parallel id '%s' is not compatible with tool class '%s'", *op.SerialOrParallel, *grandParentStationType)
-		}
+	if err := json.Unmarshal(jsonData, &data); err != nil {
+		return nil, fmt.Errorf("error unmarshaling dependency JSON: %w", err)
 	}
-	return nil
+	return &data, nil
 }
 
 func detectEntityTypeFromClipboard(clipboardData string) (string, error) {
@@ -1658,6 +2082,11 @@ func detectEntityTypeFromClipboard(clipboardData string) (string, error) {
 			return "tool", nil
 		}
 	}
+	if _, hasIndex := tempMap["Index"]; hasIndex {
+		if _, hasOperations := tempMap["Operations"]; hasOperations {
+			return "sequencegroup", nil
+		}
+	}
 	if _, hasDecisionCriteria := tempMap["DecisionCriteria"]; hasDecisionCriteria {
 		if _, hasSequenceGroup := tempMap["SequenceGroup"]; hasSequenceGroup {
 			return "operation", nil
@@ -1721,6 +2150,9 @@ func importCopiedEntityRecursive(
 		if err := tx.Create(&newLine).Error; err != nil {
 			return newUUID, fmt.Errorf("failed to insert new Line: %w", err)
 		}
+		if err := logEntityCreation(tx, newLine.ID, "line", nil, strPtr(userName)); err != nil {
+			return newUUID, err
+		}
 		idMap[e.ID] = newLine.ID
 		for i := range e.Stations {
 			_, err := importCopiedEntityRecursive(tx, userName, &e.Stations[i], "station", newLine.ID, idMap)
@@ -1742,7 +2174,17 @@ func importCopiedEntityRecursive(
 		if err := tx.Create(&newStation).Error; err != nil {
 			return newUUID, fmt.Errorf("failed to insert new Station: %w", err)
 		}
+		if err := logEntityCreation(tx, newStation.ID, "station", &newParentID, strPtr(userName)); err != nil {
+			return newUUID, err
+		}
 		idMap[e.ID] = newStation.ID
+		// Groups come first so the operations below the tools can be linked to their new IDs.
+		for i := range e.SequenceGroups {
+			_, err := importCopiedEntityRecursive(tx, userName, &e.SequenceGroups[i], "sequencegroup", newStation.ID, idMap)
+			if err != nil {
+				return newUUID, err
+			}
+		}
 		for i := range e.Tools {
 			_, err := importCopiedEntityRecursive(tx, userName, &e.Tools[i], "tool", newStation.ID, idMap)
 			if err != nil {
@@ -1770,6 +2212,9 @@ func importCopiedEntityRecursive(
 		if err := tx.Create(&newTool).Error; err != nil {
 			return newUUID, fmt.Errorf("failed to insert new Tool: %w", err)
 		}
+		if err := logEntityCreation(tx, newTool.ID, "tool", &newParentID, strPtr(userName)); err != nil {
+			return newUUID, err
+		}
 		idMap[e.ID] = newTool.ID
 		for i := range e.Operations {
 			_, err := importCopiedEntityRecursive(tx, userName, &e.Operations[i], "operation", newTool.ID, idMap)
@@ -1779,7 +2224,28 @@ func importCopiedEntityRecursive(
 		}
 		return newTool.ID, nil
 
+	case *SequenceGroup:
+		newGroup := SequenceGroup{
+			BaseModel: createBaseFromOriginal(e.BaseModel, userName),
+			ParentID:  newParentID,
+			Index:     e.Index,
+		}
+		if err := tx.Create(&newGroup).Error; err != nil {
+			return newUUID, fmt.Errorf("failed to insert new SequenceGroup: %w", err)
+		}
+		if err := logEntityCreation(tx, newGroup.ID, "sequencegroup", &newParentID, strPtr(userName)); err != nil {
+			return newUUID, err
+		}
+		idMap[e.ID] = newGroup.ID
+		return newGroup.ID, nil
+
 	case *Operation:
+		groupID := e.GroupID
+		if groupID != nil {
+			if newGroupID, copied := idMap[*groupID]; copied {
+				groupID = &newGroupID
+			}
+		}
 		newOp := Operation{
 			BaseModel:         createBaseFromOriginal(e.BaseModel, userName),
 			Description:       e.Description,
@@ -1795,11 +2261,14 @@ func importCopiedEntityRecursive(
 			VerificationClass: e.VerificationClass,
 			GenerationClass:   e.GenerationClass,
 			ParentID:          newParentID,
-			GroupID:           e.GroupID,
+			GroupID:           groupID,
 		}
 		if err := tx.Create(&newOp).Error; err != nil {
 			return newUUID, fmt.Errorf("failed to insert new Operation: %w", err)
 		}
+		if err := logEntityCreation(tx, newOp.ID, "operation", &newParentID, strPtr(userName)); err != nil {
+			return newUUID, err
+		}
 		idMap[e.ID] = newOp.ID
 		return newOp.ID, nil
 
@@ -1819,6 +2288,8 @@ func getHistoryModelInstance(entityTypeStr string) (interface{}, error) {
 		return &ToolHistory{}, nil
 	case "operation":
 		return &OperationHistory{}, nil
+	case "sequencegroup":
+		return &SequenceGroupHistory{}, nil
 	default:
 		return nil, fmt.Errorf("unknown entity type for history model: %s", entityTypeStr)
 	}
@@ -1832,50 +2303,75 @@ func (c *Core) DeleteEntityByIDString(userName string, entityTypeStr string, ent
 	if err != nil {
 		return err
 	}
+	return c.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteEntityTx(tx, userName, entityTypeStr, entityIDmssql, c.hardDeleteEnabled())
+	})
+}
+
+// deleteEntityTx deletes an entity together with its children inside an existing transaction.
+func deleteEntityTx(tx *gorm.DB, userName string, entityTypeStr string, entityIDmssql mssql.UniqueIdentifier, hardDelete bool) error {
+	entityIDStr := entityIDmssql.String()
 	// Sonderfall: sequencegroup - erst alle Operationen auf unassigned setzen, dann die Gruppe löschen
 	if strings.ToLower(entityTypeStr) == "sequencegroup" {
-		return c.DB.Transaction(func(tx *gorm.DB) error {
-			// 1. Erst prüfen, ob die Sequenzgruppe existiert
-			modelInstance, err := getModelInstance(entityTypeStr)
-			if err != nil {
-				return err
-			}
-			if err := tx.First(modelInstance, "id = ?", entityIDmssql).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					return fmt.Errorf("no entity %s with ID %s found to delete", entityTypeStr, entityIDStr)
-				}
-				return fmt.Errorf("error finding entity %s with ID %s for delete: %w", entityTypeStr, entityIDStr, err)
-			} // 2. Alle Operationen, die zu dieser Sequenzgruppe gehören, auf unassigned setzen
-			updateResult := tx.Model(&Operation{}).
-				Where("group_id = ?", entityIDmssql).
-				Updates(map[string]interface{}{
-					"group_id":       nil,
-					"sequence_group": nil,
-					"sequence":       nil,
-					"updated_by":     userName,
-					"updated_at":     time.Now(),
-				})
-
-			if updateResult.Error != nil {
-				return fmt.Errorf("error updating operations before deleting sequence group: %w", updateResult.Error)
+		// 1. Erst prüfen, ob die Sequenzgruppe existiert
+		modelInstance, err := getModelInstance(entityTypeStr)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(modelInstance, "id = ?", entityIDmssql).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("no entity %s with ID %s found to delete", entityTypeStr, entityIDStr)
 			}
+			return fmt.Errorf("error finding entity %s with ID %s for delete: %w", entityTypeStr, entityIDStr, err)
+		}
 
-			// 3. Jetzt die Sequenzgruppe sicher löschen
-			result := tx.Delete(modelInstance)
+		// 2. Zuordnungen merken, damit der Papierkorb sie wiederherstellen kann.
+		// Operationen im Papierkorb zählen mit, sonst zeigen sie nach ihrer Wiederherstellung ins Leere.
+		var linkedOps []Operation
+		if err := tx.Unscoped().Where("group_id = ?", entityIDmssql).Find(&linkedOps).Error; err != nil {
+			return fmt.Errorf("error loading operations of sequence group: %w", err)
+		}
+
+		// 3. Alle Operationen, die zu dieser Sequenzgruppe gehören, auf unassigned setzen
+		updateResult := tx.Unscoped().Model(&Operation{}).
+			Where("group_id = ?", entityIDmssql).
+			Updates(map[string]interface{}{
+				"group_id":       nil,
+				"sequence_group": nil,
+				"sequence":       nil,
+				"updated_by":     userName,
+				"updated_at":     time.Now(),
+			})
+
+		if updateResult.Error != nil {
+			return fmt.Errorf("error updating operations before deleting sequence group: %w", updateResult.Error)
+		}
+
+		// 4. Jetzt die Sequenzgruppe löschen (endgültig oder in den Papierkorb)
+		if hardDelete {
+			if err := tx.Where("entity_id = ?", entityIDmssql).Delete(&SequenceGroupHistory{}).Error; err != nil {
+				return fmt.Errorf("failed to delete history for %s: %w", entityTypeStr, err)
+			}
+			result := tx.Unscoped().Delete(modelInstance)
 			if result.Error != nil {
 				return fmt.Errorf("error deleting %s with ID %s: %w", entityTypeStr, entityIDStr, result.Error)
 			}
 			if result.RowsAffected == 0 {
 				return fmt.Errorf("no entity %s with ID %s actually deleted", entityTypeStr, entityIDStr)
 			}
-
-			// 4. Globalen Timestamp und Changelog aktualisieren
-			if logErr := updateGlobalLastUpdateTimestampAndLogChange(tx, entityIDmssql, entityTypeStr, OpTypeDelete, strPtr(userName), nil); logErr != nil {
-				return fmt.Errorf("error logging delete for %s %s: %w", entityTypeStr, entityIDStr, logErr)
+		} else {
+			subtree := map[string][]string{"sequencegroup": {entityIDmssql.String()}}
+			if err := moveToRecycleBin(tx, userName, "sequencegroup", modelInstance, subtree, linkedOps); err != nil {
+				return err
 			}
+		}
+
+		// 5. Globalen Timestamp und Changelog aktualisieren
+		if logErr := updateGlobalLastUpdateTimestampAndLogChange(tx, entityIDmssql, entityTypeStr, OpTypeDelete, strPtr(userName), nil); logErr != nil {
+			return fmt.Errorf("error logging delete for %s %s: %w", entityTypeStr, entityIDStr, logErr)
+		}
 
-			return nil
-		})
+		return nil
 	}
 
 	modelInstance, err := getModelInstance(entityTypeStr)
@@ -1883,58 +2379,51 @@ func (c *Core) DeleteEntityByIDString(userName string, entityTypeStr string, ent
 		return err
 	}
 
-	if err := c.DB.First(modelInstance, "id = ?", entityIDmssql).Error; err != nil {
+	if err := tx.First(modelInstance, "id = ?", entityIDmssql).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return fmt.Errorf("no entity %s with ID %s found to delete", entityTypeStr, entityIDStr)
 		}
 		return fmt.Errorf("error finding entity %s with ID %s for delete: %w", entityTypeStr, entityIDStr, err)
 	}
 
-	// Before deleting, collect all child IDs that will be cascade-deleted.
-	allIDsToDelete, err := c.collectAllChildIDs(strings.ToLower(entityTypeStr), entityIDmssql)
+	// Before deleting, collect all child IDs that will be deleted with the entity.
+	// A hard delete also removes children that already sit in the recycle bin.
+	collectDB := tx.Session(&gorm.Session{})
+	if hardDelete {
+		collectDB = tx.Unscoped().Session(&gorm.Session{})
+	}
+	allIDsToDelete, err := collectChildIDs(collectDB, strings.ToLower(entityTypeStr), entityIDmssql)
 	if err != nil {
 		return fmt.Errorf("failed to collect child IDs before delete: %w", err)
 	}
 
-	err = c.DB.Transaction(func(tx *gorm.DB) error {
-		// 1. Delete all history records for the entity and all its children.
-		for entityType, ids := range allIDsToDelete {
-			historyModel, err := getHistoryModelInstance(entityType)
-			if err != nil {
-				return err // Should not happen with validated types.
-			}
-
-			if len(ids) > 0 {
-				if err := tx.Where("entity_id IN (?)", ids).Delete(historyModel).Error; err != nil {
-					return fmt.Errorf("failed to delete history for %s: %w", entityType, err)
-				}
-			}
-		}
-
-		// 2. Delete the live record (cascades are handled by DB foreign key constraints).
-		result := tx.Delete(modelInstance)
-		if result.Error != nil {
-			return fmt.Errorf("error deleting %s with ID %s: %w", entityTypeStr, entityIDStr, result.Error)
+	// gorm turns a Delete on an entity into an update of deleted_at, so the foreign key cascades
+	// only apply to the Unscoped delete in purgeSubtree. A soft delete tombstones every
+	// collected child itself.
+	if hardDelete {
+		if err := purgeSubtree(tx, modelInstance, allIDsToDelete); err != nil {
+			return fmt.Errorf("error deleting %s with ID %s: %w", entityTypeStr, entityIDStr, err)
 		}
-		if result.RowsAffected == 0 {
-			return fmt.Errorf("no entity %s with ID %s actually deleted", entityTypeStr, entityIDStr)
+	} else {
+		// Soft delete: the whole subtree is tombstoned and can be restored from the recycle bin.
+		if err := moveToRecycleBin(tx, userName, strings.ToLower(entityTypeStr), modelInstance, allIDsToDelete, nil); err != nil {
+			return err
 		}
+	}
 
-		// 3. Log delete operations for all affected entities and update global timestamp.
-		for entityType, ids := range allIDsToDelete {
-			for _, idStr := range ids {
-				entityID, parseErr := parseMSSQLUniqueIdentifierFromString(idStr)
-				if parseErr != nil {
-					return fmt.Errorf("error parsing entity ID %s for logging: %w", idStr, parseErr)
-				}
-				if logErr := updateGlobalLastUpdateTimestampAndLogChange(tx, entityID, entityType, OpTypeDelete, strPtr(userName), nil); logErr != nil {
-					return fmt.Errorf("error logging delete for %s %s: %w", entityType, idStr, logErr)
-				}
+	// Log delete operations for all affected entities and update global timestamp.
+	for entityType, ids := range allIDsToDelete {
+		for _, idStr := range ids {
+			entityID, parseErr := parseMSSQLUniqueIdentifierFromString(idStr)
+			if parseErr != nil {
+				return fmt.Errorf("error parsing entity ID %s for logging: %w", idStr, parseErr)
+			}
+			if logErr := updateGlobalLastUpdateTimestampAndLogChange(tx, entityID, entityType, OpTypeDelete, strPtr(userName), nil); logErr != nil {
+				return fmt.Errorf("error logging delete for %s %s: %w", entityType, idStr, logErr)
 			}
 		}
-		return nil
-	})
-	return err
+	}
+	return nil
 }
 
 // GetEntityVersions retrieves all historical versions for a given entity, sorted from newest to oldest.
@@ -1967,6 +2456,10 @@ func (c *Core) GetEntityVersions(entityTypeStr string, entityIDStr string) (inte
 		var results []OperationHistory
 		err = query.Find(&results).Error
 		return results, err
+	case "sequencegroup":
+		var results []SequenceGroupHistory
+		err = query.Find(&results).Error
+		return results, err
 	default:
 		return nil, fmt.Errorf("unsupported entity type for version history: %s", entityTypeStr)
 	}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeChangedFields(t *testing.T) {
+	empty := ""
+	tests := []struct {
+		name string
+		raw  *string
+		want map[string]FieldChange
+	}{
+		{name: "no value", raw: nil, want: map[string]FieldChange{}},
+		{name: "empty", raw: &empty, want: map[string]FieldChange{}},
+		{name: "invalid JSON", raw: strPtr(`{"Name":`), want: map[string]FieldChange{}},
+		{name: "not an object", raw: strPtr(`["Name"]`), want: map[string]FieldChange{}},
+		{
+			name: "old and new values",
+			raw:  strPtr(`{"Name":{"old":"a","new":"b"},"Comment":{"old":null,"new":"x"}}`),
+			want: map[string]FieldChange{
+				"Name":    {Old: strPtr("a"), New: strPtr("b")},
+				"Comment": {New: strPtr("x")},
+			},
+		},
+		{
+			name: "older entries with new values only",
+			raw:  strPtr(`{"Name":"b","Description":""}`),
+			want: map[string]FieldChange{
+				"Name":        {New: strPtr("b")},
+				"Description": {New: &empty},
+			},
+		},
+		{
+			name: "both formats",
+			raw:  strPtr(`{"Name":"b","Sequence":{"old":"1","new":"2"}}`),
+			want: map[string]FieldChange{
+				"Name":     {New: strPtr("b")},
+				"Sequence": {Old: strPtr("1"), New: strPtr("2")},
+			},
+		},
+		{
+			name: "values of another type are left out",
+			raw:  strPtr(`{"Sequence":5,"Name":"b"}`),
+			want: map[string]FieldChange{"Name": {New: strPtr("b")}},
+		},
+		{
+			name: "cleared field",
+			raw:  strPtr(`{"Comment":{"old":"x","new":null}}`),
+			want: map[string]FieldChange{"Comment": {Old: strPtr("x")}},
+		},
+	}
+	for _, tt := range tests {
+		got := decodeChangedFields(tt.raw)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: decodeChangedFields = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -18,6 +18,10 @@ type BaseModel struct {
 	UpdatedAt   time.Time              `gorm:"type:datetime2"`
 	CreatedBy   *string                `gorm:"size:255;default:null"`
 	UpdatedBy   *string                `gorm:"size:255;default:null"`
+	// DeletedAt tombstones an entity in the recycle bin. gorm leaves tombstoned rows out of every
+	// query on the entity tables; code that needs them (restore, purge, history) uses Unscoped.
+	// The history, change log and metadata tables do not embed BaseModel and are not affected.
+	DeletedAt gorm.DeletedAt `gorm:"type:datetime2;index"`
 }
 
 func (base *BaseModel) BeforeCreate(tx *gorm.DB) (err error) {
@@ -48,6 +52,8 @@ type Station struct {
 	ParentID       mssql.UniqueIdentifier `gorm:"type:uniqueidentifier;index"`
 	Tools          []Tool                 `gorm:"foreignKey:ParentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	SequenceGroups []SequenceGroup        `gorm:"foreignKey:ParentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	// Line is only loaded by internalGetEntityHierarchy; exports leave it out.
+	Line *Line `gorm:"-" json:",omitempty"`
 }
 
 type Tool struct {
@@ -64,6 +70,8 @@ type Tool struct {
 	SPSAddressInReceiveDB *string                `gorm:"default:null"`
 	ParentID              mssql.UniqueIdentifier `gorm:"type:uniqueidentifier;index"`
 	Operations            []Operation            `gorm:"foreignKey:ParentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	// Station is only loaded by internalGetEntityHierarchy; exports leave it out.
+	Station *Station `gorm:"-" json:",omitempty"`
 }
 
 type Operation struct {
@@ -82,6 +90,9 @@ type Operation struct {
 	ParentID          mssql.UniqueIdentifier  `gorm:"type:uniqueidentifier;index"`
 	GroupID           *mssql.UniqueIdentifier `gorm:"type:uniqueidentifier;index"`
 	SequenceGroup     *string                 `gorm:"default:null"`
+	// Tool and Group are only loaded by internalGetEntityHierarchy; exports leave them out.
+	Tool  *Tool          `gorm:"-" json:",omitempty"`
+	Group *SequenceGroup `gorm:"-" json:",omitempty"`
 }
 
 type SequenceGroup struct {
@@ -89,6 +100,8 @@ type SequenceGroup struct {
 	ParentID   mssql.UniqueIdentifier `gorm:"type:uniqueidentifier;index"`
 	Index      *string                `gorm:"size:255;default:null"`
 	Operations []Operation            `gorm:"foreignKey:GroupID;"`
+	// Station is only loaded by internalGetEntityHierarchy; exports leave it out.
+	Station *Station `gorm:"-" json:",omitempty"`
 }
 
 // History Models for Versioning
@@ -178,21 +191,64 @@ type OperationHistory struct {
 
 func (OperationHistory) TableName() string { return "operation_histories" }
 
+type SequenceGroupHistory struct {
+	Version     int                    `gorm:"primaryKey;autoIncrement:false"`
+	EntityID    mssql.UniqueIdentifier `gorm:"type:uniqueidentifier;primaryKey"`
+	Name        *string                `gorm:"size:255;default:null"`
+	Comment     *string                `gorm:"default:null"`
+	StatusColor *string                `gorm:"size:255;default:null"`
+	CreatedAt   time.Time              `gorm:"type:datetime2"`
+	UpdatedAt   time.Time              `gorm:"type:datetime2"`
+	CreatedBy   *string                `gorm:"size:255;default:null"`
+	UpdatedBy   *string                `gorm:"size:255;default:null"`
+	Index       *string                `gorm:"size:255;default:null"`
+	ParentID    mssql.UniqueIdentifier `gorm:"type:uniqueidentifier;index"`
+}
+
+func (SequenceGroupHistory) TableName() string { return "sequence_group_histories" }
+
 // --- End of History Models ---
 
+// LineBaseline is an immutable, labelled snapshot of a whole line hierarchy stored in the export JSON format.
+type LineBaseline struct {
+	ID        mssql.UniqueIdentifier `gorm:"type:uniqueidentifier;primary_key"`
+	LineID    mssql.UniqueIdentifier `gorm:"type:uniqueidentifier;uniqueIndex:idx_line_baseline_label"`
+	Label     string                 `gorm:"size:255;uniqueIndex:idx_line_baseline_label"`
+	Note      *string                `gorm:"default:null"`
+	CreatedAt time.Time              `gorm:"type:datetime2"`
+	CreatedBy *string                `gorm:"size:255;default:null"`
+	Snapshot  string                 `gorm:"type:nvarchar(max)"`
+}
+
+// RecycleBinEntry marks the root of a soft-deleted subtree.
+// All descendants deleted together with the root share its DeletedAt timestamp.
+type RecycleBinEntry struct {
+	EntityID      mssql.UniqueIdentifier  `gorm:"type:uniqueidentifier;primaryKey"`
+	EntityType    string                  `gorm:"size:50;index"`
+	Name          *string                 `gorm:"size:255;default:null"`
+	ParentID      *mssql.UniqueIdentifier `gorm:"type:uniqueidentifier;default:null"`
+	DeletedAt     time.Time               `gorm:"type:datetime2;index"`
+	DeletedBy     *string                 `gorm:"size:255;default:null"`
+	SequenceLinks *string                 `gorm:"default:null"`
+}
+
 type AppMetadata struct {
 	ConfigKey  string    `gorm:"primaryKey;size:50"`
 	LastUpdate time.Time `gorm:"type:datetime2"`
+	// Value holds the JSON of a setting stored under ConfigKey, see loadSetting.
+	Value *string `gorm:"default:null"`
 }
 
 type EntityChangeLog struct {
-	LogID           mssql.UniqueIdentifier `gorm:"type:uniqueidentifier;primary_key;default:newsequentialid()"`
-	EntityID        mssql.UniqueIdentifier `gorm:"type:uniqueidentifier;index"`
-	EntityType      string                 `gorm:"size:50;index"`
-	OperationType   string                 `gorm:"size:20"`
-	ChangedFields   *string                `gorm:"default:null"`
-	ChangeTime      time.Time              `gorm:"type:datetime2;index"`
-	ChangedByUserID *string                `gorm:"size:255;default:null"`
+	LogID           mssql.UniqueIdentifier  `gorm:"type:uniqueidentifier;primary_key;default:newsequentialid()"`
+	EntityID        mssql.UniqueIdentifier  `gorm:"type:uniqueidentifier;index"`
+	EntityType      string                  `gorm:"size:50;index"`
+	OperationType   string                  `gorm:"size:20"`
+	ChangedFields   *string                 `gorm:"default:null"`
+	ChangeTime      time.Time               `gorm:"type:datetime2;index"`
+	ChangedByUserID *string                 `gorm:"size:255;default:null"`
+	ParentID        *mssql.UniqueIdentifier `gorm:"type:uniqueidentifier;default:null"`
+	ParentType      *string                 `gorm:"size:50;default:null"`
 }
 
 func (logEntry *EntityChangeLog) BeforeCreate(tx *gorm.DB) (err error) {
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// The DB-backed tests run against SQLite instead of SQL Server.
+
+// uuidPattern matches IDs passed to queries as strings.
+var uuidPattern = regexp.MustCompile(`^[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}$`)
+
+// uuidArgs converts IDs passed as strings into the binary form the ID columns are stored in.
+// SQL Server converts them itself when comparing them with uniqueidentifier columns.
+func uuidArgs(args []interface{}) []interface{} {
+	for i, arg := range args {
+		if s, ok := arg.(string); ok && uuidPattern.MatchString(s) {
+			if id, err := parseMSSQLUniqueIdentifierFromString(s); err == nil {
+				args[i], _ = id.Value()
+			}
+		}
+	}
+	return args
+}
+
+type testConnPool struct{ db *sql.DB }
+
+func (p testConnPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return p.db.PrepareContext(ctx, query)
+}
+
+func (p testConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return p.db.ExecContext(ctx, query, uuidArgs(args)...)
+}
+
+func (p testConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return p.db.QueryContext(ctx, query, uuidArgs(args)...)
+}
+
+func (p testConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return p.db.QueryRowContext(ctx, query, uuidArgs(args)...)
+}
+
+func (p testConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	tx, err := p.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &testTx{tx}, nil
+}
+
+type testTx struct{ tx *sql.Tx }
+
+func (t *testTx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return t.tx.PrepareContext(ctx, query)
+}
+
+func (t *testTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, query, uuidArgs(args)...)
+}
+
+func (t *testTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, query, uuidArgs(args)...)
+}
+
+func (t *testTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRowContext(ctx, query, uuidArgs(args)...)
+}
+
+func (t *testTx) Commit() error   { return t.tx.Commit() }
+func (t *testTx) Rollback() error { return t.tx.Rollback() }
+
+// newTestCore returns a Core on an empty, migrated database in a temporary folder.
+func newTestCore(t *testing.T) *Core {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "cep.db") + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+	sqlDB, err := sql.Open(sqlite.DriverName, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(&sqlite.Dialector{Conn: testConnPool{sqlDB}}, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// SQLite knows neither newsequentialid() nor the SQL Server types nvarchar(max) and datetime2.
+	// The log IDs are set in EntityChangeLog.BeforeCreate anyway. Nor does it accept DEFAULT in
+	// the VALUES of a batch insert, which gorm writes for nil fields with a default of null.
+	models := []interface{}{
+		&Line{}, &Station{}, &Tool{}, &Operation{}, &SequenceGroup{},
+		&AppMetadata{}, &EntityChangeLog{}, &RecycleBinEntry{}, &LineBaseline{},
+		&LineHistory{}, &StationHistory{}, &ToolHistory{}, &OperationHistory{}, &SequenceGroupHistory{},
+	}
+	for _, model := range models {
+		// The parsed schema is cached, so the migration and all queries use the changed fields.
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+		var withDefault []*schema.Field
+		for _, field := range stmt.Schema.FieldsWithDefaultDBValue {
+			if field.DefaultValue != "null" {
+				withDefault = append(withDefault, field)
+			}
+		}
+		stmt.Schema.FieldsWithDefaultDBValue = withDefault
+		for _, field := range stmt.Schema.Fields {
+			if field.DefaultValue == "null" {
+				field.HasDefaultValue = false
+				field.DefaultValue = ""
+			}
+			switch {
+			case field.DefaultValue == "newsequentialid()":
+				field.HasDefaultValue = false
+				field.DefaultValue = ""
+			case field.DataType == "nvarchar(max)":
+				field.DataType = schema.String
+				delete(field.TagSettings, "TYPE")
+			case field.DataType == "datetime2":
+				field.DataType = schema.Time
+				delete(field.TagSettings, "TYPE")
+			}
+		}
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	ensureAppMetadataExists(db)
+	catalogJSON, err := json.Marshal(testCatalog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Core{DB: db, dependencyJSON: catalogJSON, sourceDB: "test/cep"}
+}
+
+// createTestLine creates a line with one station of type 1, a tool of class 1 and an operation with template 1.
+func createTestLine(t *testing.T, db *gorm.DB) (*Line, *Station, *Tool, *Operation) {
+	t.Helper()
+	line := &Line{BaseModel: BaseModel{Name: strPtr("L1")}}
+	if err := db.Create(line).Error; err != nil {
+		t.Fatal(err)
+	}
+	station := &Station{BaseModel: BaseModel{Name: strPtr("S1")}, StationType: strPtr("1"), ParentID: line.ID}
+	if err := db.Create(station).Error; err != nil {
+		t.Fatal(err)
+	}
+	tool := &Tool{BaseModel: BaseModel{Name: strPtr("T1")}, ToolClass: strPtr("1"), ParentID: station.ID}
+	if err := db.Create(tool).Error; err != nil {
+		t.Fatal(err)
+	}
+	op := &Operation{BaseModel: BaseModel{Name: strPtr("O1")}, Template: strPtr("1"), SerialOrParallel: strPtr("1"), ParentID: tool.ID}
+	if err := db.Create(op).Error; err != nil {
+		t.Fatal(err)
+	}
+	return line, station, tool, op
+}
+
+func TestTestCoreStringIDs(t *testing.T) {
+	c := newTestCore(t)
+	_, station, _, _ := createTestLine(t, c.DB)
+	var found Station
+	if err := c.DB.First(&found, "id = ?", station.ID.String()).Error; err != nil {
+		t.Fatalf("station not found by its ID as string: %v", err)
+	}
+	var listed []Station
+	if err := c.DB.Where("id IN ?", []string{station.ID.String()}).Find(&listed).Error; err != nil || len(listed) != 1 {
+		t.Fatalf("station not found in a list of string IDs: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const exportFormatName = "cep-export"
+
+// currentExportSchemaVersion is written into every export. Version 1 is the bare entity JSON
+// that was written before exports were wrapped in an ExportEnvelope.
+const currentExportSchemaVersion = 2
+
+// ExportEnvelope wraps exported entities and clipboard payloads with their metadata.
+type ExportEnvelope struct {
+	Format                string          `json:"format"`
+	SchemaVersion         int             `json:"schemaVersion"`
+	RootType              string          `json:"rootType"`
+	ExportedAt            time.Time       `json:"exportedAt"`
+	ExportedBy            string          `json:"exportedBy"`
+	SourceDB              string          `json:"sourceDB"`
+	DependencyCatalogHash string          `json:"dependencyCatalogHash"`
+	Data                  json.RawMessage `json:"data"`
+}
+
+// exportMigrations upgrade an envelope from the schema version they are keyed by to the next one.
+var exportMigrations = map[int]func(*ExportEnvelope) error{
+	1: migrateExportV1ToV2,
+}
+
+// migrateExportV1ToV2 determines the root type of a bare export from its field names.
+func migrateExportV1ToV2(envelope *ExportEnvelope) error {
+	rootType, err := detectEntityTypeFromClipboard(string(envelope.Data))
+	if err != nil {
+		return err
+	}
+	envelope.RootType = rootType
+	return nil
+}
+
+// dependencyCatalogHash identifies the dependency catalog the IDs of an export refer to.
+func (c *Core) dependencyCatalogHash() string {
+	jsonData, err := c.readDependencyJSON()
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(jsonData)
+	return hex.EncodeToString(sum[:])
+}
+
+// newExportEnvelope wraps data for an export by userName, the name the app records in the change log.
+func (c *Core) newExportEnvelope(userName string, rootType string, data interface{}) (*ExportEnvelope, error) {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("error converting to JSON: %w", err)
+	}
+	return &ExportEnvelope{
+		Format:                exportFormatName,
+		SchemaVersion:         currentExportSchemaVersion,
+		RootType:              rootType,
+		ExportedAt:            time.Now().UTC(),
+		ExportedBy:            userName,
+		SourceDB:              c.sourceDB,
+		DependencyCatalogHash: c.dependencyCatalogHash(),
+		Data:                  jsonData,
+	}, nil
+}
+
+// decodeExportPayload reads an export file or clipboard payload in any known format
+// and migrates it to the current schema version.
+func (c *Core) decodeExportPayload(raw []byte) (*ExportEnvelope, error) {
+	var header struct {
+		Format string `json:"format"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return nil, fmt.Errorf("payload does not contain valid JSON: %w", err)
+	}
+
+	envelope := &ExportEnvelope{}
+	if header.Format == exportFormatName {
+		if err := json.Unmarshal(raw, envelope); err != nil {
+			return nil, fmt.Errorf("invalid export envelope: %w", err)
+		}
+	} else {
+		envelope.Format = exportFormatName
+		envelope.SchemaVersion = 1
+		envelope.Data = raw
+	}
+
+	if envelope.SchemaVersion > currentExportSchemaVersion {
+		return nil, fmt.Errorf("export schema version %d is newer than the supported version %d", envelope.SchemaVersion, currentExportSchemaVersion)
+	}
+	for envelope.SchemaVersion < currentExportSchemaVersion {
+		migrate, ok := exportMigrations[envelope.SchemaVersion]
+		if !ok {
+			return nil, fmt.Errorf("no migration for export schema version %d", envelope.SchemaVersion)
+		}
+		if err := migrate(envelope); err != nil {
+			return nil, fmt.Errorf("error migrating export schema version %d: %w", envelope.SchemaVersion, err)
+		}
+		envelope.SchemaVersion++
+	}
+
+	if len(envelope.Data) == 0 {
+		return nil, errors.New("export contains no data")
+	}
+	envelope.RootType = strings.ToLower(envelope.RootType)
+	switch envelope.RootType {
+	case "line", "station", "tool", "operation", "sequencegroup":
+	default:
+		return nil, fmt.Errorf("unsupported root type in export: '%s'", envelope.RootType)
+	}
+	if envelope.DependencyCatalogHash != "" && envelope.DependencyCatalogHash != c.dependencyCatalogHash() {
+		log.Printf("Warning: export was created with a different dependency catalog (hash %s).", envelope.DependencyCatalogHash)
+	}
+	return envelope, nil
+}
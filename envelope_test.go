@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMigrateExportV1ToV2(t *testing.T) {
+	tests := []struct {
+		data     string
+		rootType string
+		wantErr  bool
+	}{
+		{data: `{"Name":"L1","AssemblyArea":"A01","Stations":[]}`, rootType: "line"},
+		{data: `{"Name":"S1","StationType":"st1","Tools":[]}`, rootType: "station"},
+		{data: `{"Name":"T1","ToolClass":"tc1","Operations":[]}`, rootType: "tool"},
+		{data: `{"Name":"G1","Index":"1","Operations":[]}`, rootType: "sequencegroup"},
+		{data: `{"Name":"O1","DecisionCriteria":"","SequenceGroup":"1"}`, rootType: "operation"},
+		{data: `{"Name":"unknown"}`, wantErr: true},
+		{data: `not json`, wantErr: true},
+	}
+	for _, tt := range tests {
+		envelope := &ExportEnvelope{SchemaVersion: 1, Data: json.RawMessage(tt.data)}
+		err := migrateExportV1ToV2(envelope)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("migrateExportV1ToV2(%s) detected %q, want an error", tt.data, envelope.RootType)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("migrateExportV1ToV2(%s) returned %v", tt.data, err)
+			continue
+		}
+		if envelope.RootType != tt.rootType {
+			t.Errorf("migrateExportV1ToV2(%s) detected %q, want %q", tt.data, envelope.RootType, tt.rootType)
+		}
+	}
+}
+
+func TestDecodeExportPayload(t *testing.T) {
+	c := &Core{dependencyJSON: []byte(`{}`)}
+	tests := []struct {
+		name     string
+		raw      string
+		rootType string
+		data     string
+		wantErr  bool
+	}{
+		{
+			name:     "current envelope",
+			raw:      `{"format":"cep-export","schemaVersion":2,"rootType":"Station","exportedBy":"jdoe","data":{"Name":"S1","StationType":"st1","Tools":[]}}`,
+			rootType: "station",
+			data:     `{"Name":"S1","StationType":"st1","Tools":[]}`,
+		},
+		{
+			name:     "envelope of version 1",
+			raw:      `{"format":"cep-export","schemaVersion":1,"data":{"Name":"T1","ToolClass":"tc1","Operations":[]}}`,
+			rootType: "tool",
+			data:     `{"Name":"T1","ToolClass":"tc1","Operations":[]}`,
+		},
+		{
+			name:     "bare export without envelope",
+			raw:      `{"Name":"L1","AssemblyArea":"A01","Stations":[]}`,
+			rootType: "line",
+			data:     `{"Name":"L1","AssemblyArea":"A01","Stations":[]}`,
+		},
+		{name: "newer schema version", raw: `{"format":"cep-export","schemaVersion":3,"rootType":"line","data":{}}`, wantErr: true},
+		{name: "unknown schema version", raw: `{"format":"cep-export","schemaVersion":0,"rootType":"line","data":{}}`, wantErr: true},
+		{name: "no data", raw: `{"format":"cep-export","schemaVersion":2,"rootType":"line"}`, wantErr: true},
+		{name: "unsupported root type", raw: `{"format":"cep-export","schemaVersion":2,"rootType":"plant","data":{}}`, wantErr: true},
+		{name: "bare export of unknown type", raw: `{"Name":"X"}`, wantErr: true},
+		{name: "invalid envelope", raw: `{"format":"cep-export","schemaVersion":"two"}`, wantErr: true},
+		{name: "not an object", raw: `[1,2]`, wantErr: true},
+		{name: "not JSON", raw: `line`, wantErr: true},
+	}
+	for _, tt := range tests {
+		envelope, err := c.decodeExportPayload([]byte(tt.raw))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: decodeExportPayload returned no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: decodeExportPayload returned %v", tt.name, err)
+			continue
+		}
+		if envelope.SchemaVersion != currentExportSchemaVersion {
+			t.Errorf("%s: schema version %d, want %d", tt.name, envelope.SchemaVersion, currentExportSchemaVersion)
+		}
+		if envelope.RootType != tt.rootType {
+			t.Errorf("%s: root type %q, want %q", tt.name, envelope.RootType, tt.rootType)
+		}
+		if string(envelope.Data) != tt.data {
+			t.Errorf("%s: data %s, want %s", tt.name, envelope.Data, tt.data)
+		}
+	}
+}
+
+func TestExportEnvelopeRoundTrip(t *testing.T) {
+	c := &Core{dependencyJSON: []byte(`{}`), sourceDB: "server/cep"}
+	name := "S1"
+	envelope, err := c.newExportEnvelope("jdoe", "station", &Station{BaseModel: BaseModel{Name: &name}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := c.decodeExportPayload(raw)
+	if err != nil {
+		t.Fatalf("decodeExportPayload returned %v", err)
+	}
+	if decoded.ExportedBy != "jdoe" || decoded.SourceDB != "server/cep" || decoded.RootType != "station" {
+		t.Errorf("decoded envelope %+v does not match the written one", decoded)
+	}
+	if decoded.DependencyCatalogHash != c.dependencyCatalogHash() {
+		t.Errorf("catalog hash %q, want %q", decoded.DependencyCatalogHash, c.dependencyCatalogHash())
+	}
+	var station Station
+	if err := json.Unmarshal(decoded.Data, &station); err != nil || derefOrEmpty(station.Name) != "S1" {
+		t.Errorf("decoded data %s is not the exported station", decoded.Data)
+	}
+}
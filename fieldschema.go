@@ -0,0 +1,287 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"gorm.io/gorm"
+)
+
+type fieldKind int
+
+const (
+	fieldText fieldKind = iota
+	fieldInt
+	fieldIPAddress
+	fieldBool
+	fieldID
+	fieldEnum
+	fieldCatalog
+)
+
+// fieldSpec describes a column that clients may update. maxLength 0 means unlimited text.
+type fieldSpec struct {
+	kind      fieldKind
+	maxLength int
+	values    []string
+	catalog   func(*Data) []string
+}
+
+var statusColors = []string{"empty", "red", "amber", "emerald"}
+
+func catalogIDs(entries []CatalogClass) []string {
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
+
+var commonFields = map[string]fieldSpec{
+	"Comment":     {kind: fieldText},
+	"StatusColor": {kind: fieldEnum, values: statusColors},
+}
+
+// updatableFields lists per entity type the fields UpdateEntityFieldsString accepts. Length limits
+// follow the entity forms; catalog fields take an ID of dependency.json, "none" or an empty value.
+var updatableFields = map[string]map[string]fieldSpec{
+	"line": {
+		"Name":         {kind: fieldText, maxLength: 50},
+		"AssemblyArea": {kind: fieldText, maxLength: 3},
+	},
+	"station": {
+		"Name":        {kind: fieldText, maxLength: 10},
+		"Description": {kind: fieldText, maxLength: 255},
+		"StationType": {kind: fieldCatalog, catalog: func(d *Data) []string {
+			ids := make([]string, 0, len(d.StationTypes))
+			for _, stationType := range d.StationTypes {
+				ids = append(ids, stationType.ID)
+			}
+			return ids
+		}},
+	},
+	"tool": {
+		"Name":                  {kind: fieldText, maxLength: 16},
+		"Description":           {kind: fieldText, maxLength: 50},
+		"IpAddressDevice":       {kind: fieldIPAddress},
+		"SPSPLCNameSPAService":  {kind: fieldText, maxLength: 255},
+		"SPSDBNoSend":           {kind: fieldInt},
+		"SPSDBNoReceive":        {kind: fieldInt},
+		"SPSPreCheck":           {kind: fieldText, maxLength: 255},
+		"SPSAddressInSendDB":    {kind: fieldText, maxLength: 255},
+		"SPSAddressInReceiveDB": {kind: fieldText, maxLength: 255},
+		"ToolClass": {kind: fieldCatalog, catalog: func(d *Data) []string {
+			ids := make([]string, 0, len(d.ToolClasses))
+			for _, toolClass := range d.ToolClasses {
+				ids = append(ids, toolClass.ID)
+			}
+			return ids
+		}},
+		"ToolType": {kind: fieldCatalog, catalog: func(d *Data) []string {
+			ids := make([]string, 0, len(d.ToolTypes))
+			for _, toolType := range d.ToolTypes {
+				ids = append(ids, toolType.ID)
+			}
+			return ids
+		}},
+	},
+	"operation": {
+		"Name":             {kind: fieldText, maxLength: 16},
+		"Description":      {kind: fieldText, maxLength: 100},
+		"DecisionCriteria": {kind: fieldText},
+		"AlwaysPerform":    {kind: fieldBool},
+		"Sequence":         {kind: fieldInt},
+		"SequenceGroup":    {kind: fieldInt},
+		"GroupID":          {kind: fieldID},
+		"SerialOrParallel": {kind: fieldCatalog, catalog: func(d *Data) []string { return catalogIDs(d.SerialOrParallel) }},
+		"QGateRelevant":    {kind: fieldCatalog, catalog: func(d *Data) []string { return catalogIDs(d.QGateRelevant) }},
+		"Template": {kind: fieldCatalog, catalog: func(d *Data) []string {
+			ids := make([]string, 0, len(d.Templates))
+			for _, template := range d.Templates {
+				ids = append(ids, template.ID)
+			}
+			return ids
+		}},
+		"DecisionClass":     {kind: fieldCatalog, catalog: func(d *Data) []string { return catalogIDs(d.DecisionClasses) }},
+		"GenerationClass":   {kind: fieldCatalog, catalog: func(d *Data) []string { return catalogIDs(d.GenerationClasses) }},
+		"SavingClass":       {kind: fieldCatalog, catalog: func(d *Data) []string { return catalogIDs(d.SavingClasses) }},
+		"VerificationClass": {kind: fieldCatalog, catalog: func(d *Data) []string { return catalogIDs(d.VerificationClasses) }},
+	},
+	"sequencegroup": {
+		"Name":  {kind: fieldText, maxLength: 255},
+		"Index": {kind: fieldInt},
+	},
+}
+
+// FieldError names a rejected field of an update and the reason it was rejected.
+type FieldError struct {
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+// FieldValidationError lists every rejected field of an update.
+type FieldValidationError struct {
+	EntityType string       `json:"entityType"`
+	Fields     []FieldError `json:"fields"`
+}
+
+func (e *FieldValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		parts = append(parts, fmt.Sprintf("%s: %s", field.Field, field.Reason))
+	}
+	return fmt.Sprintf("invalid update of %s: %s", e.EntityType, strings.Join(parts, "; "))
+}
+
+func lookupFieldSpec(entityType string, field string) (fieldSpec, bool) {
+	fields, ok := updatableFields[entityType]
+	if !ok {
+		return fieldSpec{}, false
+	}
+	if spec, ok := fields[field]; ok {
+		return spec, true
+	}
+	spec, ok := commonFields[field]
+	return spec, ok
+}
+
+// checkFieldValue returns why value is not valid for spec, or an empty string.
+func checkFieldValue(spec fieldSpec, value string, catalog *Data) string {
+	switch spec.kind {
+	case fieldText:
+		if spec.maxLength > 0 && utf8.RuneCountInString(value) > spec.maxLength {
+			return fmt.Sprintf("longer than %d characters", spec.maxLength)
+		}
+	case fieldInt:
+		if value == "" {
+			return ""
+		}
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return "not a non-negative whole number"
+		}
+	case fieldIPAddress:
+		if value == "" {
+			return ""
+		}
+		if ip := net.ParseIP(value); ip == nil || ip.To4() == nil || strings.Contains(value, ":") {
+			return "not a valid IPv4 address"
+		}
+	case fieldBool:
+		if value != "" && value != "true" && value != "false" {
+			return "must be true or false"
+		}
+	case fieldID:
+		if value == "" {
+			return ""
+		}
+		var id mssql.UniqueIdentifier
+		if err := id.Scan(value); err != nil {
+			return "not a valid ID"
+		}
+	case fieldEnum:
+		if value != "" && !containsString(spec.values, value) {
+			return fmt.Sprintf("must be one of %s", strings.Join(spec.values, ", "))
+		}
+	case fieldCatalog:
+		if value == "" || value == "none" {
+			return ""
+		}
+		if !containsString(spec.catalog(catalog), value) {
+			return "not an entry of the dependency catalog"
+		}
+	}
+	return ""
+}
+
+// validateFieldUpdates checks the keys and values of an update against updatableFields.
+func validateFieldUpdates(entityType string, updates map[string]string, catalog *Data) error {
+	entityType = strings.ToLower(entityType)
+	if _, ok := updatableFields[entityType]; !ok {
+		return fmt.Errorf("unsupported entity type: %s", entityType)
+	}
+	validationErr := &FieldValidationError{EntityType: entityType}
+	for field, value := range updates {
+		spec, ok := lookupFieldSpec(entityType, field)
+		if !ok {
+			validationErr.Fields = append(validationErr.Fields, FieldError{Field: field, Value: value, Reason: "field cannot be updated"})
+			continue
+		}
+		if reason := checkFieldValue(spec, value, catalog); reason != "" {
+			validationErr.Fields = append(validationErr.Fields, FieldError{Field: field, Value: value, Reason: reason})
+		}
+	}
+	if len(validationErr.Fields) == 0 {
+		return nil
+	}
+	sort.Slice(validationErr.Fields, func(i, j int) bool { return validationErr.Fields[i].Field < validationErr.Fields[j].Field })
+	return validationErr
+}
+
+// validateEntityUpdate checks an update, loading the dependency catalog only if a catalog field is updated.
+func (c *Core) validateEntityUpdate(entityType string, updates map[string]string) error {
+	catalog := &Data{}
+	for field := range updates {
+		if spec, ok := lookupFieldSpec(strings.ToLower(entityType), field); ok && spec.kind == fieldCatalog {
+			data, err := c.loadDependencyData()
+			if err != nil {
+				return err
+			}
+			catalog = data
+			break
+		}
+	}
+	return validateFieldUpdates(entityType, updates, catalog)
+}
+
+// checkOperationGroup rejects a GroupID that names no live sequence group or a group of
+// another station than the one of the operation's tool. An empty GroupID clears the group.
+func checkOperationGroup(tx *gorm.DB, toolID mssql.UniqueIdentifier, groupIDStr string) error {
+	if groupIDStr == "" {
+		return nil
+	}
+	groupID, err := parseMSSQLUniqueIdentifierFromString(groupIDStr)
+	if err != nil {
+		return err
+	}
+	reason := ""
+	var group SequenceGroup
+	if err := tx.First(&group, "id = ?", groupID).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("error loading sequence group: %w", err)
+		}
+		reason = "sequence group not found"
+	} else {
+		var tool Tool
+		if err := tx.Select("id, parent_id").First(&tool, "id = ?", toolID).Error; err != nil {
+			return fmt.Errorf("error loading tool of operation: %w", err)
+		}
+		if group.ParentID != tool.ParentID {
+			reason = "sequence group belongs to another station"
+		}
+	}
+	if reason == "" {
+		return nil
+	}
+	return &FieldValidationError{EntityType: "operation", Fields: []FieldError{{Field: "GroupID", Value: groupIDStr, Reason: reason}}}
+}
+
+// fieldUpdateValue converts a validated value for the gorm Updates map. Empty IDs clear the reference.
+func fieldUpdateValue(entityType string, field string, value string) interface{} {
+	spec, _ := lookupFieldSpec(strings.ToLower(entityType), field)
+	if spec.kind == fieldID {
+		if value == "" {
+			return nil
+		}
+		var id mssql.UniqueIdentifier
+		_ = id.Scan(value)
+		return id
+	}
+	return strPtr(value)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValidateEntityUpdate(t *testing.T) {
+	c := &Core{dependencyJSON: []byte(`{"Templates":[{"id":"1"}],"ToolClasses":[{"id":"1"}],"StationTypes":[{"id":"0"}]}`)}
+	tests := []struct {
+		name       string
+		entityType string
+		updates    map[string]string
+		// want lists "field: reason" per rejected field; nil means the update is valid.
+		want    []string
+		wantErr bool
+	}{
+		{name: "valid station", entityType: "station", updates: map[string]string{"Name": "S1", "Description": "", "StationType": "0"}},
+		{name: "entity type in any case", entityType: "Station", updates: map[string]string{"Name": "S1"}},
+		{name: "name at the limit in runes", entityType: "station", updates: map[string]string{"Name": "ÄÖÜäöüßéèê"}},
+		{name: "name too long", entityType: "station", updates: map[string]string{"Name": "Station 123"}, want: []string{"Name: longer than 10 characters"}},
+		{name: "common fields", entityType: "line", updates: map[string]string{"Comment": "any text", "StatusColor": "red"}},
+		{name: "unknown status color", entityType: "line", updates: map[string]string{"StatusColor": "blue"}, want: []string{"StatusColor: must be one of empty, red, amber, emerald"}},
+		{name: "field that cannot be updated", entityType: "tool", updates: map[string]string{"ParentID": "x"}, want: []string{"ParentID: field cannot be updated"}},
+		{name: "valid IP address", entityType: "tool", updates: map[string]string{"IpAddressDevice": "10.0.0.1"}},
+		{name: "empty IP address", entityType: "tool", updates: map[string]string{"IpAddressDevice": ""}},
+		{name: "IPv6 address", entityType: "tool", updates: map[string]string{"IpAddressDevice": "::1"}, want: []string{"IpAddressDevice: not a valid IPv4 address"}},
+		{name: "IP address out of range", entityType: "tool", updates: map[string]string{"IpAddressDevice": "256.0.0.1"}, want: []string{"IpAddressDevice: not a valid IPv4 address"}},
+		{name: "DB numbers", entityType: "tool", updates: map[string]string{"SPSDBNoSend": "12", "SPSDBNoReceive": ""}},
+		{
+			name: "invalid DB numbers sorted by field", entityType: "tool",
+			updates: map[string]string{"SPSDBNoSend": "-1", "SPSDBNoReceive": "DB5"},
+			want:    []string{"SPSDBNoReceive: not a non-negative whole number", "SPSDBNoSend: not a non-negative whole number"},
+		},
+		{name: "valid bool and ID", entityType: "operation", updates: map[string]string{"AlwaysPerform": "true", "GroupID": "6F9619FF-8B86-D011-B42D-00C04FC964FF"}},
+		{name: "cleared group", entityType: "operation", updates: map[string]string{"GroupID": "", "SequenceGroup": ""}},
+		{name: "invalid bool", entityType: "operation", updates: map[string]string{"AlwaysPerform": "yes"}, want: []string{"AlwaysPerform: must be true or false"}},
+		{name: "invalid ID", entityType: "operation", updates: map[string]string{"GroupID": "group 1"}, want: []string{"GroupID: not a valid ID"}},
+		{name: "catalog entry", entityType: "operation", updates: map[string]string{"Template": "1"}},
+		{name: "catalog value none", entityType: "tool", updates: map[string]string{"ToolClass": "none", "ToolType": ""}},
+		{name: "unknown catalog entry", entityType: "operation", updates: map[string]string{"Template": "99"}, want: []string{"Template: not an entry of the dependency catalog"}},
+		{name: "unknown entity type", entityType: "plant", updates: map[string]string{"Name": "P1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := c.validateEntityUpdate(tt.entityType, tt.updates)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: validateEntityUpdate returned no error", tt.name)
+			}
+			continue
+		}
+		var got []string
+		if err != nil {
+			var validationErr *FieldValidationError
+			if !errors.As(err, &validationErr) {
+				t.Errorf("%s: validateEntityUpdate returned %v, want a FieldValidationError", tt.name, err)
+				continue
+			}
+			for _, field := range validationErr.Fields {
+				got = append(got, field.Field+": "+field.Reason)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: rejected %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateEntityUpdateLoadsCatalogOnlyForCatalogFields(t *testing.T) {
+	c := &Core{dependencyJSON: []byte(`not json`)}
+	if err := c.validateEntityUpdate("tool", map[string]string{"Name": "T1"}); err != nil {
+		t.Errorf("update without catalog fields returned %v", err)
+	}
+	if err := c.validateEntityUpdate("tool", map[string]string{"ToolClass": "1"}); err == nil {
+		t.Error("update of a catalog field with an unreadable catalog returned no error")
+	}
+}
+
+func TestFieldUpdateValue(t *testing.T) {
+	groupID, err := parseMSSQLUniqueIdentifierFromString("6F9619FF-8B86-D011-B42D-00C04FC964FF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		entityType string
+		field      string
+		value      string
+		want       interface{}
+	}{
+		{entityType: "operation", field: "GroupID", value: "6F9619FF-8B86-D011-B42D-00C04FC964FF", want: groupID},
+		{entityType: "Operation", field: "GroupID", value: "6F9619FF-8B86-D011-B42D-00C04FC964FF", want: groupID},
+		{entityType: "operation", field: "GroupID", value: "", want: nil},
+		{entityType: "operation", field: "Sequence", value: "10", want: strPtr("10")},
+		{entityType: "tool", field: "Name", value: "", want: strPtr("")},
+		{entityType: "line", field: "StatusColor", value: "red", want: strPtr("red")},
+	}
+	for _, tt := range tests {
+		got := fieldUpdateValue(tt.entityType, tt.field, tt.value)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("fieldUpdateValue(%s, %s, %q) = %#v, want %#v", tt.entityType, tt.field, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateOperationGroup(t *testing.T) {
+	c := newTestCore(t)
+	line, station, _, op := createTestLine(t, c.DB)
+	group := &SequenceGroup{BaseModel: BaseModel{Name: strPtr("G1")}, ParentID: station.ID}
+	otherStation := &Station{BaseModel: BaseModel{Name: strPtr("S2")}, ParentID: line.ID}
+	if err := c.DB.Create(group).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DB.Create(otherStation).Error; err != nil {
+		t.Fatal(err)
+	}
+	otherGroup := &SequenceGroup{BaseModel: BaseModel{Name: strPtr("G2")}, ParentID: otherStation.ID}
+	deletedGroup := &SequenceGroup{BaseModel: BaseModel{Name: strPtr("G3")}, ParentID: station.ID}
+	for _, g := range []*SequenceGroup{otherGroup, deletedGroup} {
+		if err := c.DB.Create(g).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.DB.Delete(deletedGroup).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		groupID string
+		// reason is the expected rejection of GroupID; empty means the update is accepted.
+		reason string
+	}{
+		{name: "group of the station", groupID: group.ID.String()},
+		{name: "cleared group", groupID: ""},
+		{name: "group of another station", groupID: otherGroup.ID.String(), reason: "sequence group belongs to another station"},
+		{name: "deleted group", groupID: deletedGroup.ID.String(), reason: "sequence group not found"},
+		{name: "unknown group", groupID: "6F9619FF-8B86-D011-B42D-00C04FC964FF", reason: "sequence group not found"},
+	}
+	for _, tt := range tests {
+		for _, update := range []struct {
+			name string
+			call func() error
+		}{
+			{"UpdateEntityFieldsString", func() error {
+				_, err := c.UpdateEntityFieldsString("jdoe", "operation", op.ID.String(), time.Now().Format(time.RFC3339Nano), map[string]string{"GroupID": tt.groupID})
+				return err
+			}},
+			{"UpdateEntityFieldsStringSequenceGroup", func() error {
+				globalTs, err := c.GetGlobalLastUpdateTimestamp()
+				if err != nil {
+					return err
+				}
+				_, err = c.UpdateEntityFieldsStringSequenceGroup("jdoe", "operation", op.ID.String(), globalTs, map[string]string{"GroupID": tt.groupID})
+				return err
+			}},
+		} {
+			// Start every update from an operation without a group, so the group always changes.
+			if err := c.DB.Model(&Operation{}).Where("id = ?", op.ID).Update("group_id", nil).Error; err != nil {
+				t.Fatal(err)
+			}
+			err := update.call()
+			var validationErr *FieldValidationError
+			switch {
+			case tt.reason == "" && err != nil:
+				t.Errorf("%s: %s returned %v", tt.name, update.name, err)
+			case tt.reason != "" && (!errors.As(err, &validationErr) || validationErr.Fields[0].Reason != tt.reason):
+				t.Errorf("%s: %s returned %v, want the rejection %q", tt.name, update.name, err, tt.reason)
+			}
+		}
+	}
+}
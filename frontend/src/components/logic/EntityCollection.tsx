@@ -200,12 +200,12 @@ export function EntityCollection({
                 parentId={parentId}
                 onClick={() => setKey((k) => k + 1)}
               />
-              {useDelayedLoading(entityType == "line") && (
-                <>
-                  <DropdownMenuSeparator className="bg-accent" />
-                  <ImportJSON onClick={() => setKey((k) => k + 1)} />
-                </>
-              )}
+              <DropdownMenuSeparator className="bg-accent" />
+              <ImportJSON
+                entityType={entityType}
+                parentId={parentId}
+                onClose={() => setKey((k) => k + 1)}
+              />
             </DropdownMenuContent>
           </DropdownMenu>
         </div>
@@ -509,16 +509,12 @@ export function EntityCard({
                       entityId={entityId}
                       onClick={() => setKey((k) => k + 1)}
                     />
-                    {entityType == "line" && (
-                      <>
-                        <DropdownMenuSeparator className="bg-accent" />
-                        <ExportJSON
-                          entityType={entityType}
-                          entityId={entityId}
-                          onClick={() => setKey((k) => k + 1)}
-                        />
-                      </>
-                    )}
+                    <DropdownMenuSeparator className="bg-accent" />
+                    <ExportJSON
+                      entityType={entityType}
+                      entityId={entityId}
+                      onClick={() => setKey((k) => k + 1)}
+                    />
                   </DropdownMenuContent>
                 </DropdownMenu>
               </>
@@ -698,7 +694,11 @@ function ExportJSON({
         variant="ghost"
         className="w-full justify-start flex items-center gap-2 px-3 py-2"
         onClick={async () => {
-          const res = await HandleExport(entityType, entityId);
+          const res = await HandleExport(
+            localStorage.getItem("name") ?? "",
+            entityType,
+            entityId
+          );
           res == "ExportSuccess" ? toast.success(t(res)) : toast.error(t(res));
           onClick && onClick();
         }}
@@ -767,7 +767,11 @@ function ClipboardExportButton({
         className="w-full justify-start flex items-center gap-2 px-3 py-2"
         onClick={async () => {
           try {
-            await CopyEntityHierarchyToClipboard(entityType, entityId);
+            await CopyEntityHierarchyToClipboard(
+              localStorage.getItem("name") ?? "",
+              entityType,
+              entityId
+            );
             toast.success(t("CopiedToClipboard"));
             onClick?.();
           } catch (err) {
@@ -783,29 +787,85 @@ function ClipboardExportButton({
   );
 }
 
-function ImportJSON({ onClick }: { onClick?: () => void }) {
+const importModes = ["original", "remap", "merge", "merge-delete"];
+
+function ImportJSON({
+  entityType,
+  parentId,
+  onClose,
+}: {
+  entityType: string;
+  parentId: string;
+  onClose?: () => void;
+}) {
   const queryClient = useQueryClient();
+  const { t } = useTranslation();
+  const [open, setOpen] = useState(false);
+  const [mode, setMode] = useState("original");
 
-  const { mutateAsync: importEntity } = useMutation({
+  const { mutateAsync: importEntity, isPending } = useMutation({
     mutationFn: async () => {
-      const res = await HandleImport(localStorage.getItem("name") ?? "");
+      // Lines are imported as roots, everything else under the parent of this collection.
+      const res = await HandleImport(
+        localStorage.getItem("name") ?? "",
+        entityType == "line" ? "" : parentId,
+        mode
+      );
       res == "ImportSuccess" ? toast.success(t(res)) : toast.error(t(res));
     },
     onSuccess: () => queryClient.invalidateQueries(),
   });
-  const { t } = useTranslation();
 
   return (
-    <div className="flex gap-1 items-center">
-      <Button
-        variant="ghost"
-        className="w-full justify-start flex items-center gap-2 px-3 py-2"
-        onClick={() => (importEntity(), onClick && onClick())}
-      >
-        <FileDown />
-        <span className="text-sm ">{t("ImportJSON")}</span>
-      </Button>
-    </div>
+    <Dialog
+      open={open}
+      onOpenChange={(open) => {
+        setOpen(open);
+        if (!open && onClose) onClose();
+      }}
+    >
+      <div className="flex gap-1 items-center">
+        <DialogTrigger asChild>
+          <Button
+            variant="ghost"
+            className="w-full justify-start flex items-center gap-2 px-3 py-2"
+          >
+            <FileDown />
+            <span className="text-sm ">{t("ImportJSON")}</span>
+          </Button>
+        </DialogTrigger>
+      </div>
+      <DialogContent className="py-10 grid grid-cols-1 gap-5 w-80">
+        <DialogTitle>{t("ImportDialog Title")}</DialogTitle>
+        <DialogDescription>
+          {t("ImportDialog Description", { Entity: t(entityType) })}
+        </DialogDescription>
+        <Select value={mode} onValueChange={setMode}>
+          <SelectTrigger>
+            <SelectValue />
+          </SelectTrigger>
+          <SelectContent>
+            {importModes.map((importMode) => (
+              <SelectItem key={importMode} value={importMode}>
+                {t(`ImportMode ${importMode}`)}
+              </SelectItem>
+            ))}
+          </SelectContent>
+        </Select>
+        <Button
+          variant="outline"
+          onClick={async () => {
+            await importEntity();
+            setOpen(false);
+            if (onClose) onClose();
+          }}
+          disabled={isPending}
+          className="w-1/2 mx-auto"
+        >
+          {isPending ? "..." : t("Confirm")}
+        </Button>
+      </DialogContent>
+    </Dialog>
   );
 }
 
@@ -672,19 +672,9 @@ export function StationForm({ entityId }: { entityId: string }) {
       if (tools)
         tools.forEach(async ({ ID }) => {
           const operations = await GetAllEntities("operation", ID);
-          operations.forEach(async ({ ID }) => {
-            UpdateEntityFieldsString(
-              String(localStorage.getItem("name")),
-              "operation",
-              ID,
-              lastUpdate ?? "",
-              {
-                SerialOrParallel: "none",
-                SequenceGroup: "",
-                Sequence: "",
-                GroupID: "",
-              }
-            );
+          // The backend resets operations that no longer fit the station type
+          // together with the station update; only their drafts are dropped here.
+          operations.forEach(({ ID }) => {
             const json = JSON.parse(localStorage.getItem(ID) ?? "{}");
 
             delete json.SerialOrParallel;
@@ -1313,20 +1303,9 @@ export function ToolForm({ entityId }: { entityId: string }) {
     if (resetChildTemplate) {
       const operations = await GetAllEntities("operation", entityId);
       if (operations)
-        operations.forEach(async ({ ID }) => {
-          UpdateEntityFieldsString(
-            String(localStorage.getItem("name")),
-            "operation",
-            ID,
-            lastUpdate ?? "",
-            {
-              Template: "none",
-              DecisionClass: "none",
-              VerificationClass: "none",
-              GenerationClass: "none",
-              SavingClass: "none",
-            }
-          );
+        // The backend resets operations that no longer fit the tool class
+        // together with the tool update; only their drafts are dropped here.
+        operations.forEach(({ ID }) => {
           const json = JSON.parse(localStorage.getItem(ID) ?? "{}");
 
           delete json.Template;
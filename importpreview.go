@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	mssql "github.com/microsoft/go-mssqldb"
+	ws "github.com/wailsapp/wails/v2/pkg/runtime"
+	"gorm.io/gorm"
+)
+
+type ImportPreviewEntity struct {
+	EntityType string                 `json:"entityType"`
+	EntityID   string                 `json:"entityId"`
+	Name       *string                `json:"name"`
+	Path       string                 `json:"path"`
+	Changes    map[string]FieldChange `json:"changes,omitempty"`
+}
+
+type ImportRejection struct {
+	EntityType string  `json:"entityType"`
+	EntityID   string  `json:"entityId"`
+	Name       *string `json:"name"`
+	Reason     string  `json:"reason"`
+}
+
+type ImportTypeCounts struct {
+	InFile   int `json:"inFile"`
+	Created  int `json:"created"`
+	Updated  int `json:"updated"`
+	Deleted  int `json:"deleted"`
+	Rejected int `json:"rejected"`
+}
+
+// ImportPreview describes what an import would do without changing the database.
+// Success is false and Error holds the reason if the import would fail.
+type ImportPreview struct {
+	Mode                    string                       `json:"mode"`
+	RootType                string                       `json:"rootType"`
+	Success                 bool                         `json:"success"`
+	Error                   string                       `json:"error"`
+	Counts                  map[string]*ImportTypeCounts `json:"counts"`
+	Created                 []ImportPreviewEntity        `json:"created"`
+	Updated                 []ImportPreviewEntity        `json:"updated"`
+	Deleted                 []ImportPreviewEntity        `json:"deleted"`
+	Rejected                []ImportRejection            `json:"rejected"`
+	IDCollisions            []ImportRejection            `json:"idCollisions"`
+	CompatibilityViolations []ImportRejection            `json:"compatibilityViolations"`
+}
+
+func (p *ImportPreview) count(entityType string) *ImportTypeCounts {
+	if p.Counts[entityType] == nil {
+		p.Counts[entityType] = &ImportTypeCounts{}
+	}
+	return p.Counts[entityType]
+}
+
+func (p *ImportPreview) reject(rejection ImportRejection) {
+	p.Rejected = append(p.Rejected, rejection)
+	p.count(rejection.EntityType).Rejected++
+}
+
+// SelectImportFile lets the user pick an import file, so it can be previewed and imported by path.
+func (c *Core) SelectImportFile() string {
+	file, _ := ws.OpenFileDialog(c.ctx, ws.OpenDialogOptions{
+		Title: "Import",
+		Filters: []ws.FileFilter{
+			{DisplayName: "JSON", Pattern: "*.json"},
+			{DisplayName: "*", Pattern: "*.*"},
+		},
+	})
+	return file
+}
+
+// PreviewImport runs an import inside a transaction that is always rolled back and reports
+// what the import would create, update, delete and reject. It reads and writes the file the
+// same way ImportEntityHierarchyFromJSON does.
+func (c *Core) PreviewImport(filePath string, mode string, parentIDStr string) (*ImportPreview, error) {
+	if c.DB == nil {
+		return nil, errors.New("DB not initialized")
+	}
+	if filePath == "" {
+		return nil, errors.New("import filePath is empty")
+	}
+
+	preview := &ImportPreview{
+		Mode:                    mode,
+		Counts:                  make(map[string]*ImportTypeCounts),
+		Created:                 []ImportPreviewEntity{},
+		Updated:                 []ImportPreviewEntity{},
+		Deleted:                 []ImportPreviewEntity{},
+		Rejected:                []ImportRejection{},
+		IDCollisions:            []ImportRejection{},
+		CompatibilityViolations: []ImportRejection{},
+	}
+
+	tx := c.DB.Begin()
+	if tx.Error != nil {
+		return nil, fmt.Errorf("error starting DB transaction: %w", tx.Error)
+	}
+	defer tx.Rollback()
+
+	// A user name of its own marks the change log entries written by this preview.
+	previewUser := "import-preview-" + uuid.New().String()
+	writer, err := c.newImportWriter(tx, previewUser, mode)
+	if err != nil {
+		preview.Error = err.Error()
+		return preview, nil
+	}
+	// Catalog violations are collected after the import instead of stopping it at the first one.
+	// Entities rejected for their ID would fail the import, so they are checked in memory and
+	// left out, and the rest of the file is still previewed.
+	writer.checkCatalog = false
+	writer.beforeWrite = func(entity interface{}, entityType string, parentID mssql.UniqueIdentifier) error {
+		if preview.RootType == "" {
+			preview.RootType = entityType
+		}
+		fileEntities := make(map[string]flatEntity)
+		flattenHierarchy(entity, entityType, fileEntities)
+		for _, fileEntity := range fileEntities {
+			preview.count(fileEntity.entityType).InFile++
+		}
+		rejected, err := findImportIDCollisions(tx, preview, fileEntities)
+		if err != nil || !rejected {
+			return err
+		}
+		if err := c.findSkippedCompatibilityViolations(tx, preview, entity, entityType, parentID); err != nil {
+			return err
+		}
+		return errSkipImportWrite
+	}
+	importErr := c.importFile(context.Background(), writer, filePath, parentIDStr)
+	if importErr != nil {
+		preview.Error = importErr.Error()
+	}
+	sort.Slice(preview.IDCollisions, func(i, j int) bool {
+		if preview.IDCollisions[i].EntityType != preview.IDCollisions[j].EntityType {
+			return preview.IDCollisions[i].EntityType < preview.IDCollisions[j].EntityType
+		}
+		return preview.IDCollisions[i].EntityID < preview.IDCollisions[j].EntityID
+	})
+
+	var logs []EntityChangeLog
+	if err := tx.Where("changed_by_user_id = ?", previewUser).Order("change_time asc").Find(&logs).Error; err != nil {
+		if importErr != nil {
+			// The failed import may have aborted the transaction, the error above is all we can report.
+			return preview, nil
+		}
+		return nil, fmt.Errorf("error reading change log of preview: %w", err)
+	}
+	for _, entry := range toChangeLogEntries(tx, logs) {
+		entity := ImportPreviewEntity{
+			EntityType: entry.EntityType,
+			EntityID:   entry.EntityID,
+			Name:       entry.EntityName,
+			Path:       entry.EntityPath,
+		}
+		switch entry.OperationType {
+		case OpTypeCreate:
+			preview.Created = append(preview.Created, entity)
+			preview.count(entry.EntityType).Created++
+		case OpTypeUpdate:
+			entity.Changes = entry.Changes
+			preview.Updated = append(preview.Updated, entity)
+			preview.count(entry.EntityType).Updated++
+		case OpTypeDelete:
+			preview.Deleted = append(preview.Deleted, entity)
+			preview.count(entry.EntityType).Deleted++
+		}
+	}
+
+	if err := c.findCompatibilityViolations(tx, preview); err != nil && importErr == nil {
+		return nil, err
+	}
+	preview.Success = importErr == nil && len(preview.Rejected) == 0
+	return preview, nil
+}
+
+// findImportIDCollisions lists the entities of the file whose IDs already exist in the database
+// and reports whether any of them is rejected. Whether a collision rejects the entity depends on
+// the import mode. It runs in the preview transaction before the entities are written, so it sees
+// what earlier stations of the file wrote.
+func findImportIDCollisions(tx *gorm.DB, preview *ImportPreview, fileEntities map[string]flatEntity) (bool, error) {
+	rejected := false
+	idsByType := make(map[string][]string)
+	for id, entity := range fileEntities {
+		idsByType[entity.entityType] = append(idsByType[entity.entityType], id)
+	}
+	for entityType, ids := range idsByType {
+		model, err := getModelInstance(entityType)
+		if err != nil {
+			return false, err
+		}
+		for _, chunk := range chunkIDs(ids, 1000) {
+			var rows []struct {
+				ID        mssql.UniqueIdentifier
+				DeletedAt gorm.DeletedAt
+			}
+			if err := tx.Unscoped().Model(model).Select("id, deleted_at").Where("id IN ?", chunk).Scan(&rows).Error; err != nil {
+				return false, fmt.Errorf("error checking existing %s IDs: %w", entityType, err)
+			}
+			for _, row := range rows {
+				name, _ := entityNameAndParent(fileEntities[row.ID.String()].entity)
+				collision := ImportRejection{EntityType: entityType, EntityID: row.ID.String(), Name: name, Reason: "ID already exists"}
+				if row.DeletedAt.Valid {
+					collision.Reason = "ID already exists in the recycle bin"
+				}
+				preview.IDCollisions = append(preview.IDCollisions, collision)
+				// Merges update existing entities but refuse to touch deleted ones.
+				if preview.Mode == ImportModeOriginal || (row.DeletedAt.Valid && preview.Mode != ImportModeRemap) {
+					preview.reject(collision)
+					rejected = true
+				}
+			}
+		}
+	}
+	return rejected, nil
+}
+
+// findSkippedCompatibilityViolations runs the dependency catalog checks of findCompatibilityViolations
+// in memory for an entity of the file that the preview leaves out.
+func (c *Core) findSkippedCompatibilityViolations(tx *gorm.DB, preview *ImportPreview, entity interface{}, entityType string, parentID mssql.UniqueIdentifier) error {
+	data, err := c.loadDependencyData()
+	if err != nil {
+		return err
+	}
+	report := func(entityType string, id mssql.UniqueIdentifier, name *string, err error) {
+		if err != nil {
+			violation := ImportRejection{EntityType: entityType, EntityID: id.String(), Name: name, Reason: err.Error()}
+			preview.CompatibilityViolations = append(preview.CompatibilityViolations, violation)
+			preview.reject(violation)
+		}
+	}
+	checkTool := func(tool *Tool, station *Station) {
+		report("tool", tool.ID, tool.Name, checkToolCompatibility(data, tool))
+		for i := range tool.Operations {
+			op := tool.Operations[i]
+			report("operation", op.ID, op.Name, checkOperationCompatibility(data, op, tool, station))
+		}
+	}
+	checkStation := func(station *Station) {
+		for i := range station.Tools {
+			checkTool(&station.Tools[i], station)
+		}
+	}
+	switch e := entity.(type) {
+	case *Line:
+		for i := range e.Stations {
+			checkStation(&e.Stations[i])
+		}
+	case *Station:
+		checkStation(e)
+	case *Tool:
+		var station Station
+		if err := tx.First(&station, "id = ?", parentID).Error; err != nil {
+			return fmt.Errorf("failed to load station %s: %w", parentID.String(), err)
+		}
+		checkTool(e, &station)
+	case *Operation:
+		tool, station, err := loadOperationContext(tx, parentID)
+		if err != nil {
+			return err
+		}
+		report("operation", e.ID, e.Name, checkOperationCompatibility(data, *e, tool, station))
+	}
+	return nil
+}
+
+// findCompatibilityViolations runs the dependency catalog checks for every tool and operation the
+// import created or updated and for every tool and operation below a created or updated station.
+func (c *Core) findCompatibilityViolations(tx *gorm.DB, preview *ImportPreview) error {
+	data, err := c.loadDependencyData()
+	if err != nil {
+		return err
+	}
+	operationIDs := make(map[string]bool)
+	toolIDs := make(map[string]bool)
+	for _, entity := range append(append([]ImportPreviewEntity{}, preview.Created...), preview.Updated...) {
+		if entity.EntityType != "station" && entity.EntityType != "tool" && entity.EntityType != "operation" {
+			continue
+		}
+		id, err := parseMSSQLUniqueIdentifierFromString(entity.EntityID)
+		if err != nil {
+			return err
+		}
+		children, err := collectChildIDs(tx.Session(&gorm.Session{}), entity.EntityType, id)
+		if err != nil {
+			return fmt.Errorf("error collecting operations for compatibility check: %w", err)
+		}
+		for _, opID := range children["operation"] {
+			operationIDs[opID] = true
+		}
+		for _, toolID := range children["tool"] {
+			toolIDs[toolID] = true
+		}
+	}
+
+	ids := make([]string, 0, len(operationIDs))
+	for id := range operationIDs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	tools := make(map[string]*Tool)
+	stations := make(map[string]*Station)
+	for _, chunk := range chunkIDs(ids, 1000) {
+		var ops []Operation
+		if err := tx.Where("id IN ?", chunk).Find(&ops).Error; err != nil {
+			return fmt.Errorf("error loading operations for compatibility check: %w", err)
+		}
+		for _, op := range ops {
+			tool, ok := tools[op.ParentID.String()]
+			if !ok {
+				tool = &Tool{}
+				if err := tx.First(tool, "id = ?", op.ParentID).Error; err != nil {
+					return fmt.Errorf("error loading tool of operation %s: %w", op.ID.String(), err)
+				}
+				tools[op.ParentID.String()] = tool
+			}
+			station, ok := stations[tool.ParentID.String()]
+			if !ok {
+				station = &Station{}
+				if err := tx.First(station, "id = ?", tool.ParentID).Error; err != nil {
+					return fmt.Errorf("error loading station of tool %s: %w", tool.ID.String(), err)
+				}
+				stations[tool.ParentID.String()] = station
+			}
+			if err := checkOperationCompatibility(data, op, tool, station); err != nil {
+				violation := ImportRejection{EntityType: "operation", EntityID: op.ID.String(), Name: op.Name, Reason: err.Error()}
+				preview.CompatibilityViolations = append(preview.CompatibilityViolations, violation)
+				preview.reject(violation)
+			}
+		}
+	}
+
+	ids = ids[:0]
+	for id := range toolIDs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, chunk := range chunkIDs(ids, 1000) {
+		var chunkTools []Tool
+		if err := tx.Where("id IN ?", chunk).Find(&chunkTools).Error; err != nil {
+			return fmt.Errorf("error loading tools for compatibility check: %w", err)
+		}
+		for i := range chunkTools {
+			if err := checkToolCompatibility(data, &chunkTools[i]); err != nil {
+				violation := ImportRejection{EntityType: "tool", EntityID: chunkTools[i].ID.String(), Name: chunkTools[i].Name, Reason: err.Error()}
+				preview.CompatibilityViolations = append(preview.CompatibilityViolations, violation)
+				preview.reject(violation)
+			}
+		}
+	}
+	return nil
+}
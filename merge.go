@@ -0,0 +1,311 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// sequenceAssignmentFields are the operation fields that link an operation to a sequence group of its station.
+var sequenceAssignmentFields = map[string]bool{"GroupID": true, "SequenceGroup": true, "Sequence": true}
+
+// mergeImport synchronises an imported hierarchy with the database: existing entities are
+// updated in place, missing ones are created.
+type mergeImport struct {
+	c        *Core
+	tx       *gorm.DB
+	userName string
+	// checkCatalog checks the updated entities against the dependency catalog once the whole
+	// file is merged, as stations and tools are updated before the operations below them.
+	checkCatalog bool
+	// updatedEntities are the entities updated from the file, by type, for the checks in finish.
+	updatedEntities map[string][]mssql.UniqueIdentifier
+	// spsConflictsBefore holds the SPS conflicts of every tool whose SPS fields the merge changes.
+	spsConflictsBefore map[mssql.UniqueIdentifier][]SPSConflict
+	// keepSequenceAssignments is set for tool and operation imports, whose files do not contain
+	// the sequence groups of the station.
+	keepSequenceAssignments bool
+	seen                    map[string]bool
+	created                 int
+	updated                 int
+	unchanged               int
+}
+
+func newMergeImport(c *Core, tx *gorm.DB, userName string, rootType string, checkCatalog bool) *mergeImport {
+	return &mergeImport{
+		c:                       c,
+		tx:                      tx,
+		userName:                userName,
+		checkCatalog:            checkCatalog,
+		updatedEntities:         make(map[string][]mssql.UniqueIdentifier),
+		spsConflictsBefore:      make(map[mssql.UniqueIdentifier][]SPSConflict),
+		keepSequenceAssignments: rootType == "tool" || rootType == "operation",
+		seen:                    make(map[string]bool),
+	}
+}
+
+// finish ends a merge of the root rootID. With deleteMissing, entities below the root that
+// were not part of the import are deleted.
+func (m *mergeImport) finish(rootType string, rootID mssql.UniqueIdentifier, deleteMissing bool, hardDelete bool) error {
+	deleted := 0
+	if deleteMissing {
+		var err error
+		deleted, err = m.deleteMissing(rootType, rootID, hardDelete)
+		if err != nil {
+			return err
+		}
+	}
+	if err := m.checkUpdatedEntities(); err != nil {
+		return err
+	}
+	log.Printf("Merge import: %d created, %d updated, %d unchanged, %d deleted.", m.created, m.updated, m.unchanged, deleted)
+	return nil
+}
+
+func (m *mergeImport) mergeEntity(data interface{}, entityType string, parentID mssql.UniqueIdentifier) error {
+	entityID := getIDFromModel(data)
+	var emptyMsSQLID mssql.UniqueIdentifier
+	if entityID == emptyMsSQLID {
+		return fmt.Errorf("%s in JSON has no ID", entityType)
+	}
+	if m.seen[entityID.String()] {
+		return fmt.Errorf("%s ID %s occurs more than once in the import", entityType, entityID.String())
+	}
+	m.seen[entityID.String()] = true
+
+	switch e := data.(type) {
+	case *Station:
+		e.ParentID = parentID
+	case *Tool:
+		e.ParentID = parentID
+	case *Operation:
+		e.ParentID = parentID
+	case *SequenceGroup:
+		e.ParentID = parentID
+	}
+
+	existing, _ := getModelInstance(entityType)
+	err := m.tx.Unscoped().First(existing, "id = ?", entityID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := m.createEntity(data, entityType, parentID); err != nil {
+			return err
+		}
+	case err != nil:
+		return fmt.Errorf("DB error checking %s ID %s: %w", entityType, entityID.String(), err)
+	default:
+		if deletedAt(existing).Valid {
+			return fmt.Errorf("%s ID %s is in the recycle bin, restore or purge it before merging", entityType, entityID.String())
+		}
+		if err := m.updateEntity(existing, data, entityType, parentID); err != nil {
+			return err
+		}
+	}
+
+	switch e := data.(type) {
+	case *Line:
+		for i := range e.Stations {
+			if err := m.mergeEntity(&e.Stations[i], "station", e.ID); err != nil {
+				return err
+			}
+		}
+	case *Station:
+		// Groups first, operations below the tools may point at new groups.
+		for i := range e.SequenceGroups {
+			if err := m.mergeEntity(&e.SequenceGroups[i], "sequencegroup", e.ID); err != nil {
+				return err
+			}
+		}
+		for i := range e.Tools {
+			if err := m.mergeEntity(&e.Tools[i], "tool", e.ID); err != nil {
+				return err
+			}
+		}
+	case *Tool:
+		for i := range e.Operations {
+			if err := m.mergeEntity(&e.Operations[i], "operation", e.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mergeImport) createEntity(data interface{}, entityType string, parentID mssql.UniqueIdentifier) error {
+	if err := m.tx.Omit(clause.Associations).Create(data).Error; err != nil {
+		return fmt.Errorf("error creating imported %s (ID: %s): %w", entityType, getIDFromModel(data).String(), err)
+	}
+	var logParentID *mssql.UniqueIdentifier
+	var emptyMsSQLID mssql.UniqueIdentifier
+	if parentID != emptyMsSQLID {
+		logParentID = &parentID
+	}
+	m.created++
+	return logEntityCreation(m.tx, getIDFromModel(data), entityType, logParentID, strPtr(m.userName))
+}
+
+// updateEntity writes the fields that differ between the database and the file, versioning the
+// previous state first. Entities whose parent differs are moved to the parent of the file.
+func (m *mergeImport) updateEntity(existing interface{}, data interface{}, entityType string, parentID mssql.UniqueIdentifier) error {
+	entityID := getIDFromModel(existing)
+	currentFields := currentFieldValues(existing)
+	importedFields := currentFieldValues(data)
+
+	gormUpdates := make(map[string]interface{})
+	fieldChanges := make(map[string]FieldChange)
+	changedValues := make(map[string]string)
+	for field, value := range importedFields {
+		if m.keepSequenceAssignments && sequenceAssignmentFields[field] {
+			continue
+		}
+		if derefOrEmpty(currentFields[field]) == derefOrEmpty(value) {
+			continue
+		}
+		fieldChanges[field] = FieldChange{Old: currentFields[field], New: value}
+		changedValues[field] = derefOrEmpty(value)
+		if field == "GroupID" {
+			gormUpdates[field] = data.(*Operation).GroupID
+		} else {
+			gormUpdates[field] = value
+		}
+	}
+	_, currentParentID := entityNameAndParent(existing)
+	if currentParentID != nil && *currentParentID != parentID {
+		fieldChanges["ParentID"] = FieldChange{Old: strPtr(currentParentID.String()), New: strPtr(parentID.String())}
+		gormUpdates["ParentID"] = parentID
+	}
+	if len(gormUpdates) == 0 {
+		m.unchanged++
+		return nil
+	}
+
+	// The file is checked like an update made in the editor.
+	if err := m.c.validateEntityUpdate(entityType, changedValues); err != nil {
+		return fmt.Errorf("imported %s %s rejected: %w", entityType, entityID.String(), err)
+	}
+	if groupID, ok := changedValues["GroupID"]; ok {
+		if err := checkOperationGroup(m.tx, parentID, groupID); err != nil {
+			return fmt.Errorf("imported %s %s rejected: %w", entityType, entityID.String(), err)
+		}
+	}
+	if tool, ok := existing.(*Tool); ok && m.c.spsConflictCheckEnabled() {
+		if _, seen := m.spsConflictsBefore[entityID]; !seen && changesSPSFields(changedValues, gormUpdates) {
+			conflicts, err := toolSPSConflicts(m.tx, tool)
+			if err != nil {
+				return err
+			}
+			m.spsConflictsBefore[entityID] = conflicts
+		}
+	}
+
+	if err := createVersion(m.tx, entityType, existing); err != nil {
+		return fmt.Errorf("failed to create entity version: %w", err)
+	}
+	gormUpdates["updated_by"] = strPtr(m.userName)
+	gormUpdates["updated_at"] = time.Now()
+	model, _ := getModelInstance(entityType)
+	if err := m.tx.Model(model).Where("id = ?", entityID).Updates(gormUpdates).Error; err != nil {
+		return fmt.Errorf("error updating %s %s: %w", entityType, entityID.String(), err)
+	}
+	m.updated++
+	m.updatedEntities[entityType] = append(m.updatedEntities[entityType], entityID)
+	return updateGlobalLastUpdateTimestampAndLogChange(m.tx, entityID, entityType, OpTypeUpdate, strPtr(m.userName), fieldChanges)
+}
+
+// changesSPSFields reports whether an update touches the SPS fields or moves the tool to another station.
+func changesSPSFields(changedValues map[string]string, gormUpdates map[string]interface{}) bool {
+	if _, moved := gormUpdates["ParentID"]; moved {
+		return true
+	}
+	for field := range changedValues {
+		if spsFields[field] {
+			return true
+		}
+	}
+	return false
+}
+
+// checkUpdatedEntities runs the dependency catalog and SPS conflict checks of the editor on every
+// entity the merge updated, now that the whole file is written.
+func (m *mergeImport) checkUpdatedEntities() error {
+	for _, entityType := range []string{"station", "tool", "operation"} {
+		for _, entityID := range m.updatedEntities[entityType] {
+			entity, _ := getModelInstance(entityType)
+			if err := m.tx.First(entity, "id = ?", entityID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					continue
+				}
+				return fmt.Errorf("error reloading imported %s %s: %w", entityType, entityID.String(), err)
+			}
+			if m.checkCatalog {
+				if err := m.c.checkUpdatedEntityCatalog(m.tx, entity); err != nil {
+					return fmt.Errorf("imported %s %s rejected: %w", entityType, entityID.String(), err)
+				}
+			}
+			if before, ok := m.spsConflictsBefore[entityID]; ok {
+				if err := checkToolSPSConflicts(m.tx, entity.(*Tool), before); err != nil {
+					return fmt.Errorf("imported %s %s rejected: %w", entityType, entityID.String(), err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// deleteMissing deletes every entity below the root that was not part of the import.
+func (m *mergeImport) deleteMissing(rootType string, rootID mssql.UniqueIdentifier, hardDelete bool) (int, error) {
+	existingIDs, err := collectChildIDs(m.tx.Session(&gorm.Session{}), rootType, rootID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to collect existing IDs: %w", err)
+	}
+	deleted := 0
+	// Parents first, their children are removed together with them.
+	for _, entityType := range []string{"line", "station", "sequencegroup", "tool", "operation"} {
+		ids := existingIDs[entityType]
+		sort.Strings(ids)
+		for _, idStr := range ids {
+			if m.seen[idStr] {
+				continue
+			}
+			entityID, err := parseMSSQLUniqueIdentifierFromString(idStr)
+			if err != nil {
+				return deleted, err
+			}
+			model, _ := getModelInstance(entityType)
+			if err := m.tx.First(model, "id = ?", entityID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					continue
+				}
+				return deleted, fmt.Errorf("error loading %s %s: %w", entityType, idStr, err)
+			}
+			if err := deleteEntityTx(m.tx, m.userName, entityType, entityID, hardDelete); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func deletedAt(entity interface{}) gorm.DeletedAt {
+	switch e := entity.(type) {
+	case *Line:
+		return e.DeletedAt
+	case *Station:
+		return e.DeletedAt
+	case *Tool:
+		return e.DeletedAt
+	case *Operation:
+		return e.DeletedAt
+	case *SequenceGroup:
+		return e.DeletedAt
+	default:
+		return gorm.DeletedAt{}
+	}
+}
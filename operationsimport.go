@@ -0,0 +1,562 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	ws "github.com/wailsapp/wails/v2/pkg/runtime"
+	"gorm.io/gorm"
+)
+
+// errValidateOnly rolls back the transaction of a row in a validation run.
+var errValidateOnly = errors.New("validate only")
+
+// operationsTableAliases maps alternative column headers to the headers of operationsTableHeader.
+var operationsTableAliases = map[string]string{
+	"id":        "OperationID",
+	"operation": "Name",
+}
+
+// operationsTableFields are the columns written to the operation unchanged.
+var operationsTableFields = []string{"Name", "Description", "Comment", "StatusColor", "DecisionCriteria", "AlwaysPerform", "Sequence"}
+
+type OperationsTableRowResult struct {
+	Sheet       string   `json:"sheet"`
+	Row         int      `json:"row"`
+	Status      string   `json:"status"`
+	OperationID string   `json:"operationId"`
+	Errors      []string `json:"errors"`
+}
+
+type OperationsTableImportReport struct {
+	ValidateOnly          bool                       `json:"validateOnly"`
+	Created               int                        `json:"created"`
+	Updated               int                        `json:"updated"`
+	Unchanged             int                        `json:"unchanged"`
+	Failed                int                        `json:"failed"`
+	CreatedSequenceGroups int                        `json:"createdSequenceGroups"`
+	Rows                  []OperationsTableRowResult `json:"rows"`
+}
+
+// operationsTableRow is one data row keyed by the canonical column headers it has.
+type operationsTableRow struct {
+	sheet  string
+	number int
+	values map[string]string
+}
+
+func (r operationsTableRow) has(column string) bool {
+	_, ok := r.values[column]
+	return ok
+}
+
+func (c *Core) HandleOperationsTableImport(userName string, validateOnly bool) (*OperationsTableImportReport, error) {
+	file, _ := ws.OpenFileDialog(c.ctx, ws.OpenDialogOptions{
+		Title: "Import",
+		Filters: []ws.FileFilter{
+			{DisplayName: "CSV / Excel", Pattern: "*.csv;*.xlsx"},
+			{DisplayName: "*", Pattern: "*.*"},
+		},
+	})
+	return c.ImportOperationsTable(userName, file, validateOnly)
+}
+
+// readOperationsTable reads a CSV or XLSX file into data rows keyed by canonical column headers.
+func readOperationsTable(filePath string) ([]operationsTableRow, error) {
+	var sheets []xlsxSheet
+	if strings.EqualFold(filepath.Ext(filePath), ".xlsx") {
+		var err error
+		if sheets, err = readXLSX(filePath); err != nil {
+			return nil, err
+		}
+	} else {
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading file '%s': %w", filePath, err)
+		}
+		content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+		reader := csv.NewReader(bytes.NewReader(content))
+		// Excel writes semicolon separated files in German locales.
+		firstLine, _, _ := strings.Cut(string(content), "\n")
+		if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+			reader.Comma = ';'
+		}
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV '%s': %w", filePath, err)
+		}
+		sheets = []xlsxSheet{{Name: filepath.Base(filePath), Rows: records}}
+	}
+
+	canonical := make(map[string]string, len(operationsTableHeader))
+	for _, header := range operationsTableHeader {
+		canonical[strings.ToLower(header)] = header
+	}
+	for alias, header := range operationsTableAliases {
+		canonical[alias] = header
+	}
+
+	var rows []operationsTableRow
+	for _, sheet := range sheets {
+		if len(sheet.Rows) == 0 {
+			continue
+		}
+		columns := make([]string, len(sheet.Rows[0]))
+		for i, header := range sheet.Rows[0] {
+			columns[i] = canonical[strings.ToLower(strings.ReplaceAll(strings.TrimSpace(header), " ", ""))]
+		}
+		for i, record := range sheet.Rows[1:] {
+			row := operationsTableRow{sheet: sheet.Name, number: i + 2, values: make(map[string]string)}
+			empty := true
+			for j, column := range columns {
+				if column == "" {
+					continue
+				}
+				value := ""
+				if j < len(record) {
+					value = strings.TrimSpace(record[j])
+				}
+				row.values[column] = value
+				if value != "" {
+					empty = false
+				}
+			}
+			if !empty {
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// resolveTemplateID accepts a template ID, name or description.
+func resolveTemplateID(templates []Template, value string) (string, error) {
+	if value == "" || value == "none" {
+		return "", nil
+	}
+	for _, template := range templates {
+		if template.ID == value {
+			return template.ID, nil
+		}
+	}
+	for _, template := range templates {
+		if strings.EqualFold(template.Description, value) || strings.EqualFold(template.Name, value) {
+			return template.ID, nil
+		}
+	}
+	return "", fmt.Errorf("unknown Template '%s'", value)
+}
+
+// resolveCatalogID accepts the ID, name or description of a catalog entry valid for the template.
+func resolveCatalogID(entries []CatalogClass, field string, value string, templateID string) (string, error) {
+	if value == "" || value == "none" {
+		return "", nil
+	}
+	validFor := func(entry CatalogClass) bool {
+		if entry.TemplateID != "" && entry.TemplateID != templateID {
+			return false
+		}
+		return len(entry.TemplateIDs) == 0 || containsString(entry.TemplateIDs, templateID)
+	}
+	for _, entry := range entries {
+		if entry.ID == value && validFor(entry) {
+			return entry.ID, nil
+		}
+	}
+	for _, entry := range entries {
+		if validFor(entry) && (strings.EqualFold(entry.Description, value) || strings.EqualFold(entry.Name, value)) {
+			return entry.ID, nil
+		}
+	}
+	if templateID != "" {
+		return "", fmt.Errorf("unknown %s '%s' for Template '%s'", field, value, templateID)
+	}
+	return "", fmt.Errorf("unknown %s '%s'", field, value)
+}
+
+// resolveByNameOrID finds an entity by ID or, failing that, by its name below the given parent.
+func resolveByNameOrID(tx *gorm.DB, entityType string, value string, parentID *mssql.UniqueIdentifier) (interface{}, error) {
+	model, err := getModelInstance(entityType)
+	if err != nil {
+		return nil, err
+	}
+	if id, err := parseMSSQLUniqueIdentifierFromString(value); err == nil {
+		if err := tx.First(model, "id = ?", id).Error; err == nil {
+			_, entityParentID := entityNameAndParent(model)
+			if parentID != nil && entityParentID != nil && *entityParentID != *parentID {
+				return nil, fmt.Errorf("%s %s does not belong to the given %s", entityType, value, parentEntityType(entityType))
+			}
+			return model, nil
+		}
+	}
+
+	query := tx.Model(model).Where("name = ?", value)
+	if parentID != nil {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+	var ids []mssql.UniqueIdentifier
+	if err := query.Limit(2).Pluck("id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("error looking up %s '%s': %w", entityType, value, err)
+	}
+	switch {
+	case len(ids) == 0:
+		return nil, fmt.Errorf("%s '%s' not found", entityType, value)
+	case len(ids) > 1:
+		return nil, fmt.Errorf("%s name '%s' is ambiguous, use its ID", entityType, value)
+	}
+	if err := tx.First(model, "id = ?", ids[0]).Error; err != nil {
+		return nil, fmt.Errorf("error loading %s '%s': %w", entityType, value, err)
+	}
+	return model, nil
+}
+
+// ImportOperationsTable creates and updates operations from a CSV or XLSX file in the format written
+// by ExportOperationsTable. Line, Station and Tool are given by name or ID; missing sequence groups
+// are created. Every row is imported on its own, so a failing row does not stop the others.
+// With validateOnly all rows are checked but nothing is written.
+func (c *Core) ImportOperationsTable(userName string, filePath string, validateOnly bool) (*OperationsTableImportReport, error) {
+	if c.DB == nil {
+		return nil, errors.New("DB not initialized")
+	}
+	if userName == "" {
+		return nil, errors.New("userName is required for import")
+	}
+	if filePath == "" {
+		return nil, errors.New("import filePath is empty")
+	}
+	rows, err := readOperationsTable(filePath)
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := c.loadDependencyData()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &OperationsTableImportReport{ValidateOnly: validateOnly, Rows: []OperationsTableRowResult{}}
+	// A validation run rolls every row back, so later rows create the same group again. Count each group once.
+	createdGroups := make(map[string]bool)
+	for _, row := range rows {
+		result := OperationsTableRowResult{Sheet: row.sheet, Row: row.number, Errors: []string{}}
+		createdGroup := ""
+		err := c.DB.Transaction(func(tx *gorm.DB) error {
+			status, opID, groupKey, err := importOperationsTableRow(tx, userName, row, catalog)
+			result.Status, result.OperationID, createdGroup = status, opID, groupKey
+			if err != nil {
+				return err
+			}
+			if validateOnly {
+				return errValidateOnly
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errValidateOnly) {
+			result.Status = "error"
+			result.Errors = append(result.Errors, err.Error())
+		}
+		switch result.Status {
+		case "created":
+			report.Created++
+		case "updated":
+			report.Updated++
+		case "unchanged":
+			report.Unchanged++
+		default:
+			report.Failed++
+		}
+		if createdGroup != "" && result.Status != "error" && !createdGroups[createdGroup] {
+			createdGroups[createdGroup] = true
+			report.CreatedSequenceGroups++
+		}
+		report.Rows = append(report.Rows, result)
+	}
+	log.Printf("Operations table import from '%s': %d created, %d updated, %d unchanged, %d failed (validate only: %t).",
+		filePath, report.Created, report.Updated, report.Unchanged, report.Failed, validateOnly)
+	return report, nil
+}
+
+// importOperationsTableRow writes one row and returns the resulting status, the operation ID and,
+// if the row created a sequence group, the station and name of that group.
+func importOperationsTableRow(tx *gorm.DB, userName string, row operationsTableRow, catalog *Data) (string, string, string, error) {
+	var problems []string
+
+	// 1. Locate the tool the operation belongs to.
+	var lineID *mssql.UniqueIdentifier
+	if value := row.values["Line"]; value != "" {
+		line, err := resolveByNameOrID(tx, "line", value, nil)
+		if err != nil {
+			return "", "", "", err
+		}
+		lineID = &line.(*Line).ID
+	}
+	if row.values["Station"] == "" || row.values["Tool"] == "" {
+		return "", "", "", errors.New("station and tool are required")
+	}
+	stationModel, err := resolveByNameOrID(tx, "station", row.values["Station"], lineID)
+	if err != nil {
+		return "", "", "", err
+	}
+	station := stationModel.(*Station)
+	toolModel, err := resolveByNameOrID(tx, "tool", row.values["Tool"], &station.ID)
+	if err != nil {
+		return "", "", "", err
+	}
+	tool := toolModel.(*Tool)
+
+	// 2. Find the operation by ID or by name below the tool.
+	var existing *Operation
+	var newID *mssql.UniqueIdentifier
+	if value := row.values["OperationID"]; value != "" {
+		id, err := parseMSSQLUniqueIdentifierFromString(value)
+		if err != nil {
+			return "", "", "", fmt.Errorf("invalid OperationID: %w", err)
+		}
+		var op Operation
+		if err := tx.Unscoped().First(&op, "id = ?", id).Error; err == nil {
+			if op.DeletedAt.Valid {
+				return "", "", "", fmt.Errorf("operation %s is in the recycle bin", value)
+			}
+			existing = &op
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			newID = &id
+		} else {
+			return "", "", "", fmt.Errorf("error loading operation %s: %w", value, err)
+		}
+	} else if name := row.values["Name"]; name != "" {
+		var ops []Operation
+		if err := tx.Where("parent_id = ? AND name = ?", tool.ID, name).Find(&ops).Error; err != nil {
+			return "", "", "", fmt.Errorf("error looking up operation '%s': %w", name, err)
+		}
+		if len(ops) > 1 {
+			return "", "", "", fmt.Errorf("operation name '%s' is ambiguous in tool '%s', use its ID", name, derefOrEmpty(tool.Name))
+		}
+		if len(ops) == 1 {
+			existing = &ops[0]
+		}
+	} else {
+		return "", "", "", errors.New("OperationID or Name is required")
+	}
+
+	// 3. Collect the new field values; catalog entries may be given by ID or description.
+	op := Operation{ParentID: tool.ID}
+	if existing != nil {
+		op = *existing
+		op.ParentID = tool.ID
+	}
+	newValues := make(map[string]string)
+	for _, field := range operationsTableFields {
+		if row.has(field) {
+			newValues[field] = row.values[field]
+		}
+	}
+	templateID := derefOrEmpty(op.Template)
+	if row.has("Template") {
+		if templateID, err = resolveTemplateID(catalog.Templates, row.values["Template"]); err != nil {
+			problems = append(problems, err.Error())
+		}
+		newValues["Template"] = templateID
+	}
+	catalogColumns := []struct {
+		field   string
+		entries []CatalogClass
+		byTpl   bool
+	}{
+		{"SerialOrParallel", catalog.SerialOrParallel, false},
+		{"QGateRelevant", catalog.QGateRelevant, false},
+		{"DecisionClass", catalog.DecisionClasses, true},
+		{"SavingClass", catalog.SavingClasses, true},
+		{"VerificationClass", catalog.VerificationClasses, true},
+		{"GenerationClass", catalog.GenerationClasses, true},
+	}
+	for _, column := range catalogColumns {
+		if !row.has(column.field) {
+			continue
+		}
+		tpl := ""
+		if column.byTpl {
+			tpl = templateID
+		}
+		id, err := resolveCatalogID(column.entries, column.field, row.values[column.field], tpl)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		newValues[column.field] = id
+	}
+
+	// 4. Assign the sequence group, creating it if the station does not have it yet.
+	groupCreated := ""
+	var groupID *mssql.UniqueIdentifier
+	if row.has("SequenceGroup") {
+		groupName := row.values["SequenceGroup"]
+		newValues["SequenceGroup"] = ""
+		newValues["GroupID"] = ""
+		if groupName != "" {
+			group, created, err := findOrCreateSequenceGroup(tx, userName, station.ID, groupName)
+			if err != nil {
+				return "", "", "", err
+			}
+			if created {
+				groupCreated = station.ID.String() + "/" + strings.ToLower(groupName)
+			}
+			groupID = &group.ID
+			newValues["SequenceGroup"] = derefOrEmpty(group.Index)
+			newValues["GroupID"] = group.ID.String()
+		}
+	} else if existing != nil && existing.GroupID != nil && existing.ParentID != tool.ID {
+		// The operation moves to another tool. Its group stays only if it belongs to the new station,
+		// otherwise the group of the same name in the new station is used or the group is cleared.
+		group, err := sequenceGroupInStation(tx, *existing.GroupID, station.ID)
+		if err != nil {
+			return "", "", "", err
+		}
+		newValues["SequenceGroup"] = ""
+		newValues["GroupID"] = ""
+		if group != nil {
+			groupID = &group.ID
+			newValues["SequenceGroup"] = derefOrEmpty(group.Index)
+			newValues["GroupID"] = group.ID.String()
+		}
+	}
+
+	// 5. Validate the values like UpdateEntityFieldsString does and the resulting operation against
+	// the dependency catalog.
+	if err := validateFieldUpdates("operation", newValues, catalog); err != nil {
+		problems = append(problems, err.Error())
+	}
+	fields := currentFieldValues(&op)
+	for field, value := range newValues {
+		fields[field] = strPtr(value)
+	}
+	_ = applyVersionedFields(&op, fields)
+	if err := checkOperationCompatibility(catalog, op, tool, station); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if len(problems) > 0 {
+		return "error", "", groupCreated, errors.New(strings.Join(problems, "; "))
+	}
+
+	// 6. Write the operation.
+	if existing == nil {
+		created := Operation{BaseModel: BaseModel{CreatedBy: strPtr(userName), UpdatedBy: strPtr(userName)}, ParentID: tool.ID, GroupID: groupID}
+		if newID != nil {
+			created.ID = *newID
+		}
+		_ = applyVersionedFields(&created, fields)
+		if err := tx.Create(&created).Error; err != nil {
+			return "", "", groupCreated, fmt.Errorf("error creating operation: %w", err)
+		}
+		if err := logEntityCreation(tx, created.ID, "operation", &tool.ID, strPtr(userName)); err != nil {
+			return "", "", groupCreated, err
+		}
+		return "created", created.ID.String(), groupCreated, nil
+	}
+
+	currentFields := currentFieldValues(existing)
+	changed := make(map[string]string)
+	for field, value := range newValues {
+		if derefOrEmpty(currentFields[field]) != value {
+			changed[field] = value
+		}
+	}
+	fieldChanges := buildFieldChanges(existing, changed)
+	gormUpdates := make(map[string]interface{})
+	for field, value := range changed {
+		gormUpdates[field] = fieldUpdateValue("operation", field, value)
+	}
+	if existing.ParentID != tool.ID {
+		fieldChanges["ParentID"] = FieldChange{Old: strPtr(existing.ParentID.String()), New: strPtr(tool.ID.String())}
+		gormUpdates["ParentID"] = tool.ID
+	}
+	if len(gormUpdates) == 0 {
+		return "unchanged", existing.ID.String(), groupCreated, nil
+	}
+	if err := createVersion(tx, "operation", existing); err != nil {
+		return "", "", groupCreated, fmt.Errorf("failed to create entity version: %w", err)
+	}
+	gormUpdates["updated_by"] = strPtr(userName)
+	gormUpdates["updated_at"] = time.Now()
+	if err := tx.Model(&Operation{}).Where("id = ?", existing.ID).Updates(gormUpdates).Error; err != nil {
+		return "", "", groupCreated, fmt.Errorf("error updating operation: %w", err)
+	}
+	if err := updateGlobalLastUpdateTimestampAndLogChange(tx, existing.ID, "operation", OpTypeUpdate, strPtr(userName), fieldChanges); err != nil {
+		return "", "", groupCreated, err
+	}
+	return "updated", existing.ID.String(), groupCreated, nil
+}
+
+// sequenceGroupInStation returns the group for an operation that moves into the station: the group
+// itself if it belongs to the station, else the station's group of the same name, else nil.
+func sequenceGroupInStation(tx *gorm.DB, groupID mssql.UniqueIdentifier, stationID mssql.UniqueIdentifier) (*SequenceGroup, error) {
+	var group SequenceGroup
+	if err := tx.First(&group, "id = ?", groupID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("DB error reading SequenceGroup: %w", err)
+	}
+	if group.ParentID == stationID {
+		return &group, nil
+	}
+	name := derefOrEmpty(group.Name)
+	if name == "" {
+		return nil, nil
+	}
+	var matches []SequenceGroup
+	if err := tx.Where("parent_id = ? AND name = ?", stationID, name).Limit(2).Find(&matches).Error; err != nil {
+		return nil, fmt.Errorf("DB error reading SequenceGroups: %w", err)
+	}
+	if len(matches) != 1 {
+		return nil, nil
+	}
+	return &matches[0], nil
+}
+
+// findOrCreateSequenceGroup looks up a group of the station by name or index and creates it
+// with the next free index if it does not exist.
+func findOrCreateSequenceGroup(tx *gorm.DB, userName string, stationID mssql.UniqueIdentifier, nameOrIndex string) (*SequenceGroup, bool, error) {
+	var groups []SequenceGroup
+	if err := tx.Where("parent_id = ?", stationID).Find(&groups).Error; err != nil {
+		return nil, false, fmt.Errorf("DB error reading SequenceGroups: %w", err)
+	}
+	for i := range groups {
+		if strings.EqualFold(derefOrEmpty(groups[i].Name), nameOrIndex) {
+			return &groups[i], false, nil
+		}
+	}
+	for i := range groups {
+		if derefOrEmpty(groups[i].Index) == nameOrIndex {
+			return &groups[i], false, nil
+		}
+	}
+
+	highest := 0
+	for _, g := range groups {
+		if parsed, err := strconv.Atoi(derefOrEmpty(g.Index)); err == nil && parsed > highest {
+			highest = parsed
+		}
+	}
+	newIndex := strconv.Itoa(highest + 1)
+	group := &SequenceGroup{
+		BaseModel: BaseModel{Name: strPtr(nameOrIndex), CreatedBy: strPtr(userName), UpdatedBy: strPtr(userName)},
+		ParentID:  stationID,
+		Index:     &newIndex,
+	}
+	if err := tx.Create(group).Error; err != nil {
+		return nil, false, fmt.Errorf("DB error creating sequencegroup: %w", err)
+	}
+	if err := logEntityCreation(tx, group.ID, "sequencegroup", &stationID, strPtr(userName)); err != nil {
+		return nil, false, err
+	}
+	return group, true, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestImportOperationsTable(t *testing.T) {
+	c := newTestCore(t)
+	_, station, tool, op := createTestLine(t, c.DB)
+	filePath := filepath.Join(t.TempDir(), "operations.csv")
+	table := "\xef\xbb\xbfLine;Station;Tool;Operation;Template;Serial Or Parallel;SequenceGroup;Description\n" +
+		"L1;S1;T1;O1;;;G1;updated\n" +
+		"L1;S1;T1;O2;1;1;G1;new\n" +
+		"L1;S1;T1;O3;2;;;wrong template\n" +
+		"L1;S9;T1;O4;;;;unknown station\n" +
+		";;;;;;;\n"
+	if err := os.WriteFile(filePath, []byte(table), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	statuses := func(report *OperationsTableImportReport) []string {
+		var got []string
+		for _, row := range report.Rows {
+			got = append(got, row.Status)
+		}
+		return got
+	}
+	want := []string{"updated", "created", "error", "error"}
+
+	// A validation run reports what the import would do and writes nothing.
+	report, err := c.ImportOperationsTable("jdoe", filePath, true)
+	if err != nil {
+		t.Fatalf("validation run returned %v", err)
+	}
+	if got := statuses(report); !reflect.DeepEqual(got, want) || report.CreatedSequenceGroups != 1 {
+		t.Errorf("validation run rows %q with %d new groups, want %q with 1", got, report.CreatedSequenceGroups, want)
+	}
+	var count int64
+	c.DB.Model(&Operation{}).Count(&count)
+	if count != 1 {
+		t.Errorf("validation run left %d operations, want 1", count)
+	}
+
+	report, err = c.ImportOperationsTable("jdoe", filePath, false)
+	if err != nil {
+		t.Fatalf("import returned %v", err)
+	}
+	if got := statuses(report); !reflect.DeepEqual(got, want) || report.Created != 1 || report.Updated != 1 || report.Failed != 2 {
+		t.Errorf("import rows %q, report %+v", got, report)
+	}
+	var group SequenceGroup
+	if err := c.DB.First(&group, "parent_id = ? AND name = ?", station.ID, "G1").Error; err != nil {
+		t.Fatalf("sequence group G1 not created: %v", err)
+	}
+	var ops []Operation
+	if err := c.DB.Where("parent_id = ?", tool.ID).Order("name").Find(&ops).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 2 || ops[0].ID != op.ID || derefOrEmpty(ops[0].Description) != "updated" || derefOrEmpty(ops[1].Name) != "O2" {
+		t.Fatalf("tool has operations %+v, want the updated O1 and the new O2", ops)
+	}
+	for _, o := range ops {
+		if o.GroupID == nil || *o.GroupID != group.ID {
+			t.Errorf("operation %s is in group %v, want %s", derefOrEmpty(o.Name), o.GroupID, group.ID)
+		}
+	}
+
+	// A second import of the same table changes nothing.
+	report, err = c.ImportOperationsTable("jdoe", filePath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Unchanged != 2 || report.CreatedSequenceGroups != 0 {
+		t.Errorf("repeated import report %+v, want 2 unchanged rows and no new groups", report)
+	}
+}
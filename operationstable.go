@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ws "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+var operationsTableHeader = []string{
+	"Line", "Station", "Tool", "SequenceGroup", "Sequence",
+	"Name", "Description", "Comment", "StatusColor", "DecisionCriteria", "SerialOrParallel", "AlwaysPerform",
+	"QGateRelevant", "Template", "DecisionClass", "SavingClass", "VerificationClass", "GenerationClass",
+	"OperationID",
+}
+
+type operationsTableStation struct {
+	name string
+	rows [][]string
+}
+
+// catalogLabel returns the description of a catalog entry, or the raw ID if the entry is unknown.
+// Entries that belong to templates are only matched within the given template.
+func catalogLabel(entries []CatalogClass, id *string, templateID *string) string {
+	value := derefOrEmpty(id)
+	if value == "" || value == "none" {
+		return ""
+	}
+	for _, entry := range entries {
+		if entry.ID != value {
+			continue
+		}
+		if entry.TemplateID != "" && entry.TemplateID != derefOrEmpty(templateID) {
+			continue
+		}
+		if len(entry.TemplateIDs) > 0 && !containsString(entry.TemplateIDs, derefOrEmpty(templateID)) {
+			continue
+		}
+		if entry.Description != "" {
+			return entry.Description
+		}
+		return entry.Name
+	}
+	return value
+}
+
+func templateLabel(templates []Template, id *string) string {
+	value := derefOrEmpty(id)
+	if value == "" || value == "none" {
+		return ""
+	}
+	for _, template := range templates {
+		if template.ID == value {
+			return template.Description
+		}
+	}
+	return value
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// compareNatural orders numeric strings by value and everything else alphabetically, empty last.
+func compareNatural(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return 1
+	}
+	if b == "" {
+		return -1
+	}
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil {
+		if na < nb {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// operationsTableRows flattens a station into one row per operation, ordered by sequence group and sequence.
+func operationsTableRows(lineName string, station *Station, catalog *Data, rawIDs bool) [][]string {
+	groups := make(map[string]*SequenceGroup)
+	for i := range station.SequenceGroups {
+		groups[station.SequenceGroups[i].ID.String()] = &station.SequenceGroups[i]
+	}
+
+	type operationRow struct {
+		groupIndex string
+		sequence   string
+		values     []string
+	}
+	var rows []operationRow
+	for i := range station.Tools {
+		tool := &station.Tools[i]
+		for j := range tool.Operations {
+			op := &tool.Operations[j]
+			groupName := derefOrEmpty(op.SequenceGroup)
+			groupIndex := ""
+			if op.GroupID != nil {
+				if group, ok := groups[op.GroupID.String()]; ok {
+					groupName = derefOrEmpty(group.Name)
+					groupIndex = derefOrEmpty(group.Index)
+				}
+			}
+			serialOrParallel := derefOrEmpty(op.SerialOrParallel)
+			qGateRelevant := derefOrEmpty(op.QGateRelevant)
+			template := derefOrEmpty(op.Template)
+			decisionClass := derefOrEmpty(op.DecisionClass)
+			savingClass := derefOrEmpty(op.SavingClass)
+			verificationClass := derefOrEmpty(op.VerificationClass)
+			generationClass := derefOrEmpty(op.GenerationClass)
+			if !rawIDs {
+				serialOrParallel = catalogLabel(catalog.SerialOrParallel, op.SerialOrParallel, nil)
+				qGateRelevant = catalogLabel(catalog.QGateRelevant, op.QGateRelevant, nil)
+				template = templateLabel(catalog.Templates, op.Template)
+				decisionClass = catalogLabel(catalog.DecisionClasses, op.DecisionClass, op.Template)
+				savingClass = catalogLabel(catalog.SavingClasses, op.SavingClass, op.Template)
+				verificationClass = catalogLabel(catalog.VerificationClasses, op.VerificationClass, op.Template)
+				generationClass = catalogLabel(catalog.GenerationClasses, op.GenerationClass, op.Template)
+			}
+			rows = append(rows, operationRow{
+				groupIndex: groupIndex,
+				sequence:   derefOrEmpty(op.Sequence),
+				values: []string{
+					lineName, derefOrEmpty(station.Name), derefOrEmpty(tool.Name), groupName, derefOrEmpty(op.Sequence),
+					derefOrEmpty(op.Name), derefOrEmpty(op.Description), derefOrEmpty(op.Comment), derefOrEmpty(op.StatusColor),
+					derefOrEmpty(op.DecisionCriteria), serialOrParallel, derefOrEmpty(op.AlwaysPerform),
+					qGateRelevant, template, decisionClass, savingClass, verificationClass, generationClass,
+					op.ID.String(),
+				},
+			})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if c := compareNatural(rows[i].groupIndex, rows[j].groupIndex); c != 0 {
+			return c < 0
+		}
+		if c := compareNatural(rows[i].sequence, rows[j].sequence); c != 0 {
+			return c < 0
+		}
+		if rows[i].values[2] != rows[j].values[2] {
+			return rows[i].values[2] < rows[j].values[2]
+		}
+		return rows[i].values[5] < rows[j].values[5]
+	})
+
+	result := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, row.values)
+	}
+	return result
+}
+
+func (c *Core) HandleOperationsTableExport(scopeType string, scopeID string, format string, rawIDs bool) string {
+	format = strings.ToLower(format)
+	fileFilter := ws.FileFilter{DisplayName: "CSV", Pattern: "*.csv"}
+	if format == "xlsx" {
+		fileFilter = ws.FileFilter{DisplayName: "Excel", Pattern: "*.xlsx"}
+	}
+	file, _ := ws.SaveFileDialog(c.ctx, ws.SaveDialogOptions{
+		DefaultFilename: fmt.Sprintf("%s_%s_operations_%s.%s", scopeType, scopeID, time.Now().Format("20060102_150405"), format),
+		Title:           "Export",
+		Filters: []ws.FileFilter{
+			fileFilter,
+			{DisplayName: "*", Pattern: "*.*"},
+		},
+	})
+	err := c.ExportOperationsTable(scopeType, scopeID, format, file, rawIDs)
+	if err != nil {
+		return "ExportError"
+	} else {
+		return "ExportSuccess"
+	}
+}
+
+// ExportOperationsTable writes the operations of a line or station as a table with one row per
+// operation. CSV files hold all stations, XLSX files one sheet per station. Catalog IDs are written
+// as their dependency.json descriptions unless rawIDs is set.
+func (c *Core) ExportOperationsTable(scopeType string, scopeID string, format string, filePath string, rawIDs bool) (err error) {
+	if c.DB == nil {
+		return errors.New("DB not initialized")
+	}
+	if filePath == "" {
+		return errors.New("export filePath is empty")
+	}
+	format = strings.ToLower(format)
+	if format != "csv" && format != "xlsx" {
+		return fmt.Errorf("unsupported operations table format: %s", format)
+	}
+	scopeType = strings.ToLower(scopeType)
+	if scopeType != "line" && scopeType != "station" {
+		return fmt.Errorf("operations table scope must be line or station, not %s", scopeType)
+	}
+
+	catalog := &Data{}
+	if !rawIDs {
+		if catalog, err = c.loadDependencyData(); err != nil {
+			return err
+		}
+	}
+	hierarchyData, err := internalGetEntityHierarchy(c.DB, scopeType, scopeID)
+	if err != nil {
+		return fmt.Errorf("error loading hierarchy for export: %w", err)
+	}
+
+	var stations []operationsTableStation
+	addStation := func(lineName string, station *Station) {
+		stations = append(stations, operationsTableStation{
+			name: derefOrEmpty(station.Name),
+			rows: operationsTableRows(lineName, station, catalog, rawIDs),
+		})
+	}
+	switch root := hierarchyData.(type) {
+	case *Line:
+		sort.SliceStable(root.Stations, func(i, j int) bool {
+			return compareNatural(derefOrEmpty(root.Stations[i].Name), derefOrEmpty(root.Stations[j].Name)) < 0
+		})
+		for i := range root.Stations {
+			addStation(derefOrEmpty(root.Name), &root.Stations[i])
+		}
+	case *Station:
+		var line Line
+		if err := c.DB.Select("id, name").First(&line, "id = ?", root.ParentID).Error; err != nil {
+			return fmt.Errorf("error loading line of station: %w", err)
+		}
+		addStation(derefOrEmpty(line.Name), root)
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("error creating file '%s': %w", filePath, err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("error closing file '%s': %w", filePath, closeErr)
+		}
+	}()
+
+	rowCount := 0
+	if format == "xlsx" {
+		sheets := make([]xlsxSheet, 0, len(stations))
+		for _, station := range stations {
+			sheets = append(sheets, xlsxSheet{Name: station.name, Rows: append([][]string{operationsTableHeader}, station.rows...)})
+			rowCount += len(station.rows)
+		}
+		if err := writeXLSX(file, sheets); err != nil {
+			return fmt.Errorf("error writing XLSX: %w", err)
+		}
+	} else {
+		buffered := bufio.NewWriter(file)
+		csvWriter := csv.NewWriter(buffered)
+		if err := csvWriter.Write(operationsTableHeader); err != nil {
+			return fmt.Errorf("error writing CSV header: %w", err)
+		}
+		for _, station := range stations {
+			if err := csvWriter.WriteAll(station.rows); err != nil {
+				return fmt.Errorf("error writing CSV: %w", err)
+			}
+			rowCount += len(station.rows)
+		}
+		if err := buffered.Flush(); err != nil {
+			return fmt.Errorf("error writing file '%s': %w", filePath, err)
+		}
+	}
+	log.Printf("%d operations exported to '%s'.", rowCount, filePath)
+	return nil
+}
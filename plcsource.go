@@ -0,0 +1,345 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	ws "github.com/wailsapp/wails/v2/pkg/runtime"
+	"gorm.io/gorm"
+)
+
+// plcMember is one declaration of a generated data block, placed at an offset in bits.
+type plcMember struct {
+	name    string
+	start   int
+	length  int
+	comment string
+}
+
+var plcIdentifierPattern = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// plcIdentifier turns a tool name into an S7 identifier that is unique within used.
+func plcIdentifier(name string, used map[string]bool) string {
+	identifier := strings.Trim(plcIdentifierPattern.ReplaceAllString(strings.TrimSpace(name), "_"), "_")
+	if identifier == "" {
+		identifier = "Tool"
+	}
+	if identifier[0] >= '0' && identifier[0] <= '9' {
+		identifier = "T_" + identifier
+	}
+	candidate := identifier
+	for i := 2; used[strings.ToLower(candidate)]; i++ {
+		candidate = fmt.Sprintf("%s_%d", identifier, i)
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// plcSourceWriter writes the declaration section of a non-optimized data block. Arrays, words
+// and double words start at even bytes and arrays are only used with an even length, so every
+// member lands at the byte and bit it was placed at.
+type plcSourceWriter struct {
+	b     strings.Builder
+	pos   int
+	spare int
+}
+
+func (w *plcSourceWriter) line(declaration string, comment string) {
+	w.b.WriteString("      " + declaration)
+	if comment != "" {
+		w.b.WriteString("   // " + comment)
+	}
+	w.b.WriteString("\n")
+}
+
+// bytes declares length bytes at the current byte under name.
+func (w *plcSourceWriter) bytes(name string, length int, comment string) {
+	start := w.pos / 8
+	switch {
+	case start%2 == 0 && length == 2:
+		w.line(name+" : Word;", comment)
+	case start%2 == 0 && length == 4:
+		w.line(name+" : DWord;", comment)
+	case start%2 == 0 && length%2 == 0:
+		w.line(fmt.Sprintf("%s : Array[0..%d] of Byte;", name, length-1), comment)
+	case length == 1:
+		w.line(name+" : Byte;", comment)
+	default:
+		for i := 0; i < length; i++ {
+			w.line(fmt.Sprintf("%s_%d : Byte;", name, i), comment)
+			comment = ""
+		}
+	}
+	w.pos += length * 8
+}
+
+// padTo fills the gap up to bit position target with spare members.
+func (w *plcSourceWriter) padTo(target int) {
+	// Bools are packed into the current byte, so finish it before moving on to a later byte.
+	for target/8 > w.pos/8 && w.pos%8 != 0 {
+		w.spare++
+		w.line(fmt.Sprintf("Spare_%d : Bool;", w.spare), "")
+		w.pos++
+	}
+	if gap := target/8 - w.pos/8; gap > 0 {
+		if (w.pos/8)%2 == 1 {
+			w.spare++
+			w.bytes(fmt.Sprintf("Spare_%d", w.spare), 1, "")
+			gap--
+		}
+		if gap > 1 {
+			w.spare++
+			w.bytes(fmt.Sprintf("Spare_%d", w.spare), gap-gap%2, "")
+		}
+		if gap%2 == 1 {
+			w.spare++
+			w.bytes(fmt.Sprintf("Spare_%d", w.spare), 1, "")
+		}
+	}
+	for w.pos < target {
+		w.spare++
+		w.line(fmt.Sprintf("Spare_%d : Bool;", w.spare), "")
+		w.pos++
+	}
+}
+
+func (w *plcSourceWriter) member(m plcMember) {
+	w.padTo(m.start)
+	if m.length == 1 {
+		w.line(m.name+" : Bool;", m.comment)
+		w.pos++
+		return
+	}
+	w.bytes(m.name, m.length/8, m.comment)
+}
+
+// plcDataBlockSource returns the source of one data block with the members sorted by offset.
+// Members that overlap an earlier member are left out with a comment.
+func plcDataBlockSource(dbNumber string, members []plcMember) string {
+	sort.SliceStable(members, func(i, j int) bool { return members[i].start < members[j].start })
+	w := &plcSourceWriter{}
+	var skipped []string
+	end := 0
+	for _, m := range members {
+		if m.start < end {
+			skipped = append(skipped, fmt.Sprintf("%s overlaps the member before it (%s)", m.name, m.comment))
+			continue
+		}
+		w.member(m)
+		end = m.start + m.length
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "DATA_BLOCK DB%s\n", dbNumber)
+	b.WriteString("{ S7_Optimized_Access := 'FALSE' }\n")
+	b.WriteString("AUTHOR : CEP\n")
+	b.WriteString("VERSION : 0.1\n")
+	b.WriteString("NON_RETAIN\n")
+	for _, s := range skipped {
+		b.WriteString("// Not generated: " + s + "\n")
+	}
+	b.WriteString("   STRUCT \n")
+	b.WriteString(w.b.String())
+	b.WriteString("   END_STRUCT;\n\n\nBEGIN\n\nEND_DATA_BLOCK\n\n")
+	return b.String()
+}
+
+// plcSources builds one source file per PLC from the send and receive areas of the tools.
+// Areas without DB number or a readable address are listed as comments in the file of their PLC.
+// Tools with a DB number or address but without PLC name are listed in every file.
+func plcSources(tools []spsTool) map[string]string {
+	type plcBlocks struct {
+		name   string
+		blocks map[string][]plcMember
+		used   map[string]map[string]bool
+		notes  []string
+	}
+	plcs := make(map[string]*plcBlocks)
+	var withoutPLC []string
+	for _, t := range tools {
+		plc := normalizedPLCName(t.tool)
+		if plc == "" {
+			for _, value := range []*string{t.tool.SPSDBNoSend, t.tool.SPSDBNoReceive, t.tool.SPSAddressInSendDB, t.tool.SPSAddressInReceiveDB} {
+				if strings.TrimSpace(derefOrEmpty(value)) != "" {
+					withoutPLC = append(withoutPLC, t.path+": no PLC name")
+					break
+				}
+			}
+			continue
+		}
+		p, ok := plcs[plc]
+		if !ok {
+			p = &plcBlocks{
+				name:   strings.TrimSpace(derefOrEmpty(t.tool.SPSPLCNameSPAService)),
+				blocks: make(map[string][]plcMember),
+				used:   make(map[string]map[string]bool),
+			}
+			plcs[plc] = p
+		}
+		for _, side := range []struct {
+			suffix  string
+			db      *string
+			address *string
+		}{
+			{"Send", t.tool.SPSDBNoSend, t.tool.SPSAddressInSendDB},
+			{"Receive", t.tool.SPSDBNoReceive, t.tool.SPSAddressInReceiveDB},
+		} {
+			db := strings.TrimSpace(derefOrEmpty(side.db))
+			if db == "" {
+				continue
+			}
+			n, err := strconv.Atoi(db)
+			if err != nil || n <= 0 {
+				p.notes = append(p.notes, fmt.Sprintf("%s: %s DB number '%s' is not valid", t.path, strings.ToLower(side.suffix), db))
+				continue
+			}
+			db = strconv.Itoa(n)
+			raw := strings.TrimSpace(derefOrEmpty(side.address))
+			if raw == "" {
+				p.notes = append(p.notes, fmt.Sprintf("%s: %s DB %s has no address", t.path, strings.ToLower(side.suffix), db))
+				continue
+			}
+			address, err := parseSPSAddress(raw)
+			if err == nil && address.length > 1 && address.start%8 != 0 {
+				err = fmt.Errorf("'%s' does not start on a byte", raw)
+			}
+			if err != nil {
+				p.notes = append(p.notes, fmt.Sprintf("%s: %s address: %v", t.path, strings.ToLower(side.suffix), err))
+				continue
+			}
+			if p.used[db] == nil {
+				p.used[db] = make(map[string]bool)
+			}
+			name := plcIdentifier(derefOrEmpty(t.tool.Name)+"_"+side.suffix, p.used[db])
+			p.blocks[db] = append(p.blocks[db], plcMember{name: name, start: address.start, length: address.length, comment: t.path + " @ " + raw})
+		}
+	}
+
+	sort.Strings(withoutPLC)
+	sources := make(map[string]string, len(plcs))
+	for _, p := range plcs {
+		var b strings.Builder
+		fmt.Fprintf(&b, "// Data blocks of PLC %s, generated by CEP.\n", p.name)
+		sort.Strings(p.notes)
+		for _, note := range append(p.notes, withoutPLC...) {
+			b.WriteString("// Not generated: " + note + "\n")
+		}
+		b.WriteString("\n")
+		dbs := make([]string, 0, len(p.blocks))
+		for db := range p.blocks {
+			dbs = append(dbs, db)
+		}
+		sort.Slice(dbs, func(i, j int) bool { return compareNatural(dbs[i], dbs[j]) < 0 })
+		for _, db := range dbs {
+			b.WriteString(plcDataBlockSource(db, p.blocks[db]))
+		}
+		sources[p.name] = b.String()
+	}
+	return sources
+}
+
+// loadStationSPSTools loads the tools of a station with their paths.
+func loadStationSPSTools(db *gorm.DB, stationIDStr string) ([]spsTool, error) {
+	stationID, err := parseMSSQLUniqueIdentifierFromString(stationIDStr)
+	if err != nil {
+		return nil, err
+	}
+	var station Station
+	if err := db.Select("id, name, parent_id").First(&station, "id = ?", stationID).Error; err != nil {
+		return nil, fmt.Errorf("station %s not found: %w", stationIDStr, err)
+	}
+	var line Line
+	if err := db.Select("id, name").First(&line, "id = ?", station.ParentID).Error; err != nil {
+		return nil, fmt.Errorf("error loading line of station: %w", err)
+	}
+	var stationTools []Tool
+	if err := db.Where("parent_id = ?", stationID).Find(&stationTools).Error; err != nil {
+		return nil, fmt.Errorf("error loading tools: %w", err)
+	}
+	stationPath := entityPath(entityPath("", "line", line.Name), "station", station.Name)
+	tools := make([]spsTool, 0, len(stationTools))
+	for i := range stationTools {
+		tools = append(tools, spsTool{tool: &stationTools[i], path: entityPath(stationPath, "tool", stationTools[i].Name)})
+	}
+	return tools, nil
+}
+
+func (c *Core) HandlePLCSourceExport(scopeType string, scopeID string) string {
+	folder, _ := ws.OpenDirectoryDialog(c.ctx, ws.OpenDialogOptions{
+		Title:                "Export",
+		CanCreateDirectories: true,
+	})
+	_, err := c.GeneratePLCSources(scopeType, scopeID, folder)
+	if err != nil {
+		return "ExportError"
+	} else {
+		return "ExportSuccess"
+	}
+}
+
+// GeneratePLCSources writes one TIA Portal source file (.db) per PLC into folder. Every file holds
+// the data blocks of the PLC with the send and receive areas of its tools at their DB addresses.
+func (c *Core) GeneratePLCSources(scopeType string, scopeID string, folder string) ([]string, error) {
+	if c.DB == nil {
+		return nil, errors.New("DB not initialized")
+	}
+	if folder == "" {
+		return nil, errors.New("export folder is empty")
+	}
+	var tools []spsTool
+	var err error
+	switch strings.ToLower(scopeType) {
+	case "line":
+		lineID, parseErr := parseMSSQLUniqueIdentifierFromString(scopeID)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		tools, err = loadLineSPSTools(c.DB, lineID)
+	case "station":
+		tools, err = loadStationSPSTools(c.DB, scopeID)
+	default:
+		return nil, fmt.Errorf("PLC source scope must be line or station, not %s", scopeType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sources := plcSources(tools)
+	if len(sources) == 0 {
+		return nil, errors.New("no tool has a PLC name")
+	}
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	files := make([]string, 0, len(names))
+	// Different PLC names can map to the same file name, e.g. "PLC-1" and "PLC 1", and file
+	// systems may ignore case.
+	usedFileNames := make(map[string]bool, len(names))
+	for _, name := range names {
+		baseName := strings.Trim(plcIdentifierPattern.ReplaceAllString(name, "_"), "_")
+		if baseName == "" {
+			baseName = "PLC"
+		}
+		fileName := baseName
+		for i := 2; usedFileNames[strings.ToLower(fileName)]; i++ {
+			fileName = fmt.Sprintf("%s_%d", baseName, i)
+		}
+		usedFileNames[strings.ToLower(fileName)] = true
+		filePath := filepath.Join(folder, fileName+".db")
+		if err := os.WriteFile(filePath, []byte(sources[name]), 0644); err != nil {
+			return files, fmt.Errorf("error writing file '%s': %w", filePath, err)
+		}
+		files = append(files, filePath)
+	}
+	log.Printf("%d PLC source files written to '%s'.", len(files), folder)
+	return files, nil
+}
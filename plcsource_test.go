@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// declarations returns the trimmed lines written by a plcSourceWriter.
+func declarations(source string) []string {
+	var lines []string
+	for _, line := range strings.Split(source, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestPLCSourceWriterPadTo(t *testing.T) {
+	tests := []struct {
+		name   string
+		pos    int
+		target int
+		want   []string
+	}{
+		{name: "nothing to fill", pos: 8, target: 8},
+		{name: "bits of the first byte", pos: 0, target: 3, want: []string{
+			"Spare_1 : Bool;", "Spare_2 : Bool;", "Spare_3 : Bool;",
+		}},
+		{name: "rest of a byte", pos: 5, target: 8, want: []string{
+			"Spare_1 : Bool;", "Spare_2 : Bool;", "Spare_3 : Bool;",
+		}},
+		{name: "word", pos: 0, target: 16, want: []string{"Spare_1 : Word;"}},
+		{name: "odd byte before a word", pos: 8, target: 32, want: []string{"Spare_1 : Byte;", "Spare_2 : Word;"}},
+		{name: "array and trailing byte", pos: 0, target: 56, want: []string{"Spare_1 : Array[0..5] of Byte;", "Spare_2 : Byte;"}},
+		{name: "bits, byte and bits", pos: 3, target: 19, want: []string{
+			"Spare_1 : Bool;", "Spare_2 : Bool;", "Spare_3 : Bool;", "Spare_4 : Bool;", "Spare_5 : Bool;",
+			"Spare_6 : Byte;",
+			"Spare_7 : Bool;", "Spare_8 : Bool;", "Spare_9 : Bool;",
+		}},
+	}
+	for _, tt := range tests {
+		w := &plcSourceWriter{pos: tt.pos}
+		w.padTo(tt.target)
+		if w.pos != tt.target {
+			t.Errorf("%s: position after padTo is %d, want %d", tt.name, w.pos, tt.target)
+		}
+		if got := declarations(w.b.String()); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: padTo wrote %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPLCDataBlockSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		members []plcMember
+		want    []string
+		skipped []string
+	}{
+		{
+			name: "bool and word, sorted by offset",
+			members: []plcMember{
+				{name: "B", start: 16, length: 16, comment: "b"},
+				{name: "A", start: 0, length: 1, comment: "a"},
+			},
+			want: []string{
+				"A : Bool;   // a",
+				"Spare_1 : Bool;", "Spare_2 : Bool;", "Spare_3 : Bool;", "Spare_4 : Bool;",
+				"Spare_5 : Bool;", "Spare_6 : Bool;", "Spare_7 : Bool;",
+				"Spare_8 : Byte;",
+				"B : Word;   // b",
+			},
+		},
+		{
+			name: "double word on an odd byte",
+			members: []plcMember{
+				{name: "X", start: 8, length: 32, comment: "x"},
+			},
+			want: []string{
+				"Spare_1 : Byte;",
+				"X_0 : Byte;   // x", "X_1 : Byte;", "X_2 : Byte;", "X_3 : Byte;",
+			},
+		},
+		{
+			name: "array on an even byte",
+			members: []plcMember{
+				{name: "Y", start: 32, length: 160, comment: "y"},
+			},
+			want: []string{
+				"Spare_1 : DWord;",
+				"Y : Array[0..19] of Byte;   // y",
+			},
+		},
+		{
+			name: "overlapping member is left out",
+			members: []plcMember{
+				{name: "A", start: 0, length: 16, comment: "a"},
+				{name: "C", start: 8, length: 8, comment: "c"},
+				{name: "D", start: 16, length: 8, comment: "d"},
+			},
+			want:    []string{"A : Word;   // a", "D : Byte;   // d"},
+			skipped: []string{"// Not generated: C overlaps the member before it (c)"},
+		},
+	}
+	for _, tt := range tests {
+		source := plcDataBlockSource("5", tt.members)
+		if !strings.HasPrefix(source, "DATA_BLOCK DB5\n") {
+			t.Errorf("%s: source does not start with the data block header:\n%s", tt.name, source)
+		}
+		head, rest, ok := strings.Cut(source, "   STRUCT \n")
+		body, _, ok2 := strings.Cut(rest, "   END_STRUCT;")
+		if !ok || !ok2 {
+			t.Errorf("%s: source has no STRUCT section:\n%s", tt.name, source)
+			continue
+		}
+		if got := declarations(body); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: declarations %q, want %q", tt.name, got, tt.want)
+		}
+		var skipped []string
+		for _, line := range declarations(head) {
+			if strings.HasPrefix(line, "// Not generated") {
+				skipped = append(skipped, line)
+			}
+		}
+		if strings.Join(skipped, "\n") != strings.Join(tt.skipped, "\n") {
+			t.Errorf("%s: skipped %q, want %q", tt.name, skipped, tt.skipped)
+		}
+	}
+}
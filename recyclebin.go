@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"gorm.io/gorm"
+)
+
+// sequenceLink remembers how an operation was attached to a sequence group before the group was deleted.
+type sequenceLink struct {
+	GroupID       mssql.UniqueIdentifier
+	SequenceGroup *string
+	Sequence      *string
+}
+
+// entityNameAndParent returns the display name and parent ID of a live entity.
+func entityNameAndParent(entity interface{}) (*string, *mssql.UniqueIdentifier) {
+	switch e := entity.(type) {
+	case *Line:
+		return e.Name, nil
+	case *Station:
+		return e.Name, &e.ParentID
+	case *Tool:
+		return e.Name, &e.ParentID
+	case *Operation:
+		return e.Name, &e.ParentID
+	case *SequenceGroup:
+		return e.Name, &e.ParentID
+	default:
+		return nil, nil
+	}
+}
+
+// recycleBinPurgeInterval is how often a connected client purges expired recycle bin entries.
+const recycleBinPurgeInterval = time.Hour
+
+// recycleBinSettings is stored in app_metadata so every client deletes the same way.
+type recycleBinSettings struct {
+	SoftDelete     bool `json:"softDelete"`
+	PurgeAfterDays int  `json:"purgeAfterDays"`
+}
+
+// ConfigureRecycleBin switches between soft delete (default) and permanent delete,
+// and sets after how many days recycle bin entries are purged (0 disables purging).
+// The settings are saved in the database and apply to every client.
+func (c *Core) ConfigureRecycleBin(softDelete bool, purgeAfterDays int) error {
+	if c.DB == nil {
+		return errors.New("DB not initialized")
+	}
+	if purgeAfterDays < 0 {
+		return errors.New("purgeAfterDays must not be negative")
+	}
+	settings := recycleBinSettings{SoftDelete: softDelete, PurgeAfterDays: purgeAfterDays}
+	if err := saveSetting(c.DB, RecycleBinSettingsKey, settings); err != nil {
+		return err
+	}
+	c.applyRecycleBinSettings(settings)
+	c.purgeExpiredRecycleBin()
+	return nil
+}
+
+func (c *Core) applyRecycleBinSettings(settings recycleBinSettings) {
+	c.settingsMu.Lock()
+	defer c.settingsMu.Unlock()
+	c.hardDelete = !settings.SoftDelete
+	c.recycleBinPurgeDays = settings.PurgeAfterDays
+}
+
+// loadRecycleBinSettings applies the saved recycle bin settings, or the defaults if there are none.
+func (c *Core) loadRecycleBinSettings() {
+	settings := recycleBinSettings{SoftDelete: true}
+	if _, err := loadSetting(c.DB, RecycleBinSettingsKey, &settings); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	c.applyRecycleBinSettings(settings)
+}
+
+// hardDeleteEnabled reports whether deletes bypass the recycle bin.
+func (c *Core) hardDeleteEnabled() bool {
+	c.settingsMu.RLock()
+	defer c.settingsMu.RUnlock()
+	return c.hardDelete
+}
+
+// moveToRecycleBin tombstones the given subtree and records its root in the recycle bin.
+func moveToRecycleBin(tx *gorm.DB, userName string, entityType string, root interface{}, subtree map[string][]string, linkedOps []Operation) error {
+	now := time.Now()
+	for childType, ids := range subtree {
+		if len(ids) == 0 {
+			continue
+		}
+		model, err := getModelInstance(childType)
+		if err != nil {
+			return err
+		}
+		for _, chunk := range chunkIDs(ids, 1000) {
+			if err := tx.Model(model).Where("id IN ?", chunk).UpdateColumn("deleted_at", now).Error; err != nil {
+				return fmt.Errorf("failed to move %s to recycle bin: %w", childType, err)
+			}
+		}
+	}
+
+	name, parentID := entityNameAndParent(root)
+	entry := RecycleBinEntry{
+		EntityID:   getIDFromModel(root),
+		EntityType: entityType,
+		Name:       name,
+		ParentID:   parentID,
+		DeletedAt:  now,
+		DeletedBy:  strPtr(userName),
+	}
+	if len(linkedOps) > 0 {
+		links := make(map[string]sequenceLink, len(linkedOps))
+		for _, op := range linkedOps {
+			links[op.ID.String()] = sequenceLink{GroupID: *op.GroupID, SequenceGroup: op.SequenceGroup, Sequence: op.Sequence}
+		}
+		if jsonBytes, err := json.Marshal(links); err == nil {
+			linksStr := string(jsonBytes)
+			entry.SequenceLinks = &linksStr
+		}
+	}
+	if err := tx.Save(&entry).Error; err != nil {
+		return fmt.Errorf("failed to create recycle bin entry for %s %s: %w", entityType, entry.EntityID.String(), err)
+	}
+	return nil
+}
+
+// purgeSubtree permanently removes an entity, its history and the history of all its children.
+// The children themselves are removed by the foreign key cascades.
+func purgeSubtree(tx *gorm.DB, root interface{}, subtree map[string][]string) error {
+	for entityType, ids := range subtree {
+		if len(ids) == 0 {
+			continue
+		}
+		historyModel, err := getHistoryModelInstance(entityType)
+		if err != nil {
+			return err
+		}
+		for _, chunk := range chunkIDs(ids, 1000) {
+			if err := tx.Where("entity_id IN ?", chunk).Delete(historyModel).Error; err != nil {
+				return fmt.Errorf("failed to delete history for %s: %w", entityType, err)
+			}
+			if err := tx.Where("entity_id IN ?", chunk).Delete(&RecycleBinEntry{}).Error; err != nil {
+				return fmt.Errorf("failed to delete recycle bin entries for %s: %w", entityType, err)
+			}
+			if entityType == "sequencegroup" {
+				// Soft-deleted operations may still point at the group.
+				if err := tx.Unscoped().Model(&Operation{}).Where("group_id IN ?", chunk).Update("group_id", nil).Error; err != nil {
+					return fmt.Errorf("failed to detach operations from sequence group: %w", err)
+				}
+			}
+		}
+	}
+
+	result := tx.Unscoped().Delete(root)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("no entity actually deleted")
+	}
+	return nil
+}
+
+// ListDeletedEntities returns the roots of all soft-deleted subtrees, newest first.
+func (c *Core) ListDeletedEntities() ([]RecycleBinEntry, error) {
+	if c.DB == nil {
+		return nil, errors.New("DB not initialized")
+	}
+	var entries []RecycleBinEntry
+	if err := c.DB.Order("deleted_at desc").Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to load recycle bin: %w", err)
+	}
+	return entries, nil
+}
+
+// RestoreDeletedEntity brings a soft-deleted entity back together with every child deleted along with it.
+func (c *Core) RestoreDeletedEntity(userName string, entityTypeStr string, entityIDStr string) error {
+	if c.DB == nil {
+		return errors.New("DB not initialized")
+	}
+	if userName == "" {
+		return errors.New("userName is required for restore")
+	}
+	entityIDmssql, err := parseMSSQLUniqueIdentifierFromString(entityIDStr)
+	if err != nil {
+		return err
+	}
+	entityTypeNormalized := strings.ToLower(entityTypeStr)
+
+	return c.DB.Transaction(func(tx *gorm.DB) error {
+		var entry RecycleBinEntry
+		if err := tx.Where("entity_id = ? AND entity_type = ?", entityIDmssql, entityTypeNormalized).Take(&entry).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%s with ID %s is not in the recycle bin", entityTypeNormalized, entityIDStr)
+			}
+			return fmt.Errorf("error loading recycle bin entry: %w", err)
+		}
+
+		if entry.ParentID != nil {
+			parentType := parentEntityType(entityTypeNormalized)
+			parentModel, _ := getModelInstance(parentType)
+			if err := tx.First(parentModel, "id = ?", *entry.ParentID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("parent %s %s is deleted, restore it first", parentType, entry.ParentID.String())
+				}
+				return fmt.Errorf("error loading parent %s: %w", parentType, err)
+			}
+		}
+
+		subtree, err := collectChildIDs(tx.Unscoped().Session(&gorm.Session{}), entityTypeNormalized, entityIDmssql)
+		if err != nil {
+			return fmt.Errorf("failed to collect child IDs for restore: %w", err)
+		}
+		// Children deleted earlier on their own stay in the recycle bin.
+		for childType, ids := range subtree {
+			if len(ids) == 0 {
+				continue
+			}
+			model, err := getModelInstance(childType)
+			if err != nil {
+				return err
+			}
+			columns := "id, parent_id"
+			if childType == "line" {
+				columns = "id"
+			}
+			for _, chunk := range chunkIDs(ids, 1000) {
+				var restored []struct {
+					ID       mssql.UniqueIdentifier
+					ParentID *mssql.UniqueIdentifier
+				}
+				if err := tx.Unscoped().Model(model).Select(columns).Where("id IN ? AND deleted_at >= ?", chunk, entry.DeletedAt).Scan(&restored).Error; err != nil {
+					return fmt.Errorf("failed to load %s for restore: %w", childType, err)
+				}
+				if err := tx.Unscoped().Model(model).Where("id IN ? AND deleted_at >= ?", chunk, entry.DeletedAt).UpdateColumn("deleted_at", nil).Error; err != nil {
+					return fmt.Errorf("failed to restore %s: %w", childType, err)
+				}
+				for _, r := range restored {
+					if err := logEntityCreation(tx, r.ID, childType, r.ParentID, strPtr(userName)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		if entry.SequenceLinks != nil {
+			var links map[string]sequenceLink
+			if err := json.Unmarshal([]byte(*entry.SequenceLinks), &links); err != nil {
+				return fmt.Errorf("invalid sequence links in recycle bin entry: %w", err)
+			}
+			for opIDStr, link := range links {
+				opID, err := parseMSSQLUniqueIdentifierFromString(opIDStr)
+				if err != nil {
+					return err
+				}
+				// Operations that were assigned to another group in the meantime keep their new assignment.
+				// Operations in the recycle bin get their link back as well, so it holds when they are restored.
+				if err := tx.Unscoped().Model(&Operation{}).Where("id = ? AND group_id IS NULL", opID).Updates(map[string]interface{}{
+					"group_id":       link.GroupID,
+					"sequence_group": link.SequenceGroup,
+					"sequence":       link.Sequence,
+					"updated_by":     userName,
+					"updated_at":     time.Now(),
+				}).Error; err != nil {
+					return fmt.Errorf("failed to restore sequence group link of operation %s: %w", opIDStr, err)
+				}
+			}
+		}
+
+		if err := tx.Delete(&entry).Error; err != nil {
+			return fmt.Errorf("failed to remove recycle bin entry: %w", err)
+		}
+		return nil
+	})
+}
+
+// PurgeRecycleBin permanently deletes all recycle bin entries older than the given number of days.
+func (c *Core) PurgeRecycleBin(olderThanDays int) (int, error) {
+	if c.DB == nil {
+		return 0, errors.New("DB not initialized")
+	}
+	if olderThanDays < 0 {
+		return 0, errors.New("olderThanDays must not be negative")
+	}
+	cutoff := time.Now().AddDate(0, 0, -olderThanDays)
+	var entries []RecycleBinEntry
+	if err := c.DB.Where("deleted_at < ?", cutoff).Order("deleted_at asc").Find(&entries).Error; err != nil {
+		return 0, fmt.Errorf("failed to load expired recycle bin entries: %w", err)
+	}
+
+	purged := 0
+	for _, entry := range entries {
+		err := c.DB.Transaction(func(tx *gorm.DB) error {
+			root, err := getModelInstance(entry.EntityType)
+			if err != nil {
+				return err
+			}
+			if err := tx.Unscoped().First(root, "id = ?", entry.EntityID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					// Already removed together with a purged parent.
+					return tx.Delete(&entry).Error
+				}
+				return err
+			}
+			subtree, err := collectChildIDs(tx.Unscoped().Session(&gorm.Session{}), entry.EntityType, entry.EntityID)
+			if err != nil {
+				return err
+			}
+			return purgeSubtree(tx, root, subtree)
+		})
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge %s %s: %w", entry.EntityType, entry.EntityID.String(), err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (c *Core) purgeExpiredRecycleBin() {
+	c.settingsMu.RLock()
+	purgeDays := c.recycleBinPurgeDays
+	c.settingsMu.RUnlock()
+	if purgeDays <= 0 {
+		return
+	}
+	purged, err := c.PurgeRecycleBin(purgeDays)
+	if err != nil {
+		log.Printf("Warning: recycle bin purge failed: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Purged %d expired recycle bin entries.", purged)
+	}
+}
+
+// recycleBinPurgeLoop purges expired recycle bin entries until ctx is cancelled.
+func (c *Core) recycleBinPurgeLoop(ctx context.Context) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("PANIC in recycleBinPurgeLoop: %v", r)
+		}
+	}()
+	ticker := time.NewTicker(recycleBinPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.purgeExpiredRecycleBin()
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecycleBin(t *testing.T) {
+	c := newTestCore(t)
+	_, station, tool, op := createTestLine(t, c.DB)
+	group := &SequenceGroup{BaseModel: BaseModel{Name: strPtr("G1")}, ParentID: station.ID}
+	if err := c.DB.Create(group).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DB.Model(op).Updates(map[string]interface{}{"group_id": group.ID, "sequence": "3"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	updateTestEntity(t, c, "tool", tool.ID.String(), map[string]string{"Description": "versioned"})
+
+	// Deleting the group detaches its operations; restoring it links them again.
+	if err := c.DeleteEntityByIDString("jdoe", "sequencegroup", group.ID.String()); err != nil {
+		t.Fatalf("delete of the group returned %v", err)
+	}
+	var detached Operation
+	if err := c.DB.First(&detached, "id = ?", op.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if detached.GroupID != nil {
+		t.Errorf("operation still in deleted group %s", detached.GroupID)
+	}
+	if err := c.RestoreDeletedEntity("jdoe", "sequencegroup", group.ID.String()); err != nil {
+		t.Fatalf("restore of the group returned %v", err)
+	}
+	var relinked Operation
+	if err := c.DB.First(&relinked, "id = ?", op.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if relinked.GroupID == nil || *relinked.GroupID != group.ID || derefOrEmpty(relinked.Sequence) != "3" {
+		t.Errorf("restored group link %v, sequence %q", relinked.GroupID, derefOrEmpty(relinked.Sequence))
+	}
+
+	// A deleted station takes its children into the recycle bin and brings them back on restore.
+	if err := c.DeleteEntityByIDString("jdoe", "station", station.ID.String()); err != nil {
+		t.Fatalf("delete of the station returned %v", err)
+	}
+	var live, all int64
+	c.DB.Model(&Operation{}).Where("id = ?", op.ID).Count(&live)
+	c.DB.Unscoped().Model(&Operation{}).Where("id = ?", op.ID).Count(&all)
+	if live != 0 || all != 1 {
+		t.Errorf("operation of the deleted station: %d live, %d in total, want 0 and 1", live, all)
+	}
+	entries, err := c.ListDeletedEntities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].EntityID != station.ID {
+		t.Fatalf("recycle bin holds %+v, want the station only", entries)
+	}
+	if err := c.RestoreDeletedEntity("jdoe", "tool", tool.ID.String()); err == nil {
+		t.Error("restore of a tool deleted with its station returned no error")
+	}
+	if err := c.RestoreDeletedEntity("jdoe", "station", station.ID.String()); err != nil {
+		t.Fatalf("restore of the station returned %v", err)
+	}
+	for _, model := range []interface{}{&Station{}, &Tool{}, &Operation{}, &SequenceGroup{}} {
+		var count int64
+		if err := c.DB.Model(model).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("%d live %T after the restore, want 1", count, model)
+		}
+	}
+
+	// Purging removes the entity, its children and their history for good.
+	if err := c.DeleteEntityByIDString("jdoe", "tool", tool.ID.String()); err != nil {
+		t.Fatalf("delete of the tool returned %v", err)
+	}
+	if purged, err := c.PurgeRecycleBin(1); err != nil || purged != 0 {
+		t.Errorf("purge of entries older than a day purged %d, %v", purged, err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if purged, err := c.PurgeRecycleBin(0); err != nil || purged != 1 {
+		t.Fatalf("purge purged %d, %v, want 1", purged, err)
+	}
+	c.DB.Unscoped().Model(&Operation{}).Where("id = ?", op.ID).Count(&all)
+	var history int64
+	c.DB.Model(&ToolHistory{}).Where("entity_id = ?", tool.ID).Count(&history)
+	if all != 0 || history != 0 {
+		t.Errorf("after the purge %d operations and %d tool versions are left, want none", all, history)
+	}
+	if entries, _ := c.ListDeletedEntities(); len(entries) != 0 {
+		t.Errorf("recycle bin holds %+v after the purge", entries)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Keys of the settings stored in app_metadata next to GlobalMetadataKey.
+const (
+	RecycleBinSettingsKey = "recycle_bin"
+	SPSConflictCheckKey   = "sps_conflict_check"
+)
+
+// loadSetting decodes the setting stored under key into value. It reports false if the setting was never saved.
+func loadSetting(db *gorm.DB, key string, value interface{}) (bool, error) {
+	var meta AppMetadata
+	if err := db.Where("config_key = ?", key).Take(&meta).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error loading setting %s: %w", key, err)
+	}
+	if meta.Value == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(*meta.Value), value); err != nil {
+		return false, fmt.Errorf("invalid setting %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// saveSetting stores value as JSON under key.
+func saveSetting(db *gorm.DB, key string, value interface{}) error {
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("error converting setting %s to JSON: %w", key, err)
+	}
+	if err := db.Save(&AppMetadata{ConfigKey: key, LastUpdate: time.Now(), Value: strPtr(string(jsonBytes))}).Error; err != nil {
+		return fmt.Errorf("error saving setting %s: %w", key, err)
+	}
+	return nil
+}
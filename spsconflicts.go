@@ -0,0 +1,357 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"gorm.io/gorm"
+)
+
+// Rule IDs of SPSConflict.
+const (
+	RuleDuplicateIP       = "duplicate-ip"
+	RuleDuplicateDB       = "duplicate-db"
+	RuleAddressOverlap    = "address-overlap"
+	RuleInvalidSPSAddress = "invalid-sps-address"
+)
+
+// spsFields are the tool fields that take part in the conflict analysis.
+var spsFields = map[string]bool{
+	"IpAddressDevice": true, "SPSPLCNameSPAService": true, "SPSDBNoSend": true,
+	"SPSDBNoReceive": true, "SPSAddressInSendDB": true, "SPSAddressInReceiveDB": true,
+}
+
+type SPSConflict struct {
+	RuleID    string   `json:"ruleId"`
+	Severity  string   `json:"severity"`
+	PLCName   string   `json:"plcName"`
+	DB        string   `json:"db"`
+	Message   string   `json:"message"`
+	ToolIDs   []string `json:"toolIds"`
+	ToolPaths []string `json:"toolPaths"`
+}
+
+type SPSPLCSummary struct {
+	Name      string `json:"name"`
+	ToolCount int    `json:"toolCount"`
+}
+
+type SPSConflictReport struct {
+	LineID    string          `json:"lineId"`
+	PLCs      []SPSPLCSummary `json:"plcs"`
+	Conflicts []SPSConflict   `json:"conflicts"`
+}
+
+type spsTool struct {
+	tool *Tool
+	path string
+}
+
+// spsAddress is an area of a DB in bits.
+type spsAddress struct {
+	start  int
+	length int
+}
+
+func (a spsAddress) String() string {
+	return fmt.Sprintf("%d.%d", a.start/8, a.start%8)
+}
+
+var spsAddressPattern = regexp.MustCompile(`^(?:P#)?(?:DB([XBWD])\s*)?(\d+)(?:\.([0-7]))?(?:\s+BYTE\s+(\d+))?$`)
+
+// parseSPSAddress reads addresses like "10", "10.3", "DBX10.3", "DBW10" or "P#DBX10.0 BYTE 20".
+// A plain byte takes one byte and a byte.bit one bit.
+func parseSPSAddress(value string) (spsAddress, error) {
+	m := spsAddressPattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(value)))
+	if m == nil {
+		return spsAddress{}, fmt.Errorf("'%s' is not a DB address", value)
+	}
+	byteOffset, _ := strconv.Atoi(m[2])
+	bit := 0
+	if m[3] != "" {
+		bit, _ = strconv.Atoi(m[3])
+	}
+	address := spsAddress{start: byteOffset*8 + bit}
+	switch {
+	case m[4] != "":
+		size, _ := strconv.Atoi(m[4])
+		address.length = size * 8
+	case m[1] == "X" || (m[1] == "" && m[3] != ""):
+		address.length = 1
+	case m[1] == "W":
+		address.length = 16
+	case m[1] == "D":
+		address.length = 32
+	default:
+		address.length = 8
+	}
+	if address.length == 0 {
+		return spsAddress{}, fmt.Errorf("'%s' has no size", value)
+	}
+	return address, nil
+}
+
+func normalizedPLCName(tool *Tool) string {
+	return strings.ToLower(strings.TrimSpace(derefOrEmpty(tool.SPSPLCNameSPAService)))
+}
+
+func newSPSConflict(ruleID string, severity string, plcName string, db string, message string, tools ...spsTool) SPSConflict {
+	conflict := SPSConflict{RuleID: ruleID, Severity: severity, PLCName: plcName, DB: db, Message: message}
+	for _, t := range tools {
+		conflict.ToolIDs = append(conflict.ToolIDs, t.tool.ID.String())
+		conflict.ToolPaths = append(conflict.ToolPaths, t.path)
+	}
+	return conflict
+}
+
+// analyzeSPSConflicts finds duplicate device IPs among all tools and, per PLC, DB numbers used
+// in both directions, DBs claimed as a whole by more than one tool and overlapping addresses.
+func analyzeSPSConflicts(tools []spsTool) []SPSConflict {
+	conflicts := []SPSConflict{}
+
+	byIP := make(map[string][]spsTool)
+	byPLC := make(map[string][]spsTool)
+	for _, t := range tools {
+		if ip := strings.TrimSpace(derefOrEmpty(t.tool.IpAddressDevice)); ip != "" {
+			byIP[ip] = append(byIP[ip], t)
+		}
+		if plc := normalizedPLCName(t.tool); plc != "" {
+			byPLC[plc] = append(byPLC[plc], t)
+		}
+	}
+	ips := make([]string, 0, len(byIP))
+	for ip := range byIP {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	for _, ip := range ips {
+		if len(byIP[ip]) > 1 {
+			conflicts = append(conflicts, newSPSConflict(RuleDuplicateIP, SeverityError, "", "",
+				fmt.Sprintf("device IP %s is used by %d tools", ip, len(byIP[ip])), byIP[ip]...))
+		}
+	}
+
+	plcs := make([]string, 0, len(byPLC))
+	for plc := range byPLC {
+		plcs = append(plcs, plc)
+	}
+	sort.Strings(plcs)
+	for _, plc := range plcs {
+		// Report the PLC name as written on its first tool.
+		displayName := strings.TrimSpace(derefOrEmpty(byPLC[plc][0].tool.SPSPLCNameSPAService))
+		conflicts = append(conflicts, analyzePLCConflicts(displayName, byPLC[plc])...)
+	}
+	return conflicts
+}
+
+type spsArea struct {
+	tool      spsTool
+	direction string
+	db        string
+	address   *spsAddress
+}
+
+func analyzePLCConflicts(plc string, tools []spsTool) []SPSConflict {
+	var conflicts []SPSConflict
+	areasByDB := make(map[string][]spsArea)
+	for _, t := range tools {
+		for _, side := range []struct {
+			direction string
+			db        *string
+			address   *string
+		}{
+			{"send", t.tool.SPSDBNoSend, t.tool.SPSAddressInSendDB},
+			{"receive", t.tool.SPSDBNoReceive, t.tool.SPSAddressInReceiveDB},
+		} {
+			db := strings.TrimSpace(derefOrEmpty(side.db))
+			if db == "" {
+				continue
+			}
+			area := spsArea{tool: t, direction: side.direction, db: db}
+			if raw := strings.TrimSpace(derefOrEmpty(side.address)); raw != "" {
+				address, err := parseSPSAddress(raw)
+				if err != nil {
+					conflicts = append(conflicts, newSPSConflict(RuleInvalidSPSAddress, SeverityWarning, plc, db,
+						fmt.Sprintf("%s address of DB %s cannot be checked: %v", side.direction, db, err), t))
+					continue
+				}
+				area.address = &address
+			}
+			areasByDB[db] = append(areasByDB[db], area)
+		}
+	}
+
+	dbs := make([]string, 0, len(areasByDB))
+	for db := range areasByDB {
+		dbs = append(dbs, db)
+	}
+	sort.Slice(dbs, func(i, j int) bool { return compareNatural(dbs[i], dbs[j]) < 0 })
+	for _, db := range dbs {
+		areas := areasByDB[db]
+		for i := 0; i < len(areas); i++ {
+			for j := i + 1; j < len(areas); j++ {
+				a, b := areas[i], areas[j]
+				// One tool may use the same DB for both directions as long as the areas do not overlap.
+				sameTool := a.tool.tool.ID == b.tool.tool.ID
+				switch {
+				case a.direction != b.direction && !sameTool:
+					conflicts = append(conflicts, newSPSConflict(RuleDuplicateDB, SeverityError, plc, db,
+						fmt.Sprintf("DB %s is used as %s DB and as %s DB", db, a.direction, b.direction), a.tool, b.tool))
+				case a.address != nil && b.address != nil:
+					if a.address.start < b.address.start+b.address.length && b.address.start < a.address.start+a.address.length {
+						conflicts = append(conflicts, newSPSConflict(RuleAddressOverlap, SeverityError, plc, db,
+							fmt.Sprintf("%s address %s and %s address %s overlap in DB %s", a.direction, a.address, b.direction, b.address, db), a.tool, b.tool))
+					}
+				case !sameTool:
+					conflicts = append(conflicts, newSPSConflict(RuleDuplicateDB, SeverityError, plc, db,
+						fmt.Sprintf("%s DB %s is used by two tools and at least one has no address in it", a.direction, db), a.tool, b.tool))
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+// loadLineSPSTools loads every tool of a line with its path.
+func loadLineSPSTools(db *gorm.DB, lineID mssql.UniqueIdentifier) ([]spsTool, error) {
+	var line Line
+	if err := db.Select("id, name").First(&line, "id = ?", lineID).Error; err != nil {
+		return nil, fmt.Errorf("line %s not found: %w", lineID.String(), err)
+	}
+	var stations []Station
+	if err := db.Select("id, name").Where("parent_id = ?", lineID).Find(&stations).Error; err != nil {
+		return nil, fmt.Errorf("error loading stations: %w", err)
+	}
+	var tools []spsTool
+	for i := range stations {
+		var stationTools []Tool
+		if err := db.Where("parent_id = ?", stations[i].ID).Find(&stationTools).Error; err != nil {
+			return nil, fmt.Errorf("error loading tools: %w", err)
+		}
+		stationPath := entityPath(entityPath("", "line", line.Name), "station", stations[i].Name)
+		for j := range stationTools {
+			tools = append(tools, spsTool{tool: &stationTools[j], path: entityPath(stationPath, "tool", stationTools[j].Name)})
+		}
+	}
+	return tools, nil
+}
+
+// AnalyzeSPSConflicts groups the tools of a line by PLC and reports IP and DB address conflicts.
+func (c *Core) AnalyzeSPSConflicts(lineIDStr string) (*SPSConflictReport, error) {
+	if c.DB == nil {
+		return nil, errors.New("DB not initialized")
+	}
+	lineID, err := parseMSSQLUniqueIdentifierFromString(lineIDStr)
+	if err != nil {
+		return nil, err
+	}
+	tools, err := loadLineSPSTools(c.DB, lineID)
+	if err != nil {
+		return nil, err
+	}
+	report := &SPSConflictReport{LineID: lineIDStr, PLCs: []SPSPLCSummary{}, Conflicts: analyzeSPSConflicts(tools)}
+	counts := make(map[string]int)
+	names := make(map[string]string)
+	for _, t := range tools {
+		if plc := normalizedPLCName(t.tool); plc != "" {
+			counts[plc]++
+			if _, ok := names[plc]; !ok {
+				names[plc] = strings.TrimSpace(derefOrEmpty(t.tool.SPSPLCNameSPAService))
+			}
+		}
+	}
+	for plc, count := range counts {
+		report.PLCs = append(report.PLCs, SPSPLCSummary{Name: names[plc], ToolCount: count})
+	}
+	sort.Slice(report.PLCs, func(i, j int) bool { return report.PLCs[i].Name < report.PLCs[j].Name })
+	return report, nil
+}
+
+// ConfigureSPSConflictCheck makes UpdateEntityFieldsString reject tool updates that cause an SPS conflict.
+// The setting is saved in the database and applies to every client.
+func (c *Core) ConfigureSPSConflictCheck(block bool) error {
+	if c.DB == nil {
+		return errors.New("DB not initialized")
+	}
+	if err := saveSetting(c.DB, SPSConflictCheckKey, block); err != nil {
+		return err
+	}
+	c.settingsMu.Lock()
+	c.blockSPSConflicts = block
+	c.settingsMu.Unlock()
+	return nil
+}
+
+// loadSPSConflictCheck applies the saved SPS conflict check setting; without one updates are not blocked.
+func (c *Core) loadSPSConflictCheck() {
+	block := false
+	if _, err := loadSetting(c.DB, SPSConflictCheckKey, &block); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	c.settingsMu.Lock()
+	c.blockSPSConflicts = block
+	c.settingsMu.Unlock()
+}
+
+func (c *Core) spsConflictCheckEnabled() bool {
+	c.settingsMu.RLock()
+	defer c.settingsMu.RUnlock()
+	return c.blockSPSConflicts
+}
+
+// spsConflictKey identifies a conflict by its rule, DB and tools, so a conflict whose addresses
+// moved but still collide with the same tools is not taken for a new one.
+func spsConflictKey(conflict SPSConflict) string {
+	toolIDs := append([]string(nil), conflict.ToolIDs...)
+	sort.Strings(toolIDs)
+	return conflict.RuleID + "|" + conflict.DB + "|" + strings.Join(toolIDs, ",")
+}
+
+// toolSPSConflicts returns the errors among the SPS conflicts of the tool's line the tool takes part in.
+func toolSPSConflicts(tx *gorm.DB, tool *Tool) ([]SPSConflict, error) {
+	var station Station
+	if err := tx.Select("id, parent_id").First(&station, "id = ?", tool.ParentID).Error; err != nil {
+		return nil, fmt.Errorf("error loading station of tool: %w", err)
+	}
+	tools, err := loadLineSPSTools(tx, station.ParentID)
+	if err != nil {
+		return nil, err
+	}
+	toolID := tool.ID.String()
+	var conflicts []SPSConflict
+	for _, conflict := range analyzeSPSConflicts(tools) {
+		if conflict.Severity == SeverityError && containsString(conflict.ToolIDs, toolID) {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	return conflicts, nil
+}
+
+// checkToolSPSConflicts returns an error if the updated tool takes part in an SPS conflict of its line
+// that is not among the conflicts it had before the update.
+func checkToolSPSConflicts(tx *gorm.DB, tool *Tool, before []SPSConflict) error {
+	conflicts, err := toolSPSConflicts(tx, tool)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool, len(before))
+	for _, conflict := range before {
+		existing[spsConflictKey(conflict)] = true
+	}
+	var messages []string
+	for _, conflict := range conflicts {
+		if !existing[spsConflictKey(conflict)] {
+			messages = append(messages, fmt.Sprintf("%s (%s)", conflict.Message, strings.Join(conflict.ToolPaths, ", ")))
+		}
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("SPS conflict: %s", strings.Join(messages, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+func TestParseSPSAddress(t *testing.T) {
+	tests := []struct {
+		value   string
+		start   int
+		length  int
+		wantErr bool
+	}{
+		{value: "10", start: 80, length: 8},
+		{value: " 12 ", start: 96, length: 8},
+		{value: "10.3", start: 83, length: 1},
+		{value: "DBX10.3", start: 83, length: 1},
+		{value: "dbx10.3", start: 83, length: 1},
+		{value: "DBB2", start: 16, length: 8},
+		{value: "DBW10", start: 80, length: 16},
+		{value: "DBD4", start: 32, length: 32},
+		{value: "P#DBX10.0 BYTE 20", start: 80, length: 160},
+		{value: "P#4.0 BYTE 2", start: 32, length: 16},
+		{value: "P#DBX10.0 BYTE 0", wantErr: true},
+		{value: "10.8", wantErr: true},
+		{value: "DBQ1", wantErr: true},
+		{value: "abc", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		address, err := parseSPSAddress(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseSPSAddress(%q) = %+v, want an error", tt.value, address)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSPSAddress(%q) returned %v", tt.value, err)
+			continue
+		}
+		if address.start != tt.start || address.length != tt.length {
+			t.Errorf("parseSPSAddress(%q) = start %d length %d, want start %d length %d", tt.value, address.start, address.length, tt.start, tt.length)
+		}
+	}
+}
+
+// testSPSTool is a tool with the given ID byte whose path is its name.
+type testSPSTool struct {
+	id                                       byte
+	name, plc, ip                            string
+	sendDB, sendAddress, recvDB, recvAddress string
+}
+
+func (tt testSPSTool) spsTool() spsTool {
+	tool := &Tool{
+		BaseModel:             BaseModel{ID: mssql.UniqueIdentifier{tt.id}, Name: strPtr(tt.name)},
+		SPSPLCNameSPAService:  strPtr(tt.plc),
+		IpAddressDevice:       strPtr(tt.ip),
+		SPSDBNoSend:           strPtr(tt.sendDB),
+		SPSAddressInSendDB:    strPtr(tt.sendAddress),
+		SPSDBNoReceive:        strPtr(tt.recvDB),
+		SPSAddressInReceiveDB: strPtr(tt.recvAddress),
+	}
+	return spsTool{tool: tool, path: tt.name}
+}
+
+func TestAnalyzeSPSConflicts(t *testing.T) {
+	tests := []struct {
+		name  string
+		tools []testSPSTool
+		// want lists "rule plc tool,tool" per conflict in report order.
+		want []string
+	}{
+		{
+			name: "no conflicts",
+			tools: []testSPSTool{
+				{id: 1, name: "A", plc: "PLC1", ip: "10.0.0.1", sendDB: "10", sendAddress: "0"},
+				{id: 2, name: "B", plc: "PLC1", ip: "10.0.0.2", sendDB: "10", sendAddress: "1"},
+			},
+		},
+		{
+			name: "duplicate IP across PLCs",
+			tools: []testSPSTool{
+				{id: 1, name: "A", plc: "PLC1", ip: "10.0.0.1"},
+				{id: 2, name: "B", plc: "PLC2", ip: "10.0.0.1"},
+				{id: 3, name: "C", ip: "10.0.0.1"},
+			},
+			want: []string{"duplicate-ip  A,B,C"},
+		},
+		{
+			name: "DB used for send and receive by two tools",
+			tools: []testSPSTool{
+				{id: 1, name: "A", plc: "PLC1", sendDB: "10", sendAddress: "0"},
+				{id: 2, name: "B", plc: "PLC1", recvDB: "10", recvAddress: "4"},
+			},
+			want: []string{"duplicate-db PLC1 A,B"},
+		},
+		{
+			name: "one tool uses a DB in both directions",
+			tools: []testSPSTool{
+				{id: 1, name: "A", plc: "PLC1", sendDB: "10", sendAddress: "0", recvDB: "10", recvAddress: "2"},
+			},
+		},
+		{
+			name: "one tool overlaps itself",
+			tools: []testSPSTool{
+				{id: 1, name: "A", plc: "PLC1", sendDB: "10", sendAddress: "DBW0", recvDB: "10", recvAddress: "1"},
+			},
+			want: []string{"address-overlap PLC1 A,A"},
+		},
+		{
+			name: "overlapping bit and byte",
+			tools: []testSPSTool{
+				{id: 1, name: "A", plc: "PLC1", sendDB: "10", sendAddress: "0"},
+				{id: 2, name: "B", plc: "PLC1", sendDB: "10", sendAddress: "DBX0.3"},
+			},
+			want: []string{"address-overlap PLC1 A,B"},
+		},
+		{
+			name: "whole DB claimed next to an address",
+			tools: []testSPSTool{
+				{id: 1, name: "A", plc: "PLC1", sendDB: "10"},
+				{id: 2, name: "B", plc: "PLC1", sendDB: "10", sendAddress: "4"},
+			},
+			want: []string{"duplicate-db PLC1 A,B"},
+		},
+		{
+			name: "same DB on different PLCs",
+			tools: []testSPSTool{
+				{id: 1, name: "A", plc: "PLC1", sendDB: "10", sendAddress: "0"},
+				{id: 2, name: "B", plc: "PLC2", sendDB: "10", sendAddress: "0"},
+			},
+		},
+		{
+			name: "PLC names differ in case and spaces",
+			tools: []testSPSTool{
+				{id: 1, name: "A", plc: "PLC1", sendDB: "10", sendAddress: "P#0.0 BYTE 4"},
+				{id: 2, name: "B", plc: " plc1 ", sendDB: "10", sendAddress: "DBD2"},
+			},
+			want: []string{"address-overlap PLC1 A,B"},
+		},
+		{
+			name: "unreadable address",
+			tools: []testSPSTool{
+				{id: 1, name: "A", plc: "PLC1", sendDB: "10", sendAddress: "ten"},
+				{id: 2, name: "B", plc: "PLC1", sendDB: "10", sendAddress: "0"},
+			},
+			want: []string{"invalid-sps-address PLC1 A"},
+		},
+	}
+	for _, tt := range tests {
+		tools := make([]spsTool, 0, len(tt.tools))
+		for _, tool := range tt.tools {
+			tools = append(tools, tool.spsTool())
+		}
+		var got []string
+		for _, conflict := range analyzeSPSConflicts(tools) {
+			got = append(got, conflict.RuleID+" "+conflict.PLCName+" "+strings.Join(conflict.ToolPaths, ","))
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: analyzeSPSConflicts = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSPSConflictKey(t *testing.T) {
+	a := testSPSTool{id: 1, name: "A"}.spsTool()
+	b := testSPSTool{id: 2, name: "B"}.spsTool()
+	c := testSPSTool{id: 3, name: "C"}.spsTool()
+	base := spsConflictKey(newSPSConflict(RuleAddressOverlap, SeverityError, "PLC1", "10", "send address 0.0 and send address 0.3 overlap in DB 10", a, b))
+	tests := []struct {
+		name     string
+		conflict SPSConflict
+		same     bool
+	}{
+		{name: "moved addresses", conflict: newSPSConflict(RuleAddressOverlap, SeverityError, "PLC1", "10", "send address 2.0 and send address 2.0 overlap in DB 10", a, b), same: true},
+		{name: "tools in another order", conflict: newSPSConflict(RuleAddressOverlap, SeverityError, "PLC1", "10", "", b, a), same: true},
+		{name: "another DB", conflict: newSPSConflict(RuleAddressOverlap, SeverityError, "PLC1", "11", "", a, b)},
+		{name: "another rule", conflict: newSPSConflict(RuleDuplicateDB, SeverityError, "PLC1", "10", "", a, b)},
+		{name: "another tool", conflict: newSPSConflict(RuleAddressOverlap, SeverityError, "PLC1", "10", "", a, c)},
+	}
+	for _, tt := range tests {
+		if got := spsConflictKey(tt.conflict) == base; got != tt.same {
+			t.Errorf("%s: same key = %v, want %v", tt.name, got, tt.same)
+		}
+	}
+}
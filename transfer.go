@@ -0,0 +1,490 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	ws "github.com/wailsapp/wails/v2/pkg/runtime"
+	"gorm.io/gorm"
+)
+
+// TransferProgress is emitted as "transfer:progress" while an export or import runs.
+// Exports count stations, imports count the bytes of the file read when a station is written.
+type TransferProgress struct {
+	Operation string `json:"operation"`
+	Stage     string `json:"stage"`
+	Done      int64  `json:"done"`
+	Total     int64  `json:"total"`
+}
+
+// errTransferCancelled is returned when CancelTransfer stops an export or import.
+var errTransferCancelled = errors.New("transfer cancelled")
+
+// errTransferRunning is returned when an export or import starts while another one runs.
+var errTransferRunning = errors.New("another export or import is already running")
+
+// errSkipImportWrite is returned by importWriter.beforeWrite to leave an entity and its children
+// out of the import and go on with the rest of the file.
+var errSkipImportWrite = errors.New("import entity skipped")
+
+// beginTransfer returns the context of a new export or import, which CancelTransfer cancels.
+// Only one transfer runs at a time.
+func (c *Core) beginTransfer() (context.Context, func(), error) {
+	parent := c.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	c.transferMu.Lock()
+	defer c.transferMu.Unlock()
+	if c.transferCancel != nil {
+		return nil, nil, errTransferRunning
+	}
+	ctx, cancel := context.WithCancel(parent)
+	c.transferCancel = cancel
+	return ctx, func() {
+		cancel()
+		c.transferMu.Lock()
+		c.transferCancel = nil
+		c.transferMu.Unlock()
+	}, nil
+}
+
+// CancelTransfer stops the running export or import; an import is rolled back.
+func (c *Core) CancelTransfer() {
+	c.transferMu.Lock()
+	defer c.transferMu.Unlock()
+	if c.transferCancel != nil {
+		c.transferCancel()
+		log.Println("Transfer cancelled by user.")
+	}
+}
+
+func (c *Core) emitTransferProgress(operation string, stage string, done int64, total int64) {
+	if c.ctx == nil {
+		return
+	}
+	ws.EventsEmit(c.ctx, "transfer:progress", TransferProgress{Operation: operation, Stage: stage, Done: done, Total: total})
+}
+
+// transferError reports a cancelled context as errTransferCancelled.
+func transferError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return errTransferCancelled
+	}
+	return err
+}
+
+// writeJSONObjectOpen writes v as a JSON object without its closing brace, so further members can follow.
+func writeJSONObjectOpen(w io.Writer, v interface{}) error {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	jsonData = bytes.TrimSuffix(bytes.TrimSpace(jsonData), []byte("}"))
+	_, err = w.Write(jsonData)
+	return err
+}
+
+// writeStreamedLineExport writes the envelope of a line export and then loads and encodes
+// one station at a time, so the whole line is never held in memory.
+func (c *Core) writeStreamedLineExport(ctx context.Context, w io.Writer, userName string, lineIDStr string) error {
+	db := c.DB.WithContext(ctx)
+	lineID, err := parseMSSQLUniqueIdentifierFromString(lineIDStr)
+	if err != nil {
+		return err
+	}
+	var line Line
+	if err := db.First(&line, "id = ?", lineID).Error; err != nil {
+		return fmt.Errorf("error loading line %s for export: %w", lineIDStr, err)
+	}
+	var stationIDs []mssql.UniqueIdentifier
+	if err := db.Model(&Station{}).Where("parent_id = ?", lineID).Pluck("id", &stationIDs).Error; err != nil {
+		return fmt.Errorf("error loading stations of line %s: %w", lineIDStr, err)
+	}
+
+	envelope, err := c.newExportEnvelope(userName, "line", nil)
+	if err != nil {
+		return err
+	}
+	// The outer fields shadow Data and Stations, which are written below.
+	if err := writeJSONObjectOpen(w, struct {
+		*ExportEnvelope
+		Data json.RawMessage `json:"data,omitempty"`
+	}{ExportEnvelope: envelope}); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `,"data":`); err != nil {
+		return err
+	}
+	if err := writeJSONObjectOpen(w, struct {
+		*Line
+		Stations json.RawMessage `json:"Stations,omitempty"`
+	}{Line: &line}); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `,"Stations":[`); err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	total := int64(len(stationIDs))
+	c.emitTransferProgress("export", "stations", 0, total)
+	for i, stationID := range stationIDs {
+		if ctx.Err() != nil {
+			return errTransferCancelled
+		}
+		var station Station
+		err := db.Preload("Tools.Operations").Preload("SequenceGroups.Operations").First(&station, "id = ?", stationID).Error
+		if err != nil {
+			return transferError(ctx, fmt.Errorf("error loading station %s for export: %w", stationID.String(), err))
+		}
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if err := encoder.Encode(&station); err != nil {
+			return fmt.Errorf("error converting station to JSON: %w", err)
+		}
+		c.emitTransferProgress("export", "stations", int64(i+1), total)
+	}
+	_, err = io.WriteString(w, "]}}\n")
+	return err
+}
+
+// countingReader counts the bytes read for progress reporting.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// expectDelim reads the next token and checks that it is the given delimiter.
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected '%s' in JSON, found %v", delim, token)
+	}
+	return nil
+}
+
+// decodeExportFile reads an export file with a streaming decoder and hands its content to onRoot
+// and onStation. Line exports in the current format are passed on one station at a time: onRoot
+// gets the line without its stations as soon as they start, then onStation gets every station.
+// Everything else goes through decodeExportPayload and is passed to onRoot as a whole.
+func (c *Core) decodeExportFile(ctx context.Context, filePath string, onRoot func(root interface{}, rootType string) error, onStation func(station *Station) error) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("error reading JSON file '%s': %w", filePath, err)
+	}
+	defer file.Close()
+	var size int64
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+	counter := &countingReader{r: bufio.NewReader(file)}
+	decoder := json.NewDecoder(counter)
+
+	if err := expectDelim(decoder, '{'); err != nil {
+		return fmt.Errorf("could not read import file: %w", err)
+	}
+	header := make(map[string]json.RawMessage)
+	streamed := false
+	for decoder.More() {
+		if ctx.Err() != nil {
+			return errTransferCancelled
+		}
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("could not read import file: %w", err)
+		}
+		key, _ := token.(string)
+		if key == "data" && isStreamableLineHeader(header) {
+			if err := c.decodeStreamedLine(ctx, decoder, header, counter, size, onRoot, onStation); err != nil {
+				return err
+			}
+			streamed = true
+			continue
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return fmt.Errorf("could not read import file: %w", err)
+		}
+		header[key] = value
+	}
+	if streamed {
+		return nil
+	}
+
+	// Legacy files and other root types are small enough to decode at once.
+	c.emitTransferProgress("import", "reading", size, size)
+	raw, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	envelope, err := c.decodeExportPayload(raw)
+	if err != nil {
+		return fmt.Errorf("could not read import file: %w", err)
+	}
+	rootImported, _ := getModelInstance(envelope.RootType)
+	if err := json.Unmarshal(envelope.Data, rootImported); err != nil {
+		return fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	c.emitTransferProgress("import", "writing", 0, 1)
+	if err := onRoot(rootImported, envelope.RootType); err != nil {
+		return err
+	}
+	c.emitTransferProgress("import", "writing", 1, 1)
+	return nil
+}
+
+// isStreamableLineHeader reports whether the envelope members read so far describe a line
+// export in the current schema version.
+func isStreamableLineHeader(header map[string]json.RawMessage) bool {
+	var format, rootType string
+	var schemaVersion int
+	if json.Unmarshal(header["format"], &format) != nil || json.Unmarshal(header["rootType"], &rootType) != nil ||
+		json.Unmarshal(header["schemaVersion"], &schemaVersion) != nil {
+		return false
+	}
+	return format == exportFormatName && schemaVersion == currentExportSchemaVersion && strings.ToLower(rootType) == "line"
+}
+
+// streamedLineRoot checks the envelope of a streamed line export and decodes the line fields read so far.
+func (c *Core) streamedLineRoot(header map[string]json.RawMessage, fields map[string]json.RawMessage) (*Line, error) {
+	lineJSON, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	header["data"] = lineJSON
+	raw, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.decodeExportPayload(raw); err != nil {
+		return nil, fmt.Errorf("could not read import file: %w", err)
+	}
+	line := &Line{}
+	if err := json.Unmarshal(lineJSON, line); err != nil {
+		return nil, fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	return line, nil
+}
+
+// decodeStreamedLine decodes the data member of a line export and passes each station to
+// onStation as soon as it is decoded. The line fields precede the stations in an export.
+func (c *Core) decodeStreamedLine(ctx context.Context, decoder *json.Decoder, header map[string]json.RawMessage, counter *countingReader, size int64, onRoot func(root interface{}, rootType string) error, onStation func(station *Station) error) error {
+	if err := expectDelim(decoder, '{'); err != nil {
+		return fmt.Errorf("could not read line data: %w", err)
+	}
+	fields := make(map[string]json.RawMessage)
+	rootWritten := false
+	writeRoot := func() error {
+		line, err := c.streamedLineRoot(header, fields)
+		if err != nil {
+			return err
+		}
+		rootWritten = true
+		return onRoot(line, "line")
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("could not read line data: %w", err)
+		}
+		key, _ := token.(string)
+		if key != "Stations" {
+			if rootWritten {
+				return fmt.Errorf("line field %s follows the stations", key)
+			}
+			var value json.RawMessage
+			if err := decoder.Decode(&value); err != nil {
+				return fmt.Errorf("could not read line data: %w", err)
+			}
+			fields[key] = value
+			continue
+		}
+		token, err = decoder.Token()
+		if err != nil {
+			return fmt.Errorf("could not read stations: %w", err)
+		}
+		if token == nil {
+			continue
+		}
+		if d, ok := token.(json.Delim); !ok || d != '[' {
+			return fmt.Errorf("expected station list, found %v", token)
+		}
+		if err := writeRoot(); err != nil {
+			return err
+		}
+		for decoder.More() {
+			if ctx.Err() != nil {
+				return errTransferCancelled
+			}
+			var station Station
+			if err := decoder.Decode(&station); err != nil {
+				return fmt.Errorf("error unmarshalling station: %w", err)
+			}
+			if err := onStation(&station); err != nil {
+				return err
+			}
+			c.emitTransferProgress("import", "writing", counter.n, size)
+		}
+		if err := expectDelim(decoder, ']'); err != nil {
+			return fmt.Errorf("could not read stations: %w", err)
+		}
+	}
+	if err := expectDelim(decoder, '}'); err != nil {
+		return fmt.Errorf("could not read line data: %w", err)
+	}
+	if !rootWritten {
+		return writeRoot()
+	}
+	return nil
+}
+
+// importWriter writes an import into a transaction with one of the import modes. A streamed
+// line is written with writeRoot for the line without its stations and writeStation per station.
+type importWriter struct {
+	c        *Core
+	tx       *gorm.DB
+	userName string
+	mode     string
+	// checkCatalog rejects tools and operations that break a dependency catalog rule.
+	checkCatalog bool
+	// beforeWrite, if set, is called with the root and every streamed station and the ID of
+	// its parent before it is written.
+	beforeWrite func(entity interface{}, entityType string, parentID mssql.UniqueIdentifier) error
+	rootType    string
+	rootID      mssql.UniqueIdentifier
+	idMap       map[mssql.UniqueIdentifier]mssql.UniqueIdentifier
+	merge       *mergeImport
+}
+
+func (c *Core) newImportWriter(tx *gorm.DB, userName string, mode string) (*importWriter, error) {
+	switch mode {
+	case ImportModeOriginal, ImportModeRemap, ImportModeMerge, ImportModeMergeDelete:
+	default:
+		return nil, fmt.Errorf("unknown import mode: %s", mode)
+	}
+	return &importWriter{c: c, tx: tx, userName: userName, mode: mode, checkCatalog: true}, nil
+}
+
+// writeRoot writes the root of an import under parentID, which prepareImportRoot has checked.
+func (w *importWriter) writeRoot(root interface{}, rootType string, parentID mssql.UniqueIdentifier) error {
+	w.rootType = rootType
+	w.rootID = getIDFromModel(root)
+	if w.beforeWrite != nil {
+		if err := w.beforeWrite(root, rootType, parentID); err != nil {
+			if errors.Is(err, errSkipImportWrite) {
+				if w.mode == ImportModeMerge || w.mode == ImportModeMergeDelete {
+					w.merge = newMergeImport(w.c, w.tx, w.userName, rootType, w.checkCatalog)
+				}
+				w.skip(root, rootType)
+				return nil
+			}
+			return err
+		}
+	}
+	if w.checkCatalog {
+		if err := w.c.checkImportCatalog(w.tx, root, rootType, parentID); err != nil {
+			return err
+		}
+	}
+	switch w.mode {
+	case ImportModeOriginal:
+		return importEntityRecursive_UseOriginalData(w.tx, w.userName, root, rootType, parentID)
+	case ImportModeRemap:
+		w.idMap = make(map[mssql.UniqueIdentifier]mssql.UniqueIdentifier)
+		_, err := importCopiedEntityRecursive(w.tx, w.userName, root, rootType, parentID, w.idMap)
+		w.rootID = w.idMap[w.rootID]
+		return err
+	default:
+		w.merge = newMergeImport(w.c, w.tx, w.userName, rootType, w.checkCatalog)
+		return w.merge.mergeEntity(root, rootType, parentID)
+	}
+}
+
+// writeStation writes a station of a streamed line below the line written by writeRoot.
+func (w *importWriter) writeStation(station *Station) error {
+	if w.rootType != "line" {
+		return errors.New("stations can only be written below a line import")
+	}
+	if w.beforeWrite != nil {
+		if err := w.beforeWrite(station, "station", w.rootID); err != nil {
+			if errors.Is(err, errSkipImportWrite) {
+				w.skip(station, "station")
+				return nil
+			}
+			return err
+		}
+	}
+	if w.checkCatalog {
+		if err := w.c.checkImportCatalog(w.tx, station, "station", w.rootID); err != nil {
+			return err
+		}
+	}
+	switch w.mode {
+	case ImportModeOriginal:
+		return importEntityRecursive_UseOriginalData(w.tx, w.userName, station, "station", w.rootID)
+	case ImportModeRemap:
+		_, err := importCopiedEntityRecursive(w.tx, w.userName, station, "station", w.rootID, w.idMap)
+		return err
+	default:
+		return w.merge.mergeEntity(station, "station", w.rootID)
+	}
+}
+
+// skip leaves an entity out of the import. A merge counts its entities as part of the file,
+// so ImportModeMergeDelete does not delete them.
+func (w *importWriter) skip(entity interface{}, entityType string) {
+	if w.merge == nil {
+		return
+	}
+	fileEntities := make(map[string]flatEntity)
+	flattenHierarchy(entity, entityType, fileEntities)
+	for id := range fileEntities {
+		w.merge.seen[id] = true
+	}
+}
+
+// finish completes the import after the last station, deleting missing entities for ImportModeMergeDelete.
+func (w *importWriter) finish() error {
+	if w.merge == nil {
+		return nil
+	}
+	return w.merge.finish(w.rootType, w.rootID, w.mode == ImportModeMergeDelete, w.c.hardDeleteEnabled())
+}
+
+// importFile reads an export file and writes it through w while it is read, so a streamed line
+// export never holds more than one station in memory.
+func (c *Core) importFile(ctx context.Context, w *importWriter, filePath string, parentIDStr string) error {
+	err := c.decodeExportFile(ctx, filePath, func(root interface{}, rootType string) error {
+		parentID, err := c.prepareImportRoot(w.tx, root, rootType, parentIDStr)
+		if err != nil {
+			return err
+		}
+		return w.writeRoot(root, rootType, parentID)
+	}, w.writeStation)
+	if err != nil {
+		return err
+	}
+	return w.finish()
+}
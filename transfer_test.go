@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+func TestEntityHierarchyLoadsParents(t *testing.T) {
+	c := newTestCore(t)
+	line, station, tool, op := createTestLine(t, c.DB)
+	group := &SequenceGroup{BaseModel: BaseModel{Name: strPtr("G1")}, ParentID: station.ID}
+	if err := c.DB.Create(group).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DB.Model(op).Update("group_id", group.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := internalGetEntityHierarchy(c.DB, "operation", op.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := data.(*Operation)
+	if loaded.Tool == nil || loaded.Tool.ID != tool.ID || loaded.Tool.Station == nil || loaded.Tool.Station.Line == nil || loaded.Tool.Station.Line.ID != line.ID {
+		t.Errorf("operation loaded without its tool, station and line: %+v", loaded.Tool)
+	}
+	if loaded.Group == nil || loaded.Group.ID != group.ID || loaded.Group.Station == nil || loaded.Group.Station.Line == nil {
+		t.Errorf("operation loaded without its sequence group, station and line: %+v", loaded.Group)
+	}
+
+	data, err = internalGetEntityHierarchy(c.DB, "station", station.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := data.(*Station); s.Line == nil || s.Line.ID != line.ID || len(s.Tools) != 1 || len(s.Tools[0].Operations) != 1 {
+		t.Errorf("station loaded without its line or children: %+v", s)
+	}
+}
+
+func TestImportStationUnderParent(t *testing.T) {
+	source := newTestCore(t)
+	_, station, _, _ := createTestLine(t, source.DB)
+	filePath := filepath.Join(t.TempDir(), "station.json")
+	if err := source.ExportEntityHierarchyToJSON("jdoe", "station", station.ID.String(), filePath); err != nil {
+		t.Fatalf("export returned %v", err)
+	}
+	raw, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), `"Line"`) {
+		t.Errorf("export of a station contains its line: %s", raw)
+	}
+
+	target := newTestCore(t)
+	line := &Line{BaseModel: BaseModel{Name: strPtr("L2")}}
+	if err := target.DB.Create(line).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := target.ImportEntityHierarchyFromJSON("jdoe", filePath, "", ImportModeOriginal); err == nil {
+		t.Error("import of a station without a parent returned no error")
+	}
+	if err := target.ImportEntityHierarchyFromJSON("jdoe", filePath, line.ID.String(), ImportModeOriginal); err != nil {
+		t.Fatalf("import returned %v", err)
+	}
+	var imported Station
+	if err := target.DB.Preload("Tools.Operations").First(&imported, "id = ?", station.ID).Error; err != nil {
+		t.Fatalf("imported station not found: %v", err)
+	}
+	if imported.ParentID != line.ID {
+		t.Errorf("station imported under %s, want %s", imported.ParentID, line.ID)
+	}
+	if len(imported.Tools) != 1 || len(imported.Tools[0].Operations) != 1 {
+		t.Errorf("station imported without its tool and operation: %+v", imported.Tools)
+	}
+}
+
+func TestPreviewImportListsAllCollisionsAndViolations(t *testing.T) {
+	c := newTestCore(t)
+	line, _, _, _ := createTestLine(t, c.DB)
+	station := &Station{BaseModel: BaseModel{Name: strPtr("S2")}, StationType: strPtr("1"), ParentID: line.ID}
+	if err := c.DB.Create(station).Error; err != nil {
+		t.Fatal(err)
+	}
+	badTool := &Tool{BaseModel: BaseModel{Name: strPtr("T2")}, ToolClass: strPtr("1"), ToolType: strPtr("20"), ParentID: station.ID}
+	if err := c.DB.Create(badTool).Error; err != nil {
+		t.Fatal(err)
+	}
+	filePath := filepath.Join(t.TempDir(), "line.json")
+	if err := c.ExportEntityHierarchyToJSON("jdoe", "line", line.ID.String(), filePath); err != nil {
+		t.Fatalf("export returned %v", err)
+	}
+
+	// Every entity of the file collides with itself, and the preview still checks all of them.
+	preview, err := c.PreviewImport(filePath, ImportModeOriginal, "")
+	if err != nil {
+		t.Fatalf("preview returned %v", err)
+	}
+	if preview.Success || preview.Error != "" {
+		t.Errorf("preview success %v, error %q, want a failed preview without an error", preview.Success, preview.Error)
+	}
+	if len(preview.IDCollisions) != 6 {
+		t.Errorf("preview lists %d ID collisions, want 6: %+v", len(preview.IDCollisions), preview.IDCollisions)
+	}
+	if len(preview.CompatibilityViolations) != 1 || preview.CompatibilityViolations[0].EntityID != badTool.ID.String() {
+		t.Errorf("compatibility violations %+v, want one for tool %s", preview.CompatibilityViolations, badTool.ID)
+	}
+	if len(preview.Created) != 0 || len(preview.Updated) != 0 {
+		t.Errorf("preview of a rejected line creates %d and updates %d entities", len(preview.Created), len(preview.Updated))
+	}
+
+	// Without collisions the violation is found among the written entities.
+	target := newTestCore(t)
+	preview, err = target.PreviewImport(filePath, ImportModeOriginal, "")
+	if err != nil {
+		t.Fatalf("preview returned %v", err)
+	}
+	if len(preview.IDCollisions) != 0 || len(preview.Created) != 6 {
+		t.Errorf("preview into an empty database lists %d collisions and %d created entities", len(preview.IDCollisions), len(preview.Created))
+	}
+	if preview.Success || len(preview.CompatibilityViolations) != 1 {
+		t.Errorf("preview success %v with violations %+v, want one violation", preview.Success, preview.CompatibilityViolations)
+	}
+}
+
+func TestMergeImportChecksUpdatedEntities(t *testing.T) {
+	c := newTestCore(t)
+	line, station, tool, _ := createTestLine(t, c.DB)
+	other := &Tool{
+		BaseModel: BaseModel{Name: strPtr("T2")}, ToolClass: strPtr("1"), ParentID: station.ID,
+		SPSPLCNameSPAService: strPtr("PLC1"), SPSDBNoSend: strPtr("10"), SPSAddressInSendDB: strPtr("1"),
+	}
+	if err := c.DB.Create(other).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DB.Model(tool).Updates(map[string]interface{}{"SPSPLCNameSPAService": "PLC1", "SPSDBNoSend": "10", "SPSAddressInSendDB": "0"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// model and updates are written before the export and reverted after it, so the file changes them.
+		entityType string
+		model      interface{}
+		updates    map[string]interface{}
+		wantErr    string
+	}{
+		{name: "valid name", entityType: "station", model: station, updates: map[string]interface{}{"Name": "S1b"}},
+		{name: "name too long", entityType: "station", model: station, updates: map[string]interface{}{"Name": "Station 123"}, wantErr: "longer than 10 characters"},
+		{name: "tool type of another class", entityType: "tool", model: tool, updates: map[string]interface{}{"ToolType": "20"}, wantErr: "does not belong to tool class"},
+		{name: "new SPS conflict", entityType: "tool", model: other, updates: map[string]interface{}{"SPSAddressInSendDB": "0"}, wantErr: "SPS"},
+	}
+	c.blockSPSConflicts = true
+	for _, tt := range tests {
+		reverts := make(map[string]interface{})
+		for field, value := range tt.updates {
+			// Copy the old value, the model's fields change with the update.
+			var old *string
+			if v := currentFieldValues(tt.model)[field]; v != nil {
+				old = strPtr(*v)
+			}
+			reverts[field] = old
+			if err := c.DB.Model(tt.model).Update(field, value).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+		filePath := filepath.Join(t.TempDir(), "line.json")
+		if err := c.ExportEntityHierarchyToJSON("jdoe", "line", line.ID.String(), filePath); err != nil {
+			t.Fatalf("%s: export returned %v", tt.name, err)
+		}
+		if err := c.DB.Model(tt.model).Updates(reverts).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		err := c.ImportEntityHierarchyFromJSON("jdoe", filePath, "", ImportModeMerge)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: merge import returned %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: merge import returned %v, want an error containing %q", tt.name, err, tt.wantErr)
+		case tt.wantErr != "":
+			// The rejected merge is rolled back.
+			fresh, _ := getModelInstance(tt.entityType)
+			if err := c.DB.First(fresh, "id = ?", getIDFromModel(tt.model)).Error; err != nil {
+				t.Fatal(err)
+			}
+			for field, old := range reverts {
+				if got := currentFieldValues(fresh)[field]; derefOrEmpty(got) != derefOrEmpty(old.(*string)) {
+					t.Errorf("%s: %s is %q after the rejected merge, want %q", tt.name, field, derefOrEmpty(got), derefOrEmpty(old.(*string)))
+				}
+			}
+		}
+	}
+}
+
+func TestImportRemapsIDs(t *testing.T) {
+	c := newTestCore(t)
+	line, station, _, op := createTestLine(t, c.DB)
+	group := &SequenceGroup{BaseModel: BaseModel{Name: strPtr("G1")}, ParentID: station.ID}
+	if err := c.DB.Create(group).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DB.Model(op).Update("group_id", group.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	filePath := filepath.Join(t.TempDir(), "station.json")
+	if err := c.ExportEntityHierarchyToJSON("jdoe", "station", station.ID.String(), filePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ImportEntityHierarchyFromJSON("jdoe", filePath, line.ID.String(), ImportModeOriginal); err == nil {
+		t.Fatal("import of existing IDs in original mode returned no error")
+	}
+
+	// Every import makes a copy of its own.
+	for i := 0; i < 2; i++ {
+		if err := c.ImportEntityHierarchyFromJSON("jdoe", filePath, line.ID.String(), ImportModeRemap); err != nil {
+			t.Fatalf("import %d in remap mode returned %v", i+1, err)
+		}
+	}
+	var stations []Station
+	if err := c.DB.Preload("Tools.Operations").Preload("SequenceGroups").Where("parent_id = ?", line.ID).Find(&stations).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(stations) != 3 {
+		t.Fatalf("line has %d stations, want the original and two copies", len(stations))
+	}
+	seen := make(map[mssql.UniqueIdentifier]bool)
+	for _, s := range stations {
+		if len(s.Tools) != 1 || len(s.Tools[0].Operations) != 1 || len(s.SequenceGroups) != 1 {
+			t.Fatalf("station %s has tools %+v and groups %+v", s.ID, s.Tools, s.SequenceGroups)
+		}
+		copied := s.Tools[0].Operations[0]
+		for _, id := range []mssql.UniqueIdentifier{s.ID, s.Tools[0].ID, copied.ID, s.SequenceGroups[0].ID} {
+			if seen[id] {
+				t.Errorf("ID %s used twice", id)
+			}
+			seen[id] = true
+		}
+		// Operations point at the group of their own copy.
+		if copied.GroupID == nil || *copied.GroupID != s.SequenceGroups[0].ID {
+			t.Errorf("operation %s of station %s is in group %v, want %s", copied.ID, s.ID, copied.GroupID, s.SequenceGroups[0].ID)
+		}
+	}
+}
+
+func TestStreamedLineTransfer(t *testing.T) {
+	source := newTestCore(t)
+	line, _, _, _ := createTestLine(t, source.DB)
+	var stations []*Station
+	for _, name := range []string{"S2", "S3"} {
+		station := &Station{BaseModel: BaseModel{Name: strPtr(name)}, StationType: strPtr("1"), ParentID: line.ID}
+		if err := source.DB.Create(station).Error; err != nil {
+			t.Fatal(err)
+		}
+		group := &SequenceGroup{BaseModel: BaseModel{Name: strPtr("G1")}, ParentID: station.ID}
+		tool := &Tool{BaseModel: BaseModel{Name: strPtr("T1")}, ToolClass: strPtr("1"), ParentID: station.ID}
+		for _, entity := range []interface{}{group, tool} {
+			if err := source.DB.Create(entity).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+		op := &Operation{BaseModel: BaseModel{Name: strPtr("O1")}, Template: strPtr("1"), ParentID: tool.ID, GroupID: &group.ID}
+		if err := source.DB.Create(op).Error; err != nil {
+			t.Fatal(err)
+		}
+		stations = append(stations, station)
+	}
+	filePath := filepath.Join(t.TempDir(), "line.json")
+	if err := source.ExportEntityHierarchyToJSON("jdoe", "line", line.ID.String(), filePath); err != nil {
+		t.Fatalf("export returned %v", err)
+	}
+
+	// The file is read one station at a time, after the line without its stations.
+	var rootStations, streamed int
+	err := source.decodeExportFile(context.Background(), filePath, func(root interface{}, rootType string) error {
+		rootStations = len(root.(*Line).Stations)
+		return nil
+	}, func(station *Station) error {
+		streamed++
+		return nil
+	})
+	if err != nil || rootStations != 0 || streamed != 3 {
+		t.Errorf("decoding passed a line with %d stations and %d single stations, %v, want 0 and 3", rootStations, streamed, err)
+	}
+
+	target := newTestCore(t)
+	if err := target.ImportEntityHierarchyFromJSON("jdoe", filePath, "", ImportModeOriginal); err != nil {
+		t.Fatalf("import returned %v", err)
+	}
+	for _, model := range []interface{}{&Station{}, &Tool{}, &Operation{}, &SequenceGroup{}} {
+		var want, got int64
+		source.DB.Model(model).Count(&want)
+		target.DB.Model(model).Count(&got)
+		if got != want {
+			t.Errorf("%d %T imported, want %d", got, model, want)
+		}
+	}
+	var linked int64
+	target.DB.Model(&Operation{}).Where("group_id IS NOT NULL").Count(&linked)
+	if linked != 2 {
+		t.Errorf("%d imported operations are in a sequence group, want 2", linked)
+	}
+
+	// A merge that deletes missing entities removes the station deleted in the source.
+	if err := source.DeleteEntityByIDString("jdoe", "station", stations[1].ID.String()); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.ExportEntityHierarchyToJSON("jdoe", "line", line.ID.String(), filePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.ImportEntityHierarchyFromJSON("jdoe", filePath, "", ImportModeMergeDelete); err != nil {
+		t.Fatalf("merge import returned %v", err)
+	}
+	var left []Station
+	if err := target.DB.Order("name").Find(&left).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 2 || derefOrEmpty(left[0].Name) != "S1" || derefOrEmpty(left[1].Name) != "S2" {
+		t.Errorf("stations after the merge %+v, want S1 and S2", left)
+	}
+
+	// Only one transfer runs at a time.
+	_, finish, err := target.beginTransfer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer finish()
+	if err := target.ImportEntityHierarchyFromJSON("jdoe", filePath, "", ImportModeMerge); !errors.Is(err, errTransferRunning) {
+		t.Errorf("import during another transfer returned %v, want %v", err, errTransferRunning)
+	}
+}
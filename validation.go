@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const SeverityError = "error"
+const SeverityWarning = "warning"
+
+// Rule IDs of ValidationFinding.
+const (
+	RuleCatalogOperation     = "catalog-operation"
+	RuleCatalogTool          = "catalog-tool"
+	RuleRequiredField        = "required-field"
+	RuleGroupInOtherStation  = "group-in-other-station"
+	RuleDuplicateSequence    = "duplicate-sequence"
+	RuleDuplicateToolName    = "duplicate-tool-name"
+	RuleToolWithoutOperation = "tool-without-operations"
+)
+
+type ValidationFinding struct {
+	Severity   string `json:"severity"`
+	RuleID     string `json:"ruleId"`
+	EntityType string `json:"entityType"`
+	EntityID   string `json:"entityId"`
+	Path       string `json:"path"`
+	Message    string `json:"message"`
+}
+
+type ValidationReport struct {
+	RootType string              `json:"rootType"`
+	RootID   string              `json:"rootId"`
+	Errors   int                 `json:"errors"`
+	Warnings int                 `json:"warnings"`
+	Findings []ValidationFinding `json:"findings"`
+}
+
+type requiredField struct {
+	field    string
+	severity string
+	value    func(entity interface{}) *string
+}
+
+// requiredFields lists per entity type the fields a release needs. Catalog fields accept "none" as unset.
+var requiredFields = map[string][]requiredField{
+	"station": {
+		{"StationType", SeverityWarning, func(e interface{}) *string { return e.(*Station).StationType }},
+	},
+	"tool": {
+		{"ToolClass", SeverityWarning, func(e interface{}) *string { return e.(*Tool).ToolClass }},
+		{"ToolType", SeverityWarning, func(e interface{}) *string { return e.(*Tool).ToolType }},
+	},
+	"operation": {
+		{"Template", SeverityWarning, func(e interface{}) *string { return e.(*Operation).Template }},
+		{"SerialOrParallel", SeverityWarning, func(e interface{}) *string { return e.(*Operation).SerialOrParallel }},
+	},
+}
+
+type hierarchyValidator struct {
+	catalog *Data
+	report  *ValidationReport
+}
+
+func (v *hierarchyValidator) add(severity string, ruleID string, entityType string, entityID string, path string, message string) {
+	v.report.Findings = append(v.report.Findings, ValidationFinding{
+		Severity: severity, RuleID: ruleID, EntityType: entityType, EntityID: entityID, Path: path, Message: message,
+	})
+	if severity == SeverityError {
+		v.report.Errors++
+	} else {
+		v.report.Warnings++
+	}
+}
+
+func entityPath(parentPath string, entityType string, name *string) string {
+	label := derefOrEmpty(name)
+	if label == "" {
+		label = "(" + entityType + " without name)"
+	}
+	if parentPath == "" {
+		return label
+	}
+	return parentPath + " / " + label
+}
+
+func (v *hierarchyValidator) checkRequired(entityType string, entity interface{}, entityID string, name *string, path string) {
+	if strings.TrimSpace(derefOrEmpty(name)) == "" {
+		v.add(SeverityError, RuleRequiredField, entityType, entityID, path, "Name is missing")
+	}
+	for _, field := range requiredFields[entityType] {
+		if catalogValue(field.value(entity)) == "" {
+			v.add(field.severity, RuleRequiredField, entityType, entityID, path, field.field+" is missing")
+		}
+	}
+}
+
+func (v *hierarchyValidator) validateStation(linePath string, station *Station) {
+	stationID := station.ID.String()
+	stationPath := entityPath(linePath, "station", station.Name)
+	v.checkRequired("station", station, stationID, station.Name, stationPath)
+
+	groups := make(map[string]*SequenceGroup, len(station.SequenceGroups))
+	for i := range station.SequenceGroups {
+		groups[station.SequenceGroups[i].ID.String()] = &station.SequenceGroups[i]
+	}
+	toolsByName := make(map[string][]*Tool)
+	type sequencedOperation struct {
+		op   *Operation
+		path string
+	}
+	sequences := make(map[string]map[string][]sequencedOperation)
+
+	for i := range station.Tools {
+		tool := &station.Tools[i]
+		toolID := tool.ID.String()
+		toolPath := entityPath(stationPath, "tool", tool.Name)
+		v.checkRequired("tool", tool, toolID, tool.Name, toolPath)
+		if name := strings.ToLower(strings.TrimSpace(derefOrEmpty(tool.Name))); name != "" {
+			toolsByName[name] = append(toolsByName[name], tool)
+		}
+		for _, violation := range toolCatalogViolations(v.catalog, tool) {
+			v.add(SeverityError, RuleCatalogTool, "tool", toolID, toolPath, violation)
+		}
+		if len(tool.Operations) == 0 {
+			v.add(SeverityWarning, RuleToolWithoutOperation, "tool", toolID, toolPath, "tool has no operations")
+		}
+
+		for j := range tool.Operations {
+			op := &tool.Operations[j]
+			opID := op.ID.String()
+			opPath := entityPath(toolPath, "operation", op.Name)
+			v.checkRequired("operation", op, opID, op.Name, opPath)
+			for _, violation := range operationCatalogViolations(v.catalog, *op, tool, station) {
+				v.add(SeverityError, RuleCatalogOperation, "operation", opID, opPath, violation)
+			}
+			if op.GroupID == nil {
+				continue
+			}
+			groupID := op.GroupID.String()
+			if _, ok := groups[groupID]; !ok {
+				v.add(SeverityError, RuleGroupInOtherStation, "operation", opID, opPath,
+					fmt.Sprintf("sequence group %s does not belong to station '%s'", groupID, derefOrEmpty(station.Name)))
+				continue
+			}
+			// Parallel operations share sequence 0.
+			sequence := strings.TrimSpace(derefOrEmpty(op.Sequence))
+			if sequence == "" || sequence == "0" {
+				continue
+			}
+			if sequences[groupID] == nil {
+				sequences[groupID] = make(map[string][]sequencedOperation)
+			}
+			sequences[groupID][sequence] = append(sequences[groupID][sequence], sequencedOperation{op: op, path: opPath})
+		}
+	}
+
+	names := make([]string, 0, len(toolsByName))
+	for name := range toolsByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		tools := toolsByName[name]
+		if len(tools) < 2 {
+			continue
+		}
+		for _, tool := range tools {
+			v.add(SeverityError, RuleDuplicateToolName, "tool", tool.ID.String(), entityPath(stationPath, "tool", tool.Name),
+				fmt.Sprintf("tool name '%s' is used %d times in the station", derefOrEmpty(tool.Name), len(tools)))
+		}
+	}
+
+	for i := range station.SequenceGroups {
+		group := &station.SequenceGroups[i]
+		bySequence := sequences[group.ID.String()]
+		keys := make([]string, 0, len(bySequence))
+		for sequence := range bySequence {
+			keys = append(keys, sequence)
+		}
+		sort.Slice(keys, func(a, b int) bool { return compareNatural(keys[a], keys[b]) < 0 })
+		for _, sequence := range keys {
+			ops := bySequence[sequence]
+			if len(ops) < 2 {
+				continue
+			}
+			for _, entry := range ops {
+				v.add(SeverityError, RuleDuplicateSequence, "operation", entry.op.ID.String(), entry.path,
+					fmt.Sprintf("sequence %s is used %d times in sequence group '%s'", sequence, len(ops), derefOrEmpty(group.Name)))
+			}
+		}
+	}
+}
+
+// ValidateHierarchy checks a line or station for problems that would break a release and
+// returns one finding per problem.
+func (c *Core) ValidateHierarchy(entityTypeStr string, entityIDStr string) (*ValidationReport, error) {
+	if c.DB == nil {
+		return nil, errors.New("DB not initialized")
+	}
+	entityTypeStr = strings.ToLower(entityTypeStr)
+	if entityTypeStr != "line" && entityTypeStr != "station" {
+		return nil, fmt.Errorf("validation scope must be line or station, not %s", entityTypeStr)
+	}
+	catalog, err := c.loadDependencyData()
+	if err != nil {
+		return nil, err
+	}
+	hierarchyData, err := internalGetEntityHierarchy(c.DB, entityTypeStr, entityIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("error loading hierarchy for validation: %w", err)
+	}
+
+	v := &hierarchyValidator{
+		catalog: catalog,
+		report:  &ValidationReport{RootType: entityTypeStr, RootID: entityIDStr, Findings: []ValidationFinding{}},
+	}
+	switch root := hierarchyData.(type) {
+	case *Line:
+		linePath := entityPath("", "line", root.Name)
+		v.checkRequired("line", root, root.ID.String(), root.Name, linePath)
+		sort.SliceStable(root.Stations, func(i, j int) bool {
+			return compareNatural(derefOrEmpty(root.Stations[i].Name), derefOrEmpty(root.Stations[j].Name)) < 0
+		})
+		for i := range root.Stations {
+			v.validateStation(linePath, &root.Stations[i])
+		}
+	case *Station:
+		var line Line
+		if err := c.DB.Select("id, name").First(&line, "id = ?", root.ParentID).Error; err != nil {
+			return nil, fmt.Errorf("error loading line of station: %w", err)
+		}
+		v.validateStation(entityPath("", "line", line.Name), root)
+	}
+	return v.report, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestValidateHierarchy(t *testing.T) {
+	c := newTestCore(t)
+	line, station, tool, op := createTestLine(t, c.DB)
+	other := &Station{BaseModel: BaseModel{Name: strPtr("S2")}, StationType: strPtr("1"), ParentID: line.ID}
+	if err := c.DB.Create(other).Error; err != nil {
+		t.Fatal(err)
+	}
+	group := &SequenceGroup{BaseModel: BaseModel{Name: strPtr("G1")}, ParentID: station.ID}
+	otherGroup := &SequenceGroup{BaseModel: BaseModel{Name: strPtr("G2")}, ParentID: other.ID}
+	namesake := &Tool{BaseModel: BaseModel{Name: strPtr("t1 ")}, ToolClass: strPtr("1"), ToolType: strPtr("20"), ParentID: station.ID}
+	for _, entity := range []interface{}{group, otherGroup, namesake} {
+		if err := c.DB.Create(entity).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.DB.Model(op).Updates(map[string]interface{}{"group_id": group.ID, "sequence": "2"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range []*Operation{
+		{BaseModel: BaseModel{Name: strPtr("O2")}, Template: strPtr("1"), SerialOrParallel: strPtr("1"), Sequence: strPtr("2"), GroupID: &group.ID, ParentID: tool.ID},
+		{BaseModel: BaseModel{Name: strPtr("O3")}, Template: strPtr("1"), SerialOrParallel: strPtr("1"), Sequence: strPtr("2"), GroupID: &otherGroup.ID, ParentID: tool.ID},
+	} {
+		if err := c.DB.Create(o).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := c.ValidateHierarchy("Line", line.ID.String())
+	if err != nil {
+		t.Fatalf("validation returned %v", err)
+	}
+	var got []string
+	for _, finding := range report.Findings {
+		got = append(got, finding.Severity+" "+finding.RuleID+" "+finding.Path)
+	}
+	sort.Strings(got)
+	want := []string{
+		SeverityError + " " + RuleCatalogTool + " L1 / S1 / t1 ",
+		SeverityError + " " + RuleDuplicateSequence + " L1 / S1 / T1 / O1",
+		SeverityError + " " + RuleDuplicateSequence + " L1 / S1 / T1 / O2",
+		SeverityError + " " + RuleDuplicateToolName + " L1 / S1 / T1",
+		SeverityError + " " + RuleDuplicateToolName + " L1 / S1 / t1 ",
+		SeverityError + " " + RuleGroupInOtherStation + " L1 / S1 / T1 / O3",
+		SeverityWarning + " " + RuleRequiredField + " L1 / S1 / T1",
+		SeverityWarning + " " + RuleToolWithoutOperation + " L1 / S1 / t1 ",
+	}
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findings\n%q\nwant\n%q", got, want)
+	}
+	if report.Errors != 6 || report.Warnings != 2 {
+		t.Errorf("report counts %d errors and %d warnings, want 6 and 2", report.Errors, report.Warnings)
+	}
+
+	report, err = c.ValidateHierarchy("station", other.ID.String())
+	if err != nil {
+		t.Fatalf("validation of a station returned %v", err)
+	}
+	if len(report.Findings) != 0 {
+		t.Errorf("station without problems has findings %+v", report.Findings)
+	}
+	if _, err := c.ValidateHierarchy("tool", tool.ID.String()); err == nil {
+		t.Error("validation of a tool returned no error")
+	}
+}
@@ -0,0 +1,756 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"gorm.io/gorm"
+)
+
+// versionedFields returns the user-editable fields of a live entity or one of its history snapshots,
+// keyed by the same field names the frontend sends to UpdateEntityFieldsString.
+func versionedFields(entityData interface{}) (map[string]*string, error) {
+	switch v := entityData.(type) {
+	case *Line:
+		return map[string]*string{
+			"Name":         v.Name,
+			"Comment":      v.Comment,
+			"StatusColor":  v.StatusColor,
+			"AssemblyArea": v.AssemblyArea,
+		}, nil
+	case *LineHistory:
+		return map[string]*string{
+			"Name":         v.Name,
+			"Comment":      v.Comment,
+			"StatusColor":  v.StatusColor,
+			"AssemblyArea": v.AssemblyArea,
+		}, nil
+	case *Station:
+		return map[string]*string{
+			"Name":        v.Name,
+			"Comment":     v.Comment,
+			"StatusColor": v.StatusColor,
+			"Description": v.Description,
+			"StationType": v.StationType,
+		}, nil
+	case *StationHistory:
+		return map[string]*string{
+			"Name":        v.Name,
+			"Comment":     v.Comment,
+			"StatusColor": v.StatusColor,
+			"Description": v.Description,
+			"StationType": v.StationType,
+		}, nil
+	case *Tool:
+		return map[string]*string{
+			"Name":                  v.Name,
+			"Comment":               v.Comment,
+			"StatusColor":           v.StatusColor,
+			"ToolClass":             v.ToolClass,
+			"ToolType":              v.ToolType,
+			"Description":           v.Description,
+			"IpAddressDevice":       v.IpAddressDevice,
+			"SPSPLCNameSPAService":  v.SPSPLCNameSPAService,
+			"SPSDBNoSend":           v.SPSDBNoSend,
+			"SPSDBNoReceive":        v.SPSDBNoReceive,
+			"SPSPreCheck":           v.SPSPreCheck,
+			"SPSAddressInSendDB":    v.SPSAddressInSendDB,
+			"SPSAddressInReceiveDB": v.SPSAddressInReceiveDB,
+		}, nil
+	case *ToolHistory:
+		return map[string]*string{
+			"Name":                  v.Name,
+			"Comment":               v.Comment,
+			"StatusColor":           v.StatusColor,
+			"ToolClass":             v.ToolClass,
+			"ToolType":              v.ToolType,
+			"Description":           v.Description,
+			"IpAddressDevice":       v.IpAddressDevice,
+			"SPSPLCNameSPAService":  v.SPSPLCNameSPAService,
+			"SPSDBNoSend":           v.SPSDBNoSend,
+			"SPSDBNoReceive":        v.SPSDBNoReceive,
+			"SPSPreCheck":           v.SPSPreCheck,
+			"SPSAddressInSendDB":    v.SPSAddressInSendDB,
+			"SPSAddressInReceiveDB": v.SPSAddressInReceiveDB,
+		}, nil
+	case *Operation:
+		return map[string]*string{
+			"Name":              v.Name,
+			"Comment":           v.Comment,
+			"StatusColor":       v.StatusColor,
+			"Description":       v.Description,
+			"DecisionCriteria":  v.DecisionCriteria,
+			"SerialOrParallel":  v.SerialOrParallel,
+			"SequenceGroup":     v.SequenceGroup,
+			"Sequence":          v.Sequence,
+			"AlwaysPerform":     v.AlwaysPerform,
+			"QGateRelevant":     v.QGateRelevant,
+			"Template":          v.Template,
+			"DecisionClass":     v.DecisionClass,
+			"SavingClass":       v.SavingClass,
+			"VerificationClass": v.VerificationClass,
+			"GenerationClass":   v.GenerationClass,
+		}, nil
+	case *OperationHistory:
+		return map[string]*string{
+			"Name":              v.Name,
+			"Comment":           v.Comment,
+			"StatusColor":       v.StatusColor,
+			"Description":       v.Description,
+			"DecisionCriteria":  v.DecisionCriteria,
+			"SerialOrParallel":  v.SerialOrParallel,
+			"SequenceGroup":     v.SequenceGroup,
+			"Sequence":          v.Sequence,
+			"AlwaysPerform":     v.AlwaysPerform,
+			"QGateRelevant":     v.QGateRelevant,
+			"Template":          v.Template,
+			"DecisionClass":     v.DecisionClass,
+			"SavingClass":       v.SavingClass,
+			"VerificationClass": v.VerificationClass,
+			"GenerationClass":   v.GenerationClass,
+		}, nil
+	case *SequenceGroup:
+		return map[string]*string{
+			"Name":        v.Name,
+			"Comment":     v.Comment,
+			"StatusColor": v.StatusColor,
+			"Index":       v.Index,
+		}, nil
+	case *SequenceGroupHistory:
+		return map[string]*string{
+			"Name":        v.Name,
+			"Comment":     v.Comment,
+			"StatusColor": v.StatusColor,
+			"Index":       v.Index,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown entity type for versioned fields: %T", entityData)
+	}
+}
+
+// currentFieldValues extends versionedFields with the sequence group assignment of operations,
+// which clients may update but which is not part of the history snapshots.
+func currentFieldValues(entity interface{}) map[string]*string {
+	fields, err := versionedFields(entity)
+	if err != nil {
+		return map[string]*string{}
+	}
+	if op, ok := entity.(*Operation); ok {
+		fields["GroupID"] = nil
+		if op.GroupID != nil {
+			fields["GroupID"] = strPtr(op.GroupID.String())
+		}
+	}
+	return fields
+}
+
+func derefOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// loadEntityVersion loads a single history snapshot of an entity.
+func loadEntityVersion(db *gorm.DB, entityTypeStr string, entityID mssql.UniqueIdentifier, version int) (interface{}, error) {
+	historyModel, err := getHistoryModelInstance(entityTypeStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Where("entity_id = ? AND version = ?", entityID, version).Take(historyModel).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("version %d of %s with ID %s not found", version, entityTypeStr, entityID.String())
+		}
+		return nil, fmt.Errorf("error loading version %d of %s with ID %s: %w", version, entityTypeStr, entityID.String(), err)
+	}
+	return historyModel, nil
+}
+
+// RestoreEntityVersion writes the fields of a history snapshot back into the live entity.
+// The current state is stored as a new version before it is overwritten.
+func (c *Core) RestoreEntityVersion(userName string, entityTypeStr string, entityIDStr string, lastKnownUpdatedAtStr string, version int) (interface{}, error) {
+	if c.DB == nil {
+		return nil, errors.New("DB not initialized")
+	}
+	if userName == "" {
+		return nil, errors.New("userName is required for restore")
+	}
+	entityIDmssql, err := parseMSSQLUniqueIdentifierFromString(entityIDStr)
+	if err != nil {
+		return nil, err
+	}
+	lastKnownUpdatedAt, err := parseTimestampFlexible(lastKnownUpdatedAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid updated_at format ('%s'): %w", lastKnownUpdatedAtStr, err)
+	}
+	entityTypeNormalized := strings.ToLower(entityTypeStr)
+
+	var finalModelInstance interface{}
+	err = c.DB.Transaction(func(tx *gorm.DB) error {
+		modelToRestore, err := getModelInstance(entityTypeNormalized)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(modelToRestore, "id = ?", entityIDmssql).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("record not found or already deleted")
+			}
+			return fmt.Errorf("error loading entity for restore: %w", err)
+		}
+		if err := checkUpdatedAtConflict(modelToRestore, lastKnownUpdatedAt); err != nil {
+			return err
+		}
+
+		snapshot, err := loadEntityVersion(tx, entityTypeNormalized, entityIDmssql, version)
+		if err != nil {
+			return err
+		}
+		snapshotFields, err := versionedFields(snapshot)
+		if err != nil {
+			return err
+		}
+		liveFields, err := versionedFields(modelToRestore)
+		if err != nil {
+			return err
+		}
+
+		changedFields := make(map[string]string)
+		for field, value := range snapshotFields {
+			if derefOrEmpty(value) != derefOrEmpty(liveFields[field]) {
+				changedFields[field] = derefOrEmpty(value)
+			}
+		}
+		if len(changedFields) == 0 {
+			finalModelInstance = modelToRestore
+			return nil
+		}
+
+		// The snapshot may predate the current rules, so it is checked like an update from the editor.
+		if err := c.validateEntityUpdate(entityTypeNormalized, changedFields); err != nil {
+			return err
+		}
+		checkSPS := false
+		var spsConflictsBefore []SPSConflict
+		if tool, ok := modelToRestore.(*Tool); ok && c.spsConflictCheckEnabled() {
+			for field := range changedFields {
+				if spsFields[field] {
+					checkSPS = true
+					break
+				}
+			}
+			if checkSPS {
+				if spsConflictsBefore, err = toolSPSConflicts(tx, tool); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := createVersion(tx, entityTypeNormalized, modelToRestore); err != nil {
+			return fmt.Errorf("failed to create entity version: %w", err)
+		}
+		fieldChanges := buildFieldChanges(modelToRestore, changedFields)
+
+		gormUpdates := make(map[string]interface{})
+		for k := range changedFields {
+			// Fields the snapshot has no value for are cleared, not set to an empty string.
+			gormUpdates[k] = snapshotFields[k]
+		}
+		gormUpdates["updated_by"] = strPtr(userName)
+		gormUpdates["updated_at"] = time.Now()
+		if errUpdate := tx.Model(modelToRestore).Where("id = ?", entityIDmssql).Updates(gormUpdates).Error; errUpdate != nil {
+			return fmt.Errorf("error restoring version %d: %w", version, errUpdate)
+		}
+
+		reloadedEntityWithinTx, _ := getModelInstance(entityTypeNormalized)
+		if errLoad := tx.First(reloadedEntityWithinTx, "id = ?", entityIDmssql).Error; errLoad != nil {
+			return fmt.Errorf("error reloading entity after restore within tx: %w", errLoad)
+		}
+		finalModelInstance = reloadedEntityWithinTx
+
+		_, toolClassChanged := changedFields["ToolClass"]
+		_, stationTypeChanged := changedFields["StationType"]
+		if toolClassChanged || stationTypeChanged {
+			if err := c.resetDependentOperations(tx, userName, reloadedEntityWithinTx); err != nil {
+				return err
+			}
+		}
+		for field := range changedFields {
+			if catalogRuleFields[field] {
+				if err := c.checkUpdatedEntityCatalog(tx, reloadedEntityWithinTx); err != nil {
+					return err
+				}
+				break
+			}
+		}
+		if tool, ok := reloadedEntityWithinTx.(*Tool); ok && checkSPS {
+			if err := checkToolSPSConflicts(tx, tool, spsConflictsBefore); err != nil {
+				return err
+			}
+		}
+
+		return updateGlobalLastUpdateTimestampAndLogChange(tx, entityIDmssql, entityTypeNormalized, OpTypeUpdate, strPtr(userName), fieldChanges)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return finalModelInstance, nil
+}
+
+// versionAuthor returns who last touched the given live entity or history snapshot, and when.
+func versionAuthor(entityData interface{}) (*string, time.Time) {
+	switch v := entityData.(type) {
+	case *Line:
+		return v.UpdatedBy, v.UpdatedAt
+	case *LineHistory:
+		return v.UpdatedBy, v.UpdatedAt
+	case *Station:
+		return v.UpdatedBy, v.UpdatedAt
+	case *StationHistory:
+		return v.UpdatedBy, v.UpdatedAt
+	case *Tool:
+		return v.UpdatedBy, v.UpdatedAt
+	case *ToolHistory:
+		return v.UpdatedBy, v.UpdatedAt
+	case *Operation:
+		return v.UpdatedBy, v.UpdatedAt
+	case *OperationHistory:
+		return v.UpdatedBy, v.UpdatedAt
+	case *SequenceGroup:
+		return v.UpdatedBy, v.UpdatedAt
+	case *SequenceGroupHistory:
+		return v.UpdatedBy, v.UpdatedAt
+	default:
+		return nil, time.Time{}
+	}
+}
+
+type FieldDiff struct {
+	Field    string  `json:"field"`
+	OldValue *string `json:"oldValue"`
+	NewValue *string `json:"newValue"`
+}
+
+type VersionDiff struct {
+	EntityType    string      `json:"entityType"`
+	EntityID      string      `json:"entityId"`
+	FromVersion   int         `json:"fromVersion"`
+	ToVersion     int         `json:"toVersion"`
+	FromUpdatedBy *string     `json:"fromUpdatedBy"`
+	FromUpdatedAt time.Time   `json:"fromUpdatedAt"`
+	ToUpdatedBy   *string     `json:"toUpdatedBy"`
+	ToUpdatedAt   time.Time   `json:"toUpdatedAt"`
+	Changes       []FieldDiff `json:"changes"`
+}
+
+// loadEntityVersionOrLive loads a history snapshot, or the live entity when version is 0.
+func loadEntityVersionOrLive(db *gorm.DB, entityTypeStr string, entityID mssql.UniqueIdentifier, version int) (interface{}, error) {
+	if version != 0 {
+		return loadEntityVersion(db, entityTypeStr, entityID, version)
+	}
+	modelInstance, err := getModelInstance(entityTypeStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Where("id = ?", entityID).Take(modelInstance).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("entity type %s with ID %s not found", entityTypeStr, entityID.String())
+		}
+		return nil, fmt.Errorf("error loading entity type %s with ID %s: %w", entityTypeStr, entityID.String(), err)
+	}
+	return modelInstance, nil
+}
+
+// DiffEntityVersions compares two versions of an entity field by field. Version 0 stands for the live row.
+func (c *Core) DiffEntityVersions(entityTypeStr string, entityIDStr string, fromVersion int, toVersion int) (*VersionDiff, error) {
+	if c.DB == nil {
+		return nil, errors.New("DB not initialized")
+	}
+	entityIDmssql, err := parseMSSQLUniqueIdentifierFromString(entityIDStr)
+	if err != nil {
+		return nil, err
+	}
+	entityTypeNormalized := strings.ToLower(entityTypeStr)
+
+	fromEntity, err := loadEntityVersionOrLive(c.DB, entityTypeNormalized, entityIDmssql, fromVersion)
+	if err != nil {
+		return nil, err
+	}
+	toEntity, err := loadEntityVersionOrLive(c.DB, entityTypeNormalized, entityIDmssql, toVersion)
+	if err != nil {
+		return nil, err
+	}
+	fromFields, err := versionedFields(fromEntity)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := versionedFields(toEntity)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &VersionDiff{
+		EntityType:  entityTypeNormalized,
+		EntityID:    entityIDmssql.String(),
+		FromVersion: fromVersion,
+		ToVersion:   toVersion,
+		Changes:     []FieldDiff{},
+	}
+	diff.FromUpdatedBy, diff.FromUpdatedAt = versionAuthor(fromEntity)
+	diff.ToUpdatedBy, diff.ToUpdatedAt = versionAuthor(toEntity)
+
+	fieldNames := make([]string, 0, len(toFields))
+	for field := range toFields {
+		fieldNames = append(fieldNames, field)
+	}
+	sort.Strings(fieldNames)
+	for _, field := range fieldNames {
+		if derefOrEmpty(fromFields[field]) != derefOrEmpty(toFields[field]) {
+			diff.Changes = append(diff.Changes, FieldDiff{Field: field, OldValue: fromFields[field], NewValue: toFields[field]})
+		}
+	}
+	return diff, nil
+}
+
+// applyVersionedFields overwrites the user-editable fields of a live entity with the given values.
+func applyVersionedFields(entity interface{}, fields map[string]*string) error {
+	switch e := entity.(type) {
+	case *Line:
+		e.Name, e.Comment, e.StatusColor = fields["Name"], fields["Comment"], fields["StatusColor"]
+		e.AssemblyArea = fields["AssemblyArea"]
+	case *Station:
+		e.Name, e.Comment, e.StatusColor = fields["Name"], fields["Comment"], fields["StatusColor"]
+		e.Description = fields["Description"]
+		e.StationType = fields["StationType"]
+	case *Tool:
+		e.Name, e.Comment, e.StatusColor = fields["Name"], fields["Comment"], fields["StatusColor"]
+		e.ToolClass = fields["ToolClass"]
+		e.ToolType = fields["ToolType"]
+		e.Description = fields["Description"]
+		e.IpAddressDevice = fields["IpAddressDevice"]
+		e.SPSPLCNameSPAService = fields["SPSPLCNameSPAService"]
+		e.SPSDBNoSend = fields["SPSDBNoSend"]
+		e.SPSDBNoReceive = fields["SPSDBNoReceive"]
+		e.SPSPreCheck = fields["SPSPreCheck"]
+		e.SPSAddressInSendDB = fields["SPSAddressInSendDB"]
+		e.SPSAddressInReceiveDB = fields["SPSAddressInReceiveDB"]
+	case *Operation:
+		e.Name, e.Comment, e.StatusColor = fields["Name"], fields["Comment"], fields["StatusColor"]
+		e.Description = fields["Description"]
+		e.DecisionCriteria = fields["DecisionCriteria"]
+		e.SerialOrParallel = fields["SerialOrParallel"]
+		e.SequenceGroup = fields["SequenceGroup"]
+		e.Sequence = fields["Sequence"]
+		e.AlwaysPerform = fields["AlwaysPerform"]
+		e.QGateRelevant = fields["QGateRelevant"]
+		e.Template = fields["Template"]
+		e.DecisionClass = fields["DecisionClass"]
+		e.SavingClass = fields["SavingClass"]
+		e.VerificationClass = fields["VerificationClass"]
+		e.GenerationClass = fields["GenerationClass"]
+	case *SequenceGroup:
+		e.Name, e.Comment, e.StatusColor = fields["Name"], fields["Comment"], fields["StatusColor"]
+		e.Index = fields["Index"]
+	default:
+		return fmt.Errorf("unknown entity type for applying versioned fields: %T", entity)
+	}
+	return nil
+}
+
+// chunkIDs splits an ID list so IN clauses stay below the SQL Server parameter limit.
+func chunkIDs(ids []string, size int) [][]string {
+	var chunks [][]string
+	for size < len(ids) {
+		ids, chunks = ids[size:], append(chunks, ids[0:size:size])
+	}
+	if len(ids) > 0 {
+		chunks = append(chunks, ids)
+	}
+	return chunks
+}
+
+// asOfState holds everything needed to rewind the entities of one hierarchy to a point in time.
+type asOfState struct {
+	asOf      time.Time
+	snapshots map[string]interface{}
+	deleted   map[string]bool
+}
+
+// loadAsOfState loads, for every entity ID of the hierarchy, the newest snapshot taken at or before asOf
+// and whether the change log shows the entity as deleted at that moment.
+func loadAsOfState(db *gorm.DB, idsByType map[string][]string, asOf time.Time) (*asOfState, error) {
+	state := &asOfState{asOf: asOf, snapshots: make(map[string]interface{}), deleted: make(map[string]bool)}
+	for entityType, ids := range idsByType {
+		for _, chunk := range chunkIDs(ids, 1000) {
+			var snapshots []interface{}
+			switch entityType {
+			case "line":
+				var rows []LineHistory
+				if err := db.Where("entity_id IN ? AND updated_at <= ?", chunk, asOf).Order("version asc").Find(&rows).Error; err != nil {
+					return nil, err
+				}
+				for i := range rows {
+					snapshots = append(snapshots, &rows[i])
+				}
+			case "station":
+				var rows []StationHistory
+				if err := db.Where("entity_id IN ? AND updated_at <= ?", chunk, asOf).Order("version asc").Find(&rows).Error; err != nil {
+					return nil, err
+				}
+				for i := range rows {
+					snapshots = append(snapshots, &rows[i])
+				}
+			case "tool":
+				var rows []ToolHistory
+				if err := db.Where("entity_id IN ? AND updated_at <= ?", chunk, asOf).Order("version asc").Find(&rows).Error; err != nil {
+					return nil, err
+				}
+				for i := range rows {
+					snapshots = append(snapshots, &rows[i])
+				}
+			case "operation":
+				var rows []OperationHistory
+				if err := db.Where("entity_id IN ? AND updated_at <= ?", chunk, asOf).Order("version asc").Find(&rows).Error; err != nil {
+					return nil, err
+				}
+				for i := range rows {
+					snapshots = append(snapshots, &rows[i])
+				}
+			case "sequencegroup":
+				var rows []SequenceGroupHistory
+				if err := db.Where("entity_id IN ? AND updated_at <= ?", chunk, asOf).Order("version asc").Find(&rows).Error; err != nil {
+					return nil, err
+				}
+				for i := range rows {
+					snapshots = append(snapshots, &rows[i])
+				}
+			}
+			for _, snapshot := range snapshots {
+				id := historyEntityID(snapshot).String()
+				_, updatedAt := versionAuthor(snapshot)
+				if current, ok := state.snapshots[id]; ok {
+					if _, currentUpdatedAt := versionAuthor(current); currentUpdatedAt.After(updatedAt) {
+						continue
+					}
+				}
+				state.snapshots[id] = snapshot
+			}
+
+			var logs []EntityChangeLog
+			if err := db.Where("entity_id IN ? AND change_time <= ?", chunk, asOf).Order("change_time asc").Find(&logs).Error; err != nil {
+				return nil, err
+			}
+			for _, lg := range logs {
+				state.deleted[lg.EntityID.String()] = lg.OperationType == OpTypeDelete
+			}
+		}
+	}
+	return state, nil
+}
+
+func historyEntityID(snapshot interface{}) mssql.UniqueIdentifier {
+	switch v := snapshot.(type) {
+	case *LineHistory:
+		return v.EntityID
+	case *StationHistory:
+		return v.EntityID
+	case *ToolHistory:
+		return v.EntityID
+	case *OperationHistory:
+		return v.EntityID
+	case *SequenceGroupHistory:
+		return v.EntityID
+	default:
+		return mssql.UniqueIdentifier{}
+	}
+}
+
+// existedAt reports whether an entity was present at the as-of moment.
+func (s *asOfState) existedAt(base *BaseModel) bool {
+	if base.CreatedAt.After(s.asOf) {
+		return false
+	}
+	if base.DeletedAt.Valid && !base.DeletedAt.Time.After(s.asOf) {
+		return false
+	}
+	return !s.deleted[base.ID.String()]
+}
+
+// rewind replaces the fields of a live entity with the snapshot that was current at the as-of moment.
+func (s *asOfState) rewind(entity interface{}, base *BaseModel) error {
+	base.DeletedAt = gorm.DeletedAt{}
+	snapshot, ok := s.snapshots[base.ID.String()]
+	if !ok || !base.UpdatedAt.After(s.asOf) {
+		// The live row was already current at that time.
+		return nil
+	}
+	fields, err := versionedFields(snapshot)
+	if err != nil {
+		return err
+	}
+	base.UpdatedBy, base.UpdatedAt = versionAuthor(snapshot)
+	return applyVersionedFields(entity, fields)
+}
+
+func (s *asOfState) rewindOperations(ops []Operation) ([]Operation, error) {
+	result := []Operation{}
+	for i := range ops {
+		if !s.existedAt(&ops[i].BaseModel) {
+			continue
+		}
+		if err := s.rewind(&ops[i], &ops[i].BaseModel); err != nil {
+			return nil, err
+		}
+		result = append(result, ops[i])
+	}
+	return result, nil
+}
+
+func (s *asOfState) rewindTool(tool *Tool) error {
+	if err := s.rewind(tool, &tool.BaseModel); err != nil {
+		return err
+	}
+	ops, err := s.rewindOperations(tool.Operations)
+	tool.Operations = ops
+	return err
+}
+
+func (s *asOfState) rewindSequenceGroup(group *SequenceGroup) error {
+	if err := s.rewind(group, &group.BaseModel); err != nil {
+		return err
+	}
+	ops, err := s.rewindOperations(group.Operations)
+	group.Operations = ops
+	return err
+}
+
+func (s *asOfState) rewindStation(station *Station) error {
+	if err := s.rewind(station, &station.BaseModel); err != nil {
+		return err
+	}
+	tools := []Tool{}
+	for i := range station.Tools {
+		if !s.existedAt(&station.Tools[i].BaseModel) {
+			continue
+		}
+		if err := s.rewindTool(&station.Tools[i]); err != nil {
+			return err
+		}
+		tools = append(tools, station.Tools[i])
+	}
+	station.Tools = tools
+	groups := []SequenceGroup{}
+	for i := range station.SequenceGroups {
+		if !s.existedAt(&station.SequenceGroups[i].BaseModel) {
+			continue
+		}
+		if err := s.rewindSequenceGroup(&station.SequenceGroups[i]); err != nil {
+			return err
+		}
+		groups = append(groups, station.SequenceGroups[i])
+	}
+	station.SequenceGroups = groups
+	return nil
+}
+
+func (s *asOfState) rewindLine(line *Line) error {
+	if err := s.rewind(line, &line.BaseModel); err != nil {
+		return err
+	}
+	stations := []Station{}
+	for i := range line.Stations {
+		if !s.existedAt(&line.Stations[i].BaseModel) {
+			continue
+		}
+		if err := s.rewindStation(&line.Stations[i]); err != nil {
+			return err
+		}
+		stations = append(stations, line.Stations[i])
+	}
+	line.Stations = stations
+	return nil
+}
+
+// GetEntityHierarchyAsOf rebuilds the hierarchy below an entity as it looked at the given moment,
+// combining the live rows (including soft-deleted ones), their history snapshots and the change log.
+func (c *Core) GetEntityHierarchyAsOf(entityTypeStr string, entityIDStr string, asOfStr string) (interface{}, error) {
+	if c.DB == nil {
+		return nil, errors.New("DB not initialized")
+	}
+	entityIDmssql, err := parseMSSQLUniqueIdentifierFromString(entityIDStr)
+	if err != nil {
+		return nil, err
+	}
+	asOf, err := parseTimestampFlexible(asOfStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid as-of timestamp ('%s'): %w", asOfStr, err)
+	}
+	entityTypeNormalized := strings.ToLower(entityTypeStr)
+
+	unscopedDB := c.DB.Unscoped()
+	switch entityTypeNormalized {
+	case "line":
+		unscopedDB = unscopedDB.Preload("Stations.Tools.Operations").Preload("Stations.SequenceGroups.Operations")
+	case "station":
+		unscopedDB = unscopedDB.Preload("Tools.Operations").Preload("SequenceGroups.Operations")
+	case "tool", "sequencegroup":
+		unscopedDB = unscopedDB.Preload("Operations")
+	case "operation":
+	default:
+		return nil, fmt.Errorf("as-of loading not defined for type: %s", entityTypeStr)
+	}
+	root, _ := getModelInstance(entityTypeNormalized)
+	if err := unscopedDB.First(root, "id = ?", entityIDmssql).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("entity type %s with ID %s not found", entityTypeStr, entityIDStr)
+		}
+		return nil, fmt.Errorf("error loading hierarchy for type %s, ID %s: %w", entityTypeStr, entityIDStr, err)
+	}
+
+	idsByType, err := collectChildIDs(c.DB.Unscoped().Session(&gorm.Session{}), entityTypeNormalized, entityIDmssql)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect child IDs: %w", err)
+	}
+	if group, ok := root.(*SequenceGroup); ok {
+		// Operations hang below tools, so the group's members are not part of its child IDs.
+		for _, op := range group.Operations {
+			idsByType["operation"] = append(idsByType["operation"], op.ID.String())
+		}
+	}
+	state, err := loadAsOfState(c.DB, idsByType, asOf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history as of %s: %w", asOfStr, err)
+	}
+
+	switch e := root.(type) {
+	case *Line:
+		if !state.existedAt(&e.BaseModel) {
+			break
+		}
+		return e, state.rewindLine(e)
+	case *Station:
+		if !state.existedAt(&e.BaseModel) {
+			break
+		}
+		return e, state.rewindStation(e)
+	case *Tool:
+		if !state.existedAt(&e.BaseModel) {
+			break
+		}
+		return e, state.rewindTool(e)
+	case *SequenceGroup:
+		if !state.existedAt(&e.BaseModel) {
+			break
+		}
+		return e, state.rewindSequenceGroup(e)
+	case *Operation:
+		if !state.existedAt(&e.BaseModel) {
+			break
+		}
+		return e, state.rewind(e, &e.BaseModel)
+	}
+	return nil, fmt.Errorf("%s with ID %s did not exist at %s", entityTypeNormalized, entityIDStr, asOf.Format(time.RFC3339))
+}
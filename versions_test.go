@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// updateTestEntity updates an entity through the editor's update path.
+func updateTestEntity(t *testing.T, c *Core, entityType string, id string, updates map[string]string) {
+	t.Helper()
+	if _, err := c.UpdateEntityFieldsString("jdoe", entityType, id, time.Now().Format(time.RFC3339Nano), updates); err != nil {
+		t.Fatalf("update of %s %v returned %v", entityType, updates, err)
+	}
+}
+
+func TestRestoreEntityVersion(t *testing.T) {
+	c := newTestCore(t)
+	_, station, tool, _ := createTestLine(t, c.DB)
+	// Version 1 has no description, version 2 has one.
+	updateTestEntity(t, c, "station", station.ID.String(), map[string]string{"Description": "first"})
+	updateTestEntity(t, c, "station", station.ID.String(), map[string]string{"Name": "S1b", "Description": "second"})
+
+	restored, err := c.RestoreEntityVersion("jdoe", "station", station.ID.String(), time.Now().Format(time.RFC3339Nano), 1)
+	if err != nil {
+		t.Fatalf("restore returned %v", err)
+	}
+	if s := restored.(*Station); derefOrEmpty(s.Name) != "S1" || s.Description != nil {
+		t.Errorf("restored station has name %q and description %v, want S1 and no description", derefOrEmpty(s.Name), s.Description)
+	}
+	var count int64
+	if err := c.DB.Model(&StationHistory{}).Where("entity_id = ?", station.ID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("%d versions after the restore, want 3", count)
+	}
+
+	// A snapshot that no longer passes the field rules is not restored.
+	if err := c.DB.Model(&StationHistory{}).Where("entity_id = ? AND version = ?", station.ID, 1).Update("name", "Station 123").Error; err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.RestoreEntityVersion("jdoe", "station", station.ID.String(), time.Now().Format(time.RFC3339Nano), 1)
+	var validationErr *FieldValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("restore of a name that is too long returned %v, want a FieldValidationError", err)
+	}
+
+	// Neither is a tool type that does not belong to the tool class.
+	updateTestEntity(t, c, "tool", tool.ID.String(), map[string]string{"ToolType": "10"})
+	if err := c.DB.Model(&ToolHistory{}).Where("entity_id = ? AND version = ?", tool.ID, 1).Update("tool_type", "20").Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.RestoreEntityVersion("jdoe", "tool", tool.ID.String(), time.Now().Format(time.RFC3339Nano), 1); err == nil {
+		t.Error("restore of a tool type of another class returned no error")
+	}
+}
+
+func TestDiffEntityVersions(t *testing.T) {
+	c := newTestCore(t)
+	_, _, tool, _ := createTestLine(t, c.DB)
+	updateTestEntity(t, c, "tool", tool.ID.String(), map[string]string{"Description": "first"})
+	updateTestEntity(t, c, "tool", tool.ID.String(), map[string]string{"Name": "T1b", "Description": ""})
+
+	tests := []struct {
+		name     string
+		from, to int
+		// want lists "field: old -> new" per change, sorted by field.
+		want []string
+	}{
+		{name: "first two versions", from: 1, to: 2, want: []string{"Description:  -> first"}},
+		{name: "version against the live row", from: 2, to: 0, want: []string{"Description: first -> ", "Name: T1 -> T1b"}},
+		{name: "backwards", from: 0, to: 1, want: []string{"Name: T1b -> T1"}},
+		{name: "same version", from: 2, to: 2},
+	}
+	for _, tt := range tests {
+		diff, err := c.DiffEntityVersions("tool", tool.ID.String(), tt.from, tt.to)
+		if err != nil {
+			t.Errorf("%s: diff returned %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, change := range diff.Changes {
+			got = append(got, change.Field+": "+derefOrEmpty(change.OldValue)+" -> "+derefOrEmpty(change.NewValue))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: changes %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if _, err := c.DiffEntityVersions("tool", tool.ID.String(), 1, 9); err == nil {
+		t.Error("diff against a missing version returned no error")
+	}
+}
+
+func TestGetEntityHierarchyAsOf(t *testing.T) {
+	c := newTestCore(t)
+	before := time.Now().Add(-time.Hour)
+	_, station, tool, op := createTestLine(t, c.DB)
+	time.Sleep(10 * time.Millisecond)
+	asOf := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	updateTestEntity(t, c, "station", station.ID.String(), map[string]string{"Name": "S1b"})
+	updateTestEntity(t, c, "operation", op.ID.String(), map[string]string{"Description": "later"})
+	if _, err := c.CreateEntity("jdoe", "tool", station.ID.String()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteEntityByIDString("jdoe", "operation", op.ID.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := c.GetEntityHierarchyAsOf("station", station.ID.String(), asOf.Format(time.RFC3339Nano))
+	if err != nil {
+		t.Fatalf("as-of load returned %v", err)
+	}
+	past := data.(*Station)
+	if derefOrEmpty(past.Name) != "S1" {
+		t.Errorf("station named %q in the past, want S1", derefOrEmpty(past.Name))
+	}
+	if len(past.Tools) != 1 || past.Tools[0].ID != tool.ID || len(past.Tools[0].Operations) != 1 {
+		t.Fatalf("past station has tools %+v, want T1 with its operation", past.Tools)
+	}
+	if d := past.Tools[0].Operations[0].Description; d != nil {
+		t.Errorf("past operation has description %q, want none", *d)
+	}
+
+	data, err = c.GetEntityHierarchyAsOf("station", station.ID.String(), time.Now().Format(time.RFC3339Nano))
+	if err != nil {
+		t.Fatalf("as-of load returned %v", err)
+	}
+	if now := data.(*Station); derefOrEmpty(now.Name) != "S1b" || len(now.Tools) != 2 {
+		t.Errorf("current station %q has %d tools, want S1b with 2", derefOrEmpty(now.Name), len(now.Tools))
+	} else {
+		for _, tl := range now.Tools {
+			if len(tl.Operations) != 0 {
+				t.Errorf("tool %s still has the deleted operation", derefOrEmpty(tl.Name))
+			}
+		}
+	}
+
+	if _, err := c.GetEntityHierarchyAsOf("station", station.ID.String(), before.Format(time.RFC3339Nano)); err == nil {
+		t.Error("as-of load before the station was created returned no error")
+	}
+}
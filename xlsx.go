@@ -0,0 +1,292 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xlsxSheet is one worksheet of a workbook; the first row is written as header.
+type xlsxSheet struct {
+	Name string
+	Rows [][]string
+}
+
+const xlsxContentTypesHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+// xlsxSheetName makes a worksheet name valid and unique within the workbook.
+func xlsxSheetName(name string, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		name = "Sheet"
+	}
+	base := []rune(name)
+	if len(base) > 31 {
+		base = base[:31]
+	}
+	candidate := string(base)
+	for i := 2; used[strings.ToLower(candidate)]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		trimmed := base
+		if len(trimmed)+len(suffix) > 31 {
+			trimmed = trimmed[:31-len(suffix)]
+		}
+		candidate = string(trimmed) + suffix
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// xlsxColumnName converts a zero-based column index to its letter name (0 -> A, 26 -> AA).
+func xlsxColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+func xlsxEscape(value string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+// writeXLSX writes a workbook with inline string cells, which Excel opens without a shared string table.
+func writeXLSX(w io.Writer, sheets []xlsxSheet) error {
+	if len(sheets) == 0 {
+		sheets = []xlsxSheet{{Name: "Sheet"}}
+	}
+	zw := zip.NewWriter(w)
+	writePart := func(name string, content string) error {
+		part, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(part, content)
+		return err
+	}
+
+	var contentTypes, workbook, workbookRels strings.Builder
+	contentTypes.WriteString(xlsxContentTypesHeader)
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	workbookRels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+
+	usedNames := make(map[string]bool)
+	for i, sheet := range sheets {
+		n := strconv.Itoa(i + 1)
+		contentTypes.WriteString(`<Override PartName="/xl/worksheets/sheet` + n + `.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` + "\n")
+		workbook.WriteString(`<sheet name="` + xlsxEscape(xlsxSheetName(sheet.Name, usedNames)) + `" sheetId="` + n + `" r:id="rId` + n + `"/>`)
+		workbookRels.WriteString(`<Relationship Id="rId` + n + `" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet` + n + `.xml"/>`)
+
+		var sheetXML strings.Builder
+		sheetXML.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+			`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+		if len(sheet.Rows) > 0 {
+			// Keep the header row visible while scrolling.
+			sheetXML.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+		}
+		sheetXML.WriteString(`<sheetData>`)
+		for r, row := range sheet.Rows {
+			rowNumber := strconv.Itoa(r + 1)
+			sheetXML.WriteString(`<row r="` + rowNumber + `">`)
+			for c, value := range row {
+				if value == "" {
+					continue
+				}
+				sheetXML.WriteString(`<c r="` + xlsxColumnName(c) + rowNumber + `" t="inlineStr"><is><t xml:space="preserve">` + xlsxEscape(value) + `</t></is></c>`)
+			}
+			sheetXML.WriteString(`</row>`)
+		}
+		sheetXML.WriteString(`</sheetData></worksheet>`)
+		if err := writePart("xl/worksheets/sheet"+n+".xml", sheetXML.String()); err != nil {
+			return err
+		}
+	}
+	contentTypes.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	workbookRels.WriteString(`</Relationships>`)
+
+	if err := writePart("[Content_Types].xml", contentTypes.String()); err != nil {
+		return err
+	}
+	if err := writePart("_rels/.rels", xlsxRootRels); err != nil {
+		return err
+	}
+	if err := writePart("xl/workbook.xml", workbook.String()); err != nil {
+		return err
+	}
+	if err := writePart("xl/_rels/workbook.xml.rels", workbookRels.String()); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+// xlsxText is a shared or inline string, either plain or split into rich text runs.
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Number int `xml:"r,attr"`
+		Cells  []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// xlsxColumnIndex converts the letters of a cell reference to a zero-based column index (B7 -> 1).
+func xlsxColumnIndex(ref string) int {
+	index := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		index = index*26 + int(r-'A'+1)
+	}
+	return index - 1
+}
+
+// readXLSX reads the cell texts of every worksheet of a workbook. Rows keep their position,
+// so Rows[i] is row i+1 in Excel.
+func readXLSX(path string) ([]xlsxSheet, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening workbook '%s': %w", path, err)
+	}
+	defer zr.Close()
+	parts := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		parts[strings.TrimPrefix(f.Name, "/")] = f
+	}
+	readPart := func(name string, target interface{}) error {
+		f, ok := parts[name]
+		if !ok {
+			return fmt.Errorf("workbook part '%s' is missing", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		return xml.NewDecoder(rc).Decode(target)
+	}
+
+	var workbook xlsxWorkbook
+	if err := readPart("xl/workbook.xml", &workbook); err != nil {
+		return nil, fmt.Errorf("error reading workbook: %w", err)
+	}
+	var rels xlsxRelationships
+	if err := readPart("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, fmt.Errorf("error reading workbook relationships: %w", err)
+	}
+	targets := make(map[string]string, len(rels.Relationships))
+	for _, rel := range rels.Relationships {
+		target := strings.TrimPrefix(rel.Target, "/")
+		if !strings.HasPrefix(target, "xl/") {
+			target = "xl/" + target
+		}
+		targets[rel.ID] = target
+	}
+	var sharedStrings struct {
+		Items []xlsxText `xml:"si"`
+	}
+	if _, ok := parts["xl/sharedStrings.xml"]; ok {
+		if err := readPart("xl/sharedStrings.xml", &sharedStrings); err != nil {
+			return nil, fmt.Errorf("error reading shared strings: %w", err)
+		}
+	}
+
+	sheets := make([]xlsxSheet, 0, len(workbook.Sheets))
+	for _, sheetRef := range workbook.Sheets {
+		var worksheet xlsxWorksheet
+		if err := readPart(targets[sheetRef.RID], &worksheet); err != nil {
+			return nil, fmt.Errorf("error reading sheet '%s': %w", sheetRef.Name, err)
+		}
+		sheet := xlsxSheet{Name: sheetRef.Name}
+		for _, row := range worksheet.Rows {
+			// Excel leaves out empty rows; pad them so row numbers stay as shown in Excel.
+			for row.Number > 0 && len(sheet.Rows) < row.Number-1 {
+				sheet.Rows = append(sheet.Rows, nil)
+			}
+			var values []string
+			for i, cell := range row.Cells {
+				column := i
+				if cell.Ref != "" {
+					column = xlsxColumnIndex(cell.Ref)
+				}
+				for len(values) <= column {
+					values = append(values, "")
+				}
+				switch cell.Type {
+				case "s":
+					index, err := strconv.Atoi(cell.Value)
+					if err != nil || index < 0 || index >= len(sharedStrings.Items) {
+						return nil, fmt.Errorf("invalid shared string in sheet '%s' cell %s", sheetRef.Name, cell.Ref)
+					}
+					values[column] = sharedStrings.Items[index].String()
+				case "inlineStr":
+					values[column] = cell.Inline.String()
+				default:
+					values[column] = cell.Value
+				}
+			}
+			sheet.Rows = append(sheet.Rows, values)
+		}
+		sheets = append(sheets, sheet)
+	}
+	return sheets, nil
+}
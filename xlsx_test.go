@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestXLSXRoundTrip(t *testing.T) {
+	wide := make([]string, 28)
+	wide[0], wide[25], wide[26], wide[27] = "A", "Z", "AA", "AB"
+
+	tests := []struct {
+		name   string
+		sheets []xlsxSheet
+		want   []xlsxSheet
+	}{
+		{
+			name:   "no sheets",
+			sheets: nil,
+			want:   []xlsxSheet{{Name: "Sheet"}},
+		},
+		{
+			name: "header and rows",
+			sheets: []xlsxSheet{{Name: "Operations", Rows: [][]string{
+				{"Line", "Station", "Tool", "Name"},
+				{"L1", "S1", "T1", "Op 1"},
+			}}},
+			want: []xlsxSheet{{Name: "Operations", Rows: [][]string{
+				{"Line", "Station", "Tool", "Name"},
+				{"L1", "S1", "T1", "Op 1"},
+			}}},
+		},
+		{
+			name: "special characters and spaces",
+			sheets: []xlsxSheet{{Name: "Text", Rows: [][]string{
+				{"<a & b>", `"quoted" 'single'`, "  padded  ", "line1\nline2", "Ünïcödé 中文"},
+			}}},
+			want: []xlsxSheet{{Name: "Text", Rows: [][]string{
+				{"<a & b>", `"quoted" 'single'`, "  padded  ", "line1\nline2", "Ünïcödé 中文"},
+			}}},
+		},
+		{
+			name: "empty cells and rows keep their position",
+			sheets: []xlsxSheet{{Name: "Gaps", Rows: [][]string{
+				{"A", "", "C", ""},
+				{},
+				{"", "B"},
+			}}},
+			want: []xlsxSheet{{Name: "Gaps", Rows: [][]string{
+				{"A", "", "C"},
+				nil,
+				{"", "B"},
+			}}},
+		},
+		{
+			name:   "columns after Z",
+			sheets: []xlsxSheet{{Name: "Wide", Rows: [][]string{wide}}},
+			want:   []xlsxSheet{{Name: "Wide", Rows: [][]string{wide}}},
+		},
+		{
+			name: "sheet names are made valid and unique",
+			sheets: []xlsxSheet{
+				{Name: "Line/1"},
+				{Name: "line_1"},
+				{Name: "A station name longer than thirty-one characters"},
+			},
+			want: []xlsxSheet{
+				{Name: "Line_1"},
+				{Name: "line_1 (2)"},
+				{Name: "A station name longer than thir"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "workbook.xlsx")
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writeXLSX(file, tt.sheets); err != nil {
+			t.Fatalf("%s: writeXLSX returned %v", tt.name, err)
+		}
+		if err := file.Close(); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readXLSX(path)
+		if err != nil {
+			t.Fatalf("%s: readXLSX returned %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: read back %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}